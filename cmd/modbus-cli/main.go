@@ -0,0 +1,235 @@
+// Command modbus-cli performs single reads and writes against a Modbus TCP
+// device, decoding registers the same way as the modbus package.
+//
+//	modbus-cli read-holding -host 10.0.0.5 -unit 3 -type float32 -order CDAB 100 2
+//	modbus-cli write-coil -host 10.0.0.5 12 on
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/modbus_client"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usage = `usage: modbus-cli <command> [flags] <address> [arguments]
+
+commands:
+  read-coils      <address> [count]   read coils
+  read-discrete   <address> [count]   read discrete inputs
+  read-holding    <address> [count]   read holding registers, count values of -type
+  read-input      <address> [count]   read input registers, count values of -type
+  write-coil      <address> on|off    write a single coil
+  write-register  <address> <value>   write a single register (16-bit types only)
+  write-registers <address> <value>…  write consecutive registers, values of -type
+
+Run "modbus-cli <command> -h" for the flags.`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "modbus-cli:", err)
+		os.Exit(1)
+	}
+}
+
+// options are the flags shared by every command.
+type options struct {
+	host    string
+	port    int
+	unit    uint
+	timeout time.Duration
+	typ     string
+	order   modbus.WordByteOrder
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		fmt.Fprintln(stdout, usage)
+		return nil
+	}
+	command := args[0]
+
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	opts := options{}
+	fs.StringVar(&opts.host, "host", "127.0.0.1", "device host name or IP address")
+	fs.IntVar(&opts.port, "port", 502, "device TCP port")
+	fs.UintVar(&opts.unit, "unit", 1, "unit ID (slave ID)")
+	fs.DurationVar(&opts.timeout, "timeout", 2*time.Second, "response timeout")
+	fs.StringVar(&opts.typ, "type", "uint16", "register value type: uint16, int16, uint32, int32, float32, uint64, int64, float64, hex or binary")
+	order := fs.String("order", "ABCD", "word/byte order of multi-register values: ABCD, CDAB, BADC or DCBA")
+	if err := fs.Parse(args[1:]); err != nil {
+		// The flag set has already printed the command's flags.
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	var err error
+	if opts.order, err = modbus.ParseWordByteOrder(*order); err != nil {
+		return err
+	}
+	if opts.unit > 255 {
+		return fmt.Errorf("invalid unit ID %d", opts.unit)
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("%s: missing address\n\n%s", command, usage)
+	}
+	address, err := parseAddress(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	c := modbus_client.NewModbusClient(opts.host, opts.port, opts.timeout, nil)
+	defer c.Close()
+
+	rest := fs.Args()[1:]
+	switch command {
+	case "read-coils", "read-discrete":
+		return readBits(c, command, opts, address, rest, stdout)
+	case "read-holding", "read-input":
+		return readRegisters(c, command, opts, address, rest, stdout)
+	case "write-coil":
+		return writeCoil(c, opts, address, rest)
+	case "write-register", "write-registers":
+		return writeRegisters(c, command, opts, address, rest)
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", command, usage)
+	}
+}
+
+func readBits(c *modbus_client.ModbusClient, command string, opts options, address uint16, args []string, stdout io.Writer) error {
+	count, err := parseCount(args)
+	if err != nil {
+		return err
+	}
+
+	read := c.ReadCoils
+	if command == "read-discrete" {
+		read = c.ReadDiscreteInputs
+	}
+	values, err := read(byte(opts.unit), address, count)
+	if err != nil {
+		return err
+	}
+
+	for i, v := range values {
+		bit := 0
+		if v {
+			bit = 1
+		}
+		fmt.Fprintf(stdout, "%d: %d\n", int(address)+i, bit)
+	}
+	return nil
+}
+
+func readRegisters(c *modbus_client.ModbusClient, command string, opts options, address uint16, args []string, stdout io.Writer) error {
+	count, err := parseCount(args)
+	if err != nil {
+		return err
+	}
+	width, err := registerWidth(opts.typ)
+	if err != nil {
+		return err
+	}
+
+	quantity := int(count) * width
+	if quantity > modbus.MaxReadRegisters {
+		return fmt.Errorf("%s: %d %s values need %d registers, at most %d can be read at once", command, count, opts.typ, quantity, modbus.MaxReadRegisters)
+	}
+
+	read := c.ReadHoldingRegisters
+	if command == "read-input" {
+		read = c.ReadInputRegisters
+	}
+	registers, err := read(byte(opts.unit), address, uint16(quantity))
+	if err != nil {
+		return err
+	}
+
+	values, err := decodeValues(modbus.Uint16sToBytes(registers, binary.BigEndian), opts.typ, opts.order)
+	if err != nil {
+		return err
+	}
+	for i, v := range values {
+		fmt.Fprintf(stdout, "%d: %s\n", int(address)+i*width, v)
+	}
+	return nil
+}
+
+func writeCoil(c *modbus_client.ModbusClient, opts options, address uint16, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("write-coil: expected one value (on or off)")
+	}
+
+	var value bool
+	switch strings.ToLower(args[0]) {
+	case "on", "1", "true":
+		value = true
+	case "off", "0", "false":
+	default:
+		return fmt.Errorf("write-coil: invalid value %q, expected on or off", args[0])
+	}
+	return c.WriteSingleCoil(byte(opts.unit), address, value)
+}
+
+func writeRegisters(c *modbus_client.ModbusClient, command string, opts options, address uint16, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s: missing value", command)
+	}
+	if command == "write-register" && len(args) != 1 {
+		return fmt.Errorf("write-register: expected one value, use write-registers for more")
+	}
+
+	var data []byte
+	for _, arg := range args {
+		b, err := encodeValue(arg, opts.typ, opts.order)
+		if err != nil {
+			return fmt.Errorf("%s: invalid %s value %q: %w", command, opts.typ, arg, err)
+		}
+		data = append(data, b...)
+	}
+	registers, err := modbus.BytesToUint16s(data, binary.BigEndian)
+	if err != nil {
+		return err
+	}
+
+	if command == "write-register" {
+		if len(registers) != 1 {
+			return fmt.Errorf("write-register: a %s value needs %d registers, use write-registers", opts.typ, len(registers))
+		}
+		return c.WriteSingleRegister(byte(opts.unit), address, registers[0])
+	}
+	return c.WriteMultipleRegisters(byte(opts.unit), address, registers)
+}
+
+func parseAddress(s string) (uint16, error) {
+	v, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", s)
+	}
+	return uint16(v), nil
+}
+
+// parseCount reads the optional count argument of the read commands.
+func parseCount(args []string) (uint16, error) {
+	switch len(args) {
+	case 0:
+		return 1, nil
+	case 1:
+		v, err := strconv.ParseUint(args[0], 0, 16)
+		if err != nil || v == 0 {
+			return 0, fmt.Errorf("invalid count %q", args[0])
+		}
+		return uint16(v), nil
+	default:
+		return 0, fmt.Errorf("too many arguments: %v", args)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/server"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// startDevice serves a register bank for unit 1 and returns the flags that
+// point modbus-cli at it.
+func startDevice(t *testing.T) (*server.RegisterBank, []string, func()) {
+	bank := server.NewRegisterBank()
+	bank.AddRange(1, modbus.TableCoils, 0, 16)
+	bank.AddRange(1, modbus.TableHoldingRegisters, 0, 16)
+	bank.AddRange(1, modbus.TableInputRegisters, 0, 16)
+	s := server.NewServer()
+	bank.Register(s)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.Serve(ln)
+
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	return bank, []string{"-host", "127.0.0.1", "-port", port}, func() { s.Close() }
+}
+
+func runCLI(t *testing.T, flags []string, command string, args ...string) string {
+	out := &bytes.Buffer{}
+	argv := append(append([]string{command}, flags...), args...)
+	if err := run(argv, out); err != nil {
+		t.Fatalf("%v: %v", argv, err)
+	}
+	return out.String()
+}
+
+func TestRun_ReadWrite(t *testing.T) {
+	bank, flags, stop := startDevice(t)
+	defer stop()
+
+	runCLI(t, flags, "write-registers", "-type", "float32", "-order", "CDAB", "2", "1.5", "-3.25")
+	registers, _ := bank.Get(1, modbus.TableHoldingRegisters, 2, 4)
+	if !reflect.DeepEqual(registers, []uint16{0x0000, 0x3FC0, 0x0000, 0xC050}) {
+		t.Errorf("unexpected registers after write: %04X", registers)
+	}
+
+	out := runCLI(t, flags, "read-holding", "-type", "float32", "-order", "CDAB", "2", "2")
+	if out != "2: 1.5\n4: -3.25\n" {
+		t.Errorf("unexpected read-holding output:\n%s", out)
+	}
+
+	runCLI(t, flags, "write-register", "-type", "int16", "0", "-7")
+	out = runCLI(t, flags, "read-holding", "-type", "hex", "0")
+	if out != "0: 0xfff9\n" {
+		t.Errorf("unexpected read-holding output:\n%s", out)
+	}
+
+	runCLI(t, flags, "write-coil", "3", "on")
+	out = runCLI(t, flags, "read-coils", "2", "3")
+	if out != "2: 0\n3: 1\n4: 0\n" {
+		t.Errorf("unexpected read-coils output:\n%s", out)
+	}
+
+	bank.Set(1, modbus.TableInputRegisters, 0, 0x8001)
+	out = runCLI(t, flags, "read-input", "-type", "binary", "0")
+	if out != "0: 10000000 00000001\n" {
+		t.Errorf("unexpected read-input output:\n%s", out)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	_, flags, stop := startDevice(t)
+	defer stop()
+
+	for _, argv := range [][]string{
+		{"read-holding"},
+		{"frobnicate", "0"},
+		{"read-holding", "-order", "ACBD", "0"},
+		{"read-holding", "99"},
+		{"write-register", "-type", "float32", "0", "1.5"},
+		{"write-coil", "0", "maybe"},
+		{"read-holding", "-type", "float32", "0", "63"},
+	} {
+		argv = append(append([]string{argv[0]}, flags...), argv[1:]...)
+		if err := run(argv, &bytes.Buffer{}); err == nil {
+			t.Errorf("%v: expected error, got nil", argv)
+		}
+	}
+}
+
+func TestRun_Help(t *testing.T) {
+	for _, argv := range [][]string{{"-h"}, {"help"}, {"read-holding", "-h"}, {"write-coil", "-help"}} {
+		if err := run(argv, &bytes.Buffer{}); err != nil {
+			t.Errorf("%v: expected no error, got %v", argv, err)
+		}
+	}
+}
+
+func TestRun_CountOverflow(t *testing.T) {
+	_, flags, stop := startDevice(t)
+	defer stop()
+
+	// 32800 float32 values are 65600 registers, which would wrap to 64.
+	argv := append(append([]string{"read-holding"}, flags...), "-type", "float32", "0", "32800")
+	err := run(argv, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "65600 registers") {
+		t.Errorf("expected the count to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"modbus_client/pkg/modbus"
+	"strconv"
+)
+
+// registerWidth returns how many registers one value of typ occupies.
+func registerWidth(typ string) (int, error) {
+	switch typ {
+	case "uint16", "int16", "hex", "binary":
+		return 1, nil
+	case "uint32", "int32", "float32":
+		return 2, nil
+	case "uint64", "int64", "float64":
+		return 4, nil
+	default:
+		return 0, fmt.Errorf("unknown type %q", typ)
+	}
+}
+
+// decodeValues splits register data into values of typ and formats them.
+// hex and binary show the bytes as received, one register at a time.
+func decodeValues(data []byte, typ string, order modbus.WordByteOrder) ([]string, error) {
+	width, err := registerWidth(typ)
+	if err != nil {
+		return nil, err
+	}
+	size := 2 * width
+	if len(data)%size != 0 {
+		return nil, fmt.Errorf("%d bytes is not a whole number of %s values", len(data), typ)
+	}
+
+	var values []string
+	for i := 0; i < len(data); i += size {
+		s, err := decodeValue(data[i:i+size], typ, order)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+func decodeValue(b []byte, typ string, order modbus.WordByteOrder) (string, error) {
+	switch typ {
+	case "uint16":
+		v, err := modbus.BytesToUint16(b, order.ByteOrder)
+		return strconv.FormatUint(uint64(v), 10), err
+	case "int16":
+		v, err := modbus.BytesToInt16(b, order.ByteOrder)
+		return strconv.FormatInt(int64(v), 10), err
+	case "uint32":
+		v, err := modbus.BytesToUint32(b, order)
+		return strconv.FormatUint(uint64(v), 10), err
+	case "int32":
+		v, err := modbus.BytesToInt32(b, order)
+		return strconv.FormatInt(int64(v), 10), err
+	case "float32":
+		v, err := modbus.BytesToFloat32(b, order)
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), err
+	case "uint64":
+		v, err := modbus.BytesToUint64(b, order)
+		return strconv.FormatUint(v, 10), err
+	case "int64":
+		v, err := modbus.BytesToInt64(b, order)
+		return strconv.FormatInt(v, 10), err
+	case "float64":
+		v, err := modbus.BytesToFloat64(b, order)
+		return strconv.FormatFloat(v, 'g', -1, 64), err
+	case "hex":
+		return "0x" + modbus.BytesToHexString(b), nil
+	case "binary":
+		return modbus.BytesToBinaryString(b, true), nil
+	default:
+		return "", fmt.Errorf("unknown type %q", typ)
+	}
+}
+
+// encodeValue parses s as a value of typ and returns its register bytes.
+// hex and binary values give the bytes of one register as sent.
+func encodeValue(s string, typ string, order modbus.WordByteOrder) ([]byte, error) {
+	switch typ {
+	case "uint16":
+		v, err := strconv.ParseUint(s, 0, 16)
+		if err != nil {
+			return nil, err
+		}
+		return modbus.Uint16ToBytes(uint16(v), order.ByteOrder), nil
+	case "int16":
+		v, err := strconv.ParseInt(s, 0, 16)
+		if err != nil {
+			return nil, err
+		}
+		return modbus.Int16ToBytes(int16(v), order.ByteOrder), nil
+	case "uint32":
+		v, err := strconv.ParseUint(s, 0, 32)
+		if err != nil {
+			return nil, err
+		}
+		return modbus.Uint32ToBytes(uint32(v), order), nil
+	case "int32":
+		v, err := strconv.ParseInt(s, 0, 32)
+		if err != nil {
+			return nil, err
+		}
+		return modbus.Int32ToBytes(int32(v), order), nil
+	case "float32":
+		v, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return nil, err
+		}
+		return modbus.Float32ToBytes(float32(v), order), nil
+	case "uint64":
+		v, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		return modbus.Uint64ToBytes(v, order), nil
+	case "int64":
+		v, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		return modbus.Int64ToBytes(v, order), nil
+	case "float64":
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return modbus.Float64ToBytes(v, order), nil
+	case "hex":
+		b, err := modbus.HexStringToBytes(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != 2 {
+			return nil, fmt.Errorf("hex value %q is not one register (2 bytes)", s)
+		}
+		return b, nil
+	case "binary":
+		b, err := modbus.BinaryStringToBytes(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 2 {
+			return nil, fmt.Errorf("binary value %q is longer than one register", s)
+		}
+		return append(make([]byte, 2-len(b)), b...), nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"modbus_client/pkg/modbus"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecodeValues(t *testing.T) {
+	abcd, _ := modbus.ParseWordByteOrder("ABCD")
+	cdab, _ := modbus.ParseWordByteOrder("CDAB")
+
+	tests := []struct {
+		typ   string
+		order modbus.WordByteOrder
+		value string
+		wire  []byte
+	}{
+		{"uint16", abcd, "4660", []byte{0x12, 0x34}},
+		{"int16", abcd, "-2", []byte{0xFF, 0xFE}},
+		{"uint32", cdab, "305419896", []byte{0x56, 0x78, 0x12, 0x34}},
+		{"int32", abcd, "-1", []byte{0xFF, 0xFF, 0xFF, 0xFF}},
+		{"float32", abcd, "1.5", []byte{0x3F, 0xC0, 0x00, 0x00}},
+		{"float32", cdab, "1.5", []byte{0x00, 0x00, 0x3F, 0xC0}},
+		{"float64", abcd, "-2", []byte{0xC0, 0x00, 0, 0, 0, 0, 0, 0}},
+		{"hex", abcd, "0xbeef", []byte{0xBE, 0xEF}},
+		{"binary", abcd, "00000001 00000011", []byte{0x01, 0x03}},
+	}
+
+	for _, tt := range tests {
+		values, err := decodeValues(tt.wire, tt.typ, tt.order)
+		if err != nil {
+			t.Fatalf("decodeValues(%s) error: %v", tt.typ, err)
+		}
+		if len(values) != 1 || values[0] != tt.value {
+			t.Errorf("decodeValues(%s) = %v, expected %q", tt.typ, values, tt.value)
+		}
+
+		if tt.typ == "binary" {
+			continue
+		}
+		b, err := encodeValue(tt.value, tt.typ, tt.order)
+		if err != nil {
+			t.Fatalf("encodeValue(%s, %q) error: %v", tt.typ, tt.value, err)
+		}
+		if !reflect.DeepEqual(b, tt.wire) {
+			t.Errorf("encodeValue(%s, %q) = %X, expected %X", tt.typ, tt.value, b, tt.wire)
+		}
+	}
+}
+
+func TestEncodeValue_Errors(t *testing.T) {
+	order, _ := modbus.ParseWordByteOrder("ABCD")
+
+	if b, err := encodeValue("101", "binary", order); err != nil || !reflect.DeepEqual(b, []byte{0x00, 0x05}) {
+		t.Errorf("encodeValue(binary, 101) = %X, %v", b, err)
+	}
+	for _, tt := range []struct{ typ, value string }{
+		{"int16", "40000"},
+		{"hex", "0x123456"},
+		{"binary", "11111111000000001"},
+		{"uint8", "1"},
+	} {
+		if _, err := encodeValue(tt.value, tt.typ, order); err == nil {
+			t.Errorf("encodeValue(%s, %q): expected error, got nil", tt.typ, tt.value)
+		}
+	}
+
+	if _, err := decodeValues([]byte{0x00, 0x01}, "float32", order); err == nil {
+		t.Error("expected error decoding one register as float32, got nil")
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/server"
+	"time"
+)
+
+// behaviour produces the successive values of a simulated register.
+type behaviour interface {
+	// next returns the value of the register elapsed after the simulator
+	// started. It is called once per tick.
+	next(elapsed time.Duration, bank *server.RegisterBank) (float64, error)
+}
+
+// register is a coil or register driven by a behaviour.
+type register struct {
+	unitID    byte
+	table     modbus.Table
+	address   uint16
+	behaviour behaviour
+	// set records that the register has been given its first value.
+	set bool
+}
+
+func newRegister(unitID byte, rc registerConfig) (*register, error) {
+	table, err := modbus.ParseTable(rc.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := newBehaviour(unitID, rc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &register{
+		unitID:    unitID,
+		table:     table,
+		address:   rc.Address,
+		behaviour: b,
+	}, nil
+}
+
+// newBehaviour builds the behaviour named by rc.Behaviour:
+//
+//	constant     Value, set once at startup so that a master may overwrite
+//	             it (the default)
+//	counter      starts at Value and adds Step every tick, wrapping from Max
+//	             back to Min (0 and 65535 by default)
+//	sine         Offset + Amplitude*sin(2*pi*t/Period)
+//	random_walk  starts at Value and moves by up to Step every tick, kept
+//	             within Min and Max
+//	mirror       copies SourceTable/SourceAddress of SourceUnit (this unit by
+//	             default)
+func newBehaviour(unitID byte, rc registerConfig) (behaviour, error) {
+	min, max := 0.0, 65535.0
+	if rc.Min != nil {
+		min = *rc.Min
+	}
+	if rc.Max != nil {
+		max = *rc.Max
+	}
+	if min > max {
+		return nil, fmt.Errorf("min %v is greater than max %v", min, max)
+	}
+
+	switch rc.Behaviour {
+	case "", "constant":
+		return &constant{value: rc.Value}, nil
+	case "counter":
+		if rc.Step == 0 {
+			return nil, fmt.Errorf("counter needs a non-zero step")
+		}
+		return &counter{value: rc.Value - rc.Step, step: rc.Step, min: min, max: max}, nil
+	case "sine":
+		if rc.Period <= 0 {
+			return nil, fmt.Errorf("sine needs a positive period")
+		}
+		return &sine{amplitude: rc.Amplitude, offset: rc.Offset, period: time.Duration(rc.Period)}, nil
+	case "random_walk":
+		return &randomWalk{value: rc.Value, step: rc.Step, min: min, max: max, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}, nil
+	case "mirror":
+		table, err := modbus.ParseTable(rc.SourceTable)
+		if err != nil {
+			return nil, fmt.Errorf("mirror source: %w", err)
+		}
+		source := unitID
+		if rc.SourceUnit != nil {
+			source = *rc.SourceUnit
+		}
+		return &mirror{unitID: source, table: table, address: rc.SourceAddress}, nil
+	default:
+		return nil, fmt.Errorf("unknown behaviour %q", rc.Behaviour)
+	}
+}
+
+// update stores the next value of r in bank. Constants are only stored the
+// first time, so that writes by a master are not reverted on the next tick.
+func (r *register) update(elapsed time.Duration, bank *server.RegisterBank) error {
+	if _, ok := r.behaviour.(*constant); ok && r.set {
+		return nil
+	}
+
+	v, err := r.behaviour.next(elapsed, bank)
+	if err != nil {
+		return err
+	}
+	if err := bank.Set(r.unitID, r.table, r.address, toValue(v, r.table)); err != nil {
+		return err
+	}
+	r.set = true
+	return nil
+}
+
+// toValue converts a behaviour's output to what the table stores: 0 or 1 for
+// bit tables; otherwise the rounded value, negative values as int16 two's
+// complement, saturating at the 16-bit limits.
+func toValue(v float64, table modbus.Table) uint16 {
+	if table.IsBit() {
+		if v != 0 {
+			return 1
+		}
+		return 0
+	}
+
+	v = math.Round(v)
+	switch {
+	case v < math.MinInt16:
+		return uint16(0x8000)
+	case v < 0:
+		return uint16(int16(v))
+	case v > math.MaxUint16:
+		return math.MaxUint16
+	default:
+		return uint16(v)
+	}
+}
+
+type constant struct {
+	value float64
+}
+
+func (b *constant) next(time.Duration, *server.RegisterBank) (float64, error) {
+	return b.value, nil
+}
+
+type counter struct {
+	value, step, min, max float64
+}
+
+func (b *counter) next(time.Duration, *server.RegisterBank) (float64, error) {
+	b.value += b.step
+	switch {
+	case b.value > b.max:
+		b.value = b.min
+	case b.value < b.min:
+		b.value = b.max
+	}
+	return b.value, nil
+}
+
+type sine struct {
+	amplitude, offset float64
+	period            time.Duration
+}
+
+func (b *sine) next(elapsed time.Duration, _ *server.RegisterBank) (float64, error) {
+	phase := 2 * math.Pi * float64(elapsed) / float64(b.period)
+	return b.offset + b.amplitude*math.Sin(phase), nil
+}
+
+type randomWalk struct {
+	value, step, min, max float64
+	rand                  *rand.Rand
+}
+
+func (b *randomWalk) next(time.Duration, *server.RegisterBank) (float64, error) {
+	b.value += (b.rand.Float64()*2 - 1) * b.step
+	b.value = math.Max(b.min, math.Min(b.max, b.value))
+	return b.value, nil
+}
+
+type mirror struct {
+	unitID  byte
+	table   modbus.Table
+	address uint16
+}
+
+func (b *mirror) next(_ time.Duration, bank *server.RegisterBank) (float64, error) {
+	values, err := bank.Get(b.unitID, b.table, b.address, 1)
+	if err != nil {
+		return 0, fmt.Errorf("mirror source unit %d, %v %d: %w", b.unitID, b.table, b.address, err)
+	}
+	return float64(values[0]), nil
+}
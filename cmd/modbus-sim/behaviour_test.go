@@ -0,0 +1,120 @@
+package main
+
+import (
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/server"
+	"testing"
+	"time"
+)
+
+func TestCounter(t *testing.T) {
+	max := 2.0
+	b, err := newBehaviour(1, registerConfig{Behaviour: "counter", Step: 1, Max: &max})
+	if err != nil {
+		t.Fatalf("newBehaviour() error: %v", err)
+	}
+
+	var got []float64
+	for i := 0; i < 5; i++ {
+		v, _ := b.next(0, nil)
+		got = append(got, v)
+	}
+	expected := []float64{0, 1, 2, 0, 1}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("unexpected counter sequence: %v", got)
+		}
+	}
+}
+
+func TestSine(t *testing.T) {
+	b, _ := newBehaviour(1, registerConfig{Behaviour: "sine", Amplitude: 10, Offset: 100, Period: duration(4 * time.Second)})
+
+	for elapsed, expected := range map[time.Duration]uint16{0: 100, time.Second: 110, 3 * time.Second: 90} {
+		v, _ := b.next(elapsed, nil)
+		if got := toValue(v, modbus.TableHoldingRegisters); got != expected {
+			t.Errorf("sine at %v = %d, expected %d", elapsed, got, expected)
+		}
+	}
+
+	if _, err := newBehaviour(1, registerConfig{Behaviour: "sine"}); err == nil {
+		t.Error("expected error for sine without period, got nil")
+	}
+}
+
+func TestRandomWalk(t *testing.T) {
+	min, max := 10.0, 20.0
+	b, _ := newBehaviour(1, registerConfig{Behaviour: "random_walk", Value: 15, Step: 3, Min: &min, Max: &max})
+
+	prev := 15.0
+	for i := 0; i < 1000; i++ {
+		v, _ := b.next(0, nil)
+		if v < min || v > max {
+			t.Fatalf("random walk left its bounds: %v", v)
+		}
+		if v-prev > 3 || prev-v > 3 {
+			t.Fatalf("random walk moved by more than its step: %v -> %v", prev, v)
+		}
+		prev = v
+	}
+}
+
+func TestConstant_WritableByMaster(t *testing.T) {
+	bank := server.NewRegisterBank()
+	bank.AddRange(1, modbus.TableHoldingRegisters, 0, 1)
+
+	r, err := newRegister(1, registerConfig{Table: "holding", Value: 5})
+	if err != nil {
+		t.Fatalf("newRegister() error: %v", err)
+	}
+	r.update(0, bank)
+	if values, _ := bank.Get(1, modbus.TableHoldingRegisters, 0, 1); values[0] != 5 {
+		t.Fatalf("expected constant to start at 5, got %d", values[0])
+	}
+
+	// A master writes the register; the next tick must keep its value.
+	bank.HandleSingleWrite(&modbus.SingleWritingRequest{Header: modbus.NewModbusHeader(modbus.FCPresetSingleRegister, 1, 0), Value2Write: 9})
+	r.update(time.Second, bank)
+	if values, _ := bank.Get(1, modbus.TableHoldingRegisters, 0, 1); values[0] != 9 {
+		t.Errorf("expected the master's write to stick, got %d", values[0])
+	}
+}
+
+func TestMirror(t *testing.T) {
+	bank := server.NewRegisterBank()
+	bank.AddRange(1, modbus.TableHoldingRegisters, 0, 2)
+	bank.AddRange(2, modbus.TableCoils, 0, 1)
+	bank.Set(1, modbus.TableHoldingRegisters, 0, 0xFFFE)
+
+	source := byte(1)
+	r, err := newRegister(2, registerConfig{Table: "coil", Behaviour: "mirror", SourceUnit: &source, SourceTable: "holding"})
+	if err != nil {
+		t.Fatalf("newRegister() error: %v", err)
+	}
+	if err := r.update(0, bank); err != nil {
+		t.Fatalf("update() error: %v", err)
+	}
+	if values, _ := bank.Get(2, modbus.TableCoils, 0, 1); values[0] != 1 {
+		t.Errorf("expected mirrored coil to be 1, got %d", values[0])
+	}
+}
+
+func TestToValue(t *testing.T) {
+	tests := []struct {
+		v        float64
+		table    modbus.Table
+		expected uint16
+	}{
+		{12.4, modbus.TableHoldingRegisters, 12},
+		{-1, modbus.TableHoldingRegisters, 0xFFFF},
+		{-40000, modbus.TableInputRegisters, 0x8000},
+		{70000, modbus.TableInputRegisters, 0xFFFF},
+		{5, modbus.TableCoils, 1},
+		{0, modbus.TableDiscreteInputs, 0},
+	}
+	for _, tt := range tests {
+		if got := toValue(tt.v, tt.table); got != tt.expected {
+			t.Errorf("toValue(%v, %v) = 0x%04X, expected 0x%04X", tt.v, tt.table, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/server"
+	"os"
+	"time"
+)
+
+// config is the register map served by the simulator, as read from a JSON
+// file:
+//
+//	{
+//	  "listen": ":5020",
+//	  "tick": "500ms",
+//	  "units": [{
+//	    "id": 1,
+//	    "ranges": [{"table": "holding", "start": 0, "count": 100}],
+//	    "registers": [
+//	      {"table": "holding", "address": 0, "behaviour": "counter", "step": 1},
+//	      {"table": "holding", "address": 1, "behaviour": "sine", "amplitude": 100, "offset": 500, "period": "10s"}
+//	    ]
+//	  }]
+//	}
+type config struct {
+	Listen string       `json:"listen"`
+	Tick   duration     `json:"tick"`
+	Units  []unitConfig `json:"units"`
+}
+
+type unitConfig struct {
+	ID        byte             `json:"id"`
+	Ranges    []rangeConfig    `json:"ranges"`
+	Registers []registerConfig `json:"registers"`
+}
+
+type rangeConfig struct {
+	Table string `json:"table"`
+	Start uint16 `json:"start"`
+	Count uint16 `json:"count"`
+}
+
+// registerConfig sets the initial value and behaviour of one coil or
+// register. Which of the remaining fields apply depends on Behaviour; see
+// newBehaviour.
+type registerConfig struct {
+	Table     string `json:"table"`
+	Address   uint16 `json:"address"`
+	Behaviour string `json:"behaviour"`
+
+	Value     float64  `json:"value"`
+	Step      float64  `json:"step"`
+	Min       *float64 `json:"min"`
+	Max       *float64 `json:"max"`
+	Amplitude float64  `json:"amplitude"`
+	Offset    float64  `json:"offset"`
+	Period    duration `json:"period"`
+
+	SourceUnit    *byte  `json:"source_unit"`
+	SourceTable   string `json:"source_table"`
+	SourceAddress uint16 `json:"source_address"`
+}
+
+// duration reads a time.Duration from a string such as "250ms".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"1s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	cfg := &config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// build creates the register bank described by cfg and the behaviours that
+// drive it.
+func (cfg *config) build() (*server.RegisterBank, []*register, error) {
+	bank := server.NewRegisterBank()
+	var registers []*register
+
+	for _, unit := range cfg.Units {
+		for _, r := range unit.Ranges {
+			table, err := modbus.ParseTable(r.Table)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unit %d: %w", unit.ID, err)
+			}
+			if err := bank.AddRange(unit.ID, table, r.Start, r.Count); err != nil {
+				return nil, nil, fmt.Errorf("unit %d: %w", unit.ID, err)
+			}
+		}
+
+		for _, rc := range unit.Registers {
+			reg, err := newRegister(unit.ID, rc)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unit %d, %s %d: %w", unit.ID, rc.Table, rc.Address, err)
+			}
+			if _, err := bank.Get(reg.unitID, reg.table, reg.address, 1); err != nil {
+				return nil, nil, fmt.Errorf("unit %d, %s %d: address is not in any range", unit.ID, rc.Table, rc.Address)
+			}
+			registers = append(registers, reg)
+		}
+	}
+
+	// Mirror sources may belong to units defined further down, so they are
+	// checked once every range exists.
+	for _, reg := range registers {
+		if m, ok := reg.behaviour.(*mirror); ok {
+			if _, err := m.next(0, bank); err != nil {
+				return nil, nil, fmt.Errorf("unit %d, %v %d: %w", reg.unitID, reg.table, reg.address, err)
+			}
+		}
+	}
+
+	return bank, registers, nil
+}
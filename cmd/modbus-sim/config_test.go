@@ -0,0 +1,66 @@
+package main
+
+import (
+	"modbus_client/pkg/modbus"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig_Example(t *testing.T) {
+	cfg, err := loadConfig("example.json")
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	if cfg.Listen != ":5020" || time.Duration(cfg.Tick) != 500*time.Millisecond {
+		t.Errorf("unexpected listen/tick: %q %v", cfg.Listen, time.Duration(cfg.Tick))
+	}
+
+	bank, registers, err := cfg.build()
+	if err != nil {
+		t.Fatalf("build() error: %v", err)
+	}
+	if len(registers) != 6 {
+		t.Fatalf("expected 6 registers, got %d", len(registers))
+	}
+
+	for _, r := range registers {
+		if err := r.update(0, bank); err != nil {
+			t.Fatalf("update() error: %v", err)
+		}
+	}
+	values, _ := bank.Get(1, modbus.TableHoldingRegisters, 0, 2)
+	if !reflect.DeepEqual(values, []uint16{1234, 0}) {
+		t.Errorf("unexpected holding registers after first tick: %v", values)
+	}
+	values, _ = bank.Get(1, modbus.TableInputRegisters, 0, 1)
+	if values[0] != 500 {
+		t.Errorf("expected sine to start at its offset, got %d", values[0])
+	}
+}
+
+func TestConfigBuild_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unknown table":     `{"units": [{"id": 1, "ranges": [{"table": "eeprom", "start": 0, "count": 1}]}]}`,
+		"overlapping range": `{"units": [{"id": 1, "ranges": [{"table": "holding", "start": 0, "count": 10}, {"table": "holding", "start": 5, "count": 10}]}]}`,
+		"outside range":     `{"units": [{"id": 1, "ranges": [{"table": "holding", "start": 0, "count": 10}], "registers": [{"table": "holding", "address": 10}]}]}`,
+		"unknown behaviour": `{"units": [{"id": 1, "ranges": [{"table": "holding", "start": 0, "count": 10}], "registers": [{"table": "holding", "address": 0, "behaviour": "square"}]}]}`,
+		"missing mirror":    `{"units": [{"id": 1, "ranges": [{"table": "holding", "start": 0, "count": 10}], "registers": [{"table": "holding", "address": 0, "behaviour": "mirror", "source_table": "input"}]}]}`,
+		"bad duration":      `{"tick": 5}`,
+	}
+
+	for name, data := range tests {
+		path := filepath.Join(t.TempDir(), "config.json")
+		os.WriteFile(path, []byte(data), 0o644)
+
+		cfg, err := loadConfig(path)
+		if err == nil {
+			_, _, err = cfg.build()
+		}
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/server"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// faults holds the faults currently injected into replies. Each reply is
+// affected with probability rate; when it is, every active fault applies.
+type faults struct {
+	mu            sync.Mutex
+	delay         time.Duration
+	exception     modbus.ModbusExceptionCode
+	drop          bool
+	corruptLength bool
+	rate          float64
+	rand          *rand.Rand
+}
+
+func newFaults() *faults {
+	return &faults{
+		rate: 1,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (f *faults) String() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	exception := "off"
+	if f.exception != 0 {
+		exception = fmt.Sprintf("0x%02X", byte(f.exception))
+	}
+	return fmt.Sprintf("delay=%v exception=%s drop=%v corrupt=%v rate=%v",
+		f.delay, exception, f.drop, f.corruptLength, f.rate)
+}
+
+// apply rewrites a reply ADU according to the active faults. It returns nil
+// if the connection should be dropped instead of answering.
+func (f *faults) apply(adu []byte) []byte {
+	f.mu.Lock()
+	if f.rate < 1 && f.rand.Float64() >= f.rate {
+		f.mu.Unlock()
+		return adu
+	}
+	delay, exception, drop, corrupt := f.delay, f.exception, f.drop, f.corruptLength
+	f.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	if drop {
+		return nil
+	}
+	if exception != 0 && len(adu) >= 8 {
+		resp := &modbus.ExceptionResponse{
+			Header: modbus.ModbusHeader{SlaveID: adu[6], FC: modbus.FunctionCode(adu[7] &^ 0x80)},
+			Code:   exception,
+		}
+		frame, _ := resp.Build()
+		adu = append(adu[:6:6], frame...)
+		binary.BigEndian.PutUint16(adu[4:6], uint16(len(frame)))
+	}
+	if corrupt && len(adu) >= 6 {
+		// Claim one byte more than is sent, so the master waits for data that
+		// never comes.
+		length := binary.BigEndian.Uint16(adu[4:6])
+		binary.BigEndian.PutUint16(adu[4:6], length+1)
+	}
+	return adu
+}
+
+// faultListener hands out connections whose replies pass through faults.
+type faultListener struct {
+	net.Listener
+	faults *faults
+}
+
+func (l *faultListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &faultConn{Conn: conn, faults: l.faults}, nil
+}
+
+// faultConn relies on the server writing each reply ADU with a single Write.
+type faultConn struct {
+	net.Conn
+	faults *faults
+}
+
+func (c *faultConn) Write(b []byte) (int, error) {
+	adu := c.faults.apply(append([]byte(nil), b...))
+	if adu == nil {
+		c.Conn.Close()
+		return 0, net.ErrClosed
+	}
+	if _, err := c.Conn.Write(adu); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+const commandHelp = `commands:
+  delay <duration>         delay every reply, e.g. "delay 2s" ("delay 0" to stop)
+  exception <code>|off     answer with this exception code, e.g. "exception 6"
+  drop on|off              close the connection instead of answering
+  corrupt on|off           send replies with a wrong MBAP length
+  rate <0..1>              fraction of replies affected by the faults above
+  clear                    remove all faults
+  set <unit> <table> <address> <value>
+                           set a coil or register
+  status                   show the active faults
+  help                     show this text`
+
+// runCommands reads commands line by line from r, writing replies to w, until
+// r is exhausted.
+func runCommands(r io.Reader, w io.Writer, f *faults, bank *server.RegisterBank) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := runCommand(strings.Fields(line), f, bank); err != nil {
+			fmt.Fprintf(w, "error: %v\n", err)
+			continue
+		}
+		if line == "help" {
+			fmt.Fprintln(w, commandHelp)
+			continue
+		}
+		fmt.Fprintln(w, f)
+	}
+}
+
+func runCommand(args []string, f *faults, bank *server.RegisterBank) error {
+	// set changes the bank, not the faults, so it runs without the fault lock.
+	if args[0] == "set" {
+		return setCommand(args, bank)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch args[0] {
+	case "delay":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: delay <duration>")
+		}
+		if args[1] == "0" {
+			f.delay = 0
+			return nil
+		}
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			return err
+		}
+		f.delay = d
+	case "exception":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: exception <code>|off")
+		}
+		if args[1] == "off" {
+			f.exception = 0
+			return nil
+		}
+		code, err := strconv.ParseUint(args[1], 0, 8)
+		if err != nil || code == 0 {
+			return fmt.Errorf("invalid exception code %q", args[1])
+		}
+		f.exception = modbus.ModbusExceptionCode(code)
+	case "drop", "corrupt":
+		if len(args) != 2 || (args[1] != "on" && args[1] != "off") {
+			return fmt.Errorf("usage: %s on|off", args[0])
+		}
+		if args[0] == "drop" {
+			f.drop = args[1] == "on"
+		} else {
+			f.corruptLength = args[1] == "on"
+		}
+	case "rate":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: rate <0..1>")
+		}
+		rate, err := strconv.ParseFloat(args[1], 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return fmt.Errorf("invalid rate %q", args[1])
+		}
+		f.rate = rate
+	case "clear":
+		f.delay, f.exception, f.drop, f.corruptLength, f.rate = 0, 0, false, false, 1
+	case "status", "help":
+	default:
+		return fmt.Errorf("unknown command %q, try \"help\"", args[0])
+	}
+	return nil
+}
+
+func setCommand(args []string, bank *server.RegisterBank) error {
+	if len(args) != 5 {
+		return fmt.Errorf("usage: set <unit> <table> <address> <value>")
+	}
+	unit, err := strconv.ParseUint(args[1], 0, 8)
+	if err != nil {
+		return fmt.Errorf("invalid unit ID %q", args[1])
+	}
+	table, err := modbus.ParseTable(args[2])
+	if err != nil {
+		return err
+	}
+	address, err := strconv.ParseUint(args[3], 0, 16)
+	if err != nil {
+		return fmt.Errorf("invalid address %q", args[3])
+	}
+	value, err := strconv.ParseFloat(args[4], 64)
+	if err != nil {
+		return fmt.Errorf("invalid value %q", args[4])
+	}
+	return bank.Set(byte(unit), table, uint16(address), toValue(value, table))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/modbus_client"
+	"modbus_client/pkg/modbus/server"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startSim serves a bank with ten holding registers on unit 1 through a
+// faultListener and returns a client for it.
+func startSim(t *testing.T, f *faults) (*modbus_client.ModbusClient, func()) {
+	bank := server.NewRegisterBank()
+	bank.AddRange(1, modbus.TableHoldingRegisters, 0, 10)
+	s := server.NewServer()
+	bank.Register(s)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.Serve(&faultListener{Listener: ln, faults: f})
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	c := modbus_client.NewModbusClient("127.0.0.1", port, 300*time.Millisecond, nil)
+	return c, func() { s.Close() }
+}
+
+func TestFaults(t *testing.T) {
+	f := newFaults()
+	c, stop := startSim(t, f)
+	defer stop()
+
+	if _, err := c.ReadHoldingRegisters(1, 0, 2); err != nil {
+		t.Fatalf("ReadHoldingRegisters() without faults error: %v", err)
+	}
+
+	runCommand([]string{"exception", "6"}, f, nil)
+	if _, err := c.ReadHoldingRegisters(1, 0, 2); !errors.Is(err, modbus.ErrSlaveDeviceBusy) {
+		t.Errorf("expected ErrSlaveDeviceBusy, got %v", err)
+	}
+
+	runCommand([]string{"clear"}, f, nil)
+	runCommand([]string{"drop", "on"}, f, nil)
+	if _, err := c.ReadHoldingRegisters(1, 0, 2); err == nil {
+		t.Error("expected error on dropped connection, got nil")
+	}
+
+	runCommand([]string{"clear"}, f, nil)
+	runCommand([]string{"corrupt", "on"}, f, nil)
+	if _, err := c.ReadHoldingRegisters(1, 0, 2); err == nil {
+		t.Error("expected error on corrupted length, got nil")
+	}
+
+	runCommand([]string{"clear"}, f, nil)
+	runCommand([]string{"delay", "1s"}, f, nil)
+	start := time.Now()
+	if _, err := c.ReadHoldingRegisters(1, 0, 2); err == nil {
+		t.Error("expected timeout with delayed reply, got nil")
+	}
+	if time.Since(start) > 900*time.Millisecond {
+		t.Errorf("client waited %v, longer than its timeout", time.Since(start))
+	}
+
+	runCommand([]string{"clear"}, f, nil)
+	runCommand([]string{"exception", "2"}, f, nil)
+	runCommand([]string{"rate", "0"}, f, nil)
+	if _, err := c.ReadHoldingRegisters(1, 0, 2); err != nil {
+		t.Errorf("expected no fault at rate 0, got %v", err)
+	}
+}
+
+func TestRunCommands(t *testing.T) {
+	bank := server.NewRegisterBank()
+	bank.AddRange(1, modbus.TableHoldingRegisters, 0, 10)
+	f := newFaults()
+
+	in := strings.NewReader("delay 250ms\nexception 0x0B\nset 1 holding 3 -2\nbogus\nrate 2\n")
+	out := &bytes.Buffer{}
+	runCommands(in, out, f, bank)
+
+	if f.delay != 250*time.Millisecond || f.exception != modbus.ExceptionGatewayTargetFailedToRespond || f.rate != 1 {
+		t.Errorf("unexpected fault state: %v", f)
+	}
+	if values, _ := bank.Get(1, modbus.TableHoldingRegisters, 3, 1); values[0] != 0xFFFE {
+		t.Errorf("expected register 3 to be 0xFFFE, got 0x%04X", values[0])
+	}
+	if n := strings.Count(out.String(), "error:"); n != 2 {
+		t.Errorf("expected 2 errors in output, got %d:\n%s", n, out.String())
+	}
+}
+
+func TestRunCommand_SetWithoutFaultLock(t *testing.T) {
+	bank := server.NewRegisterBank()
+	bank.AddRange(1, modbus.TableHoldingRegisters, 0, 1)
+	f := newFaults()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	done := make(chan error, 1)
+	go func() { done <- runCommand([]string{"set", "1", "holding", "0", "7"}, f, bank) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("set error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("set waited for the fault lock")
+	}
+}
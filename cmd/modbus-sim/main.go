@@ -0,0 +1,115 @@
+// Command modbus-sim serves a simulated Modbus TCP device whose register map
+// and register behaviours are read from a JSON file (see config). Faults can
+// be injected at run time by typing commands on stdin, or by sending them to
+// the -control address, e.g. with "nc localhost 5021".
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"modbus_client/pkg/modbus/server"
+	"net"
+	"os"
+	"time"
+)
+
+func main() {
+	configPath := flag.String("config", "", "register map to serve (JSON)")
+	listen := flag.String("listen", "", "address to serve Modbus TCP on (overrides the config, default :502)")
+	control := flag.String("control", "", "address to accept fault injection commands on (stdin is always read)")
+	flag.Parse()
+
+	if *configPath == "" {
+		fmt.Fprintln(os.Stderr, "usage: modbus-sim -config <file> [-listen addr] [-control addr]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	if err := run(*configPath, *listen, *control); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(configPath, listen, control string) error {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	bank, registers, err := cfg.build()
+	if err != nil {
+		return err
+	}
+
+	if listen == "" {
+		listen = cfg.Listen
+	}
+	if listen == "" {
+		listen = ":502"
+	}
+	tick := time.Duration(cfg.Tick)
+	if tick <= 0 {
+		tick = time.Second
+	}
+
+	f := newFaults()
+	go runCommands(os.Stdin, os.Stdout, f, bank)
+	if control != "" {
+		if err := serveControl(control, f, bank); err != nil {
+			return err
+		}
+	}
+
+	go simulate(registers, bank, tick)
+
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", listen, err)
+	}
+	log.Printf("serving %d units on %s", len(cfg.Units), ln.Addr())
+
+	s := server.NewServer()
+	bank.Register(s)
+	return s.Serve(&faultListener{Listener: ln, faults: f})
+}
+
+// simulate updates every register once per tick.
+func simulate(registers []*register, bank *server.RegisterBank, tick time.Duration) {
+	start := time.Now()
+	update := func(now time.Time) {
+		for _, r := range registers {
+			if err := r.update(now.Sub(start), bank); err != nil {
+				log.Printf("unit %d, %v %d: %v", r.unitID, r.table, r.address, err)
+			}
+		}
+	}
+
+	update(start)
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		update(now)
+	}
+}
+
+func serveControl(address string, f *faults, bank *server.RegisterBank) error {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	log.Printf("accepting commands on %s", ln.Addr())
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				runCommands(conn, conn, f, bank)
+			}()
+		}
+	}()
+	return nil
+}
@@ -1,3 +1,5 @@
 module modbus_client
 
-go 1.23.3
\ No newline at end of file
+go 1.23.3
+
+require gopkg.in/yaml.v3 v3.0.1
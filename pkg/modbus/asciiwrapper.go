@@ -0,0 +1,72 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+type ASCIIRequestWrapper struct {
+	ModbusFrame []byte
+	LRC         byte
+}
+
+type ASCIIResponseWrapper struct {
+	ModbusFrame []byte
+	LRC         byte
+}
+
+// LRC computes the Modbus ASCII longitudinal redundancy check: the two's
+// complement of the 8-bit sum of data.
+func LRC(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return -sum
+}
+
+func (wrap *ASCIIRequestWrapper) Build() ([]byte, error) {
+	// modbus ascii frame:
+	// ':'
+	// modbus frame (slave ID onwards) as upper case hex
+	// LRC as upper case hex
+	// CR LF
+	wrap.LRC = LRC(wrap.ModbusFrame)
+
+	payload := append(append([]byte{}, wrap.ModbusFrame...), wrap.LRC)
+
+	frame := make([]byte, 0, 1+2*len(payload)+2)
+	frame = append(frame, ':')
+	frame = append(frame, bytes.ToUpper([]byte(hex.EncodeToString(payload)))...)
+	frame = append(frame, '\r', '\n')
+
+	return frame, nil
+}
+
+// Parse decodes a Modbus ASCII frame and checks its LRC.
+func (wrap *ASCIIResponseWrapper) Parse(adu []byte) error {
+	if len(adu) < 9 {
+		return fmt.Errorf("Modbus ASCII frame too short: expected at least 9 bytes, got %d", len(adu))
+	}
+	if adu[0] != ':' {
+		return fmt.Errorf("Modbus ASCII frame must start with ':', got 0x%02X", adu[0])
+	}
+	if !bytes.HasSuffix(adu, []byte("\r\n")) {
+		return fmt.Errorf("Modbus ASCII frame must end with CR LF")
+	}
+
+	payload, err := hex.DecodeString(string(adu[1 : len(adu)-2]))
+	if err != nil {
+		return fmt.Errorf("invalid hex in Modbus ASCII frame: %w", err)
+	}
+
+	n := len(payload) - 1
+	wrap.LRC = payload[n]
+	if lrc := LRC(payload[:n]); lrc != wrap.LRC {
+		return fmt.Errorf("LRC mismatch: computed 0x%02X, frame carries 0x%02X", lrc, wrap.LRC)
+	}
+
+	wrap.ModbusFrame = payload[:n]
+	return nil
+}
@@ -0,0 +1,51 @@
+package modbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLRC(t *testing.T) {
+	// Read Holding Registers, slave 0x11, address 0x006B, quantity 3.
+	if lrc := LRC([]byte{0x11, 0x03, 0x00, 0x6B, 0x00, 0x03}); lrc != 0x7E {
+		t.Errorf("Expected LRC 0x7E, got 0x%02X", lrc)
+	}
+}
+
+func TestASCIIRequestWrapper_Build(t *testing.T) {
+	wrap := &ASCIIRequestWrapper{
+		ModbusFrame: []byte{0x11, 0x03, 0x00, 0x6B, 0x00, 0x03},
+	}
+
+	frame, err := wrap.Build()
+	if err != nil {
+		t.Fatalf("Build() returned error: %v", err)
+	}
+
+	expected := []byte(":1103006B00037E\r\n")
+	if !reflect.DeepEqual(frame, expected) {
+		t.Errorf("Expected frame %q, got %q", expected, frame)
+	}
+}
+
+func TestASCIIResponseWrapper_Parse(t *testing.T) {
+	wrap := &ASCIIResponseWrapper{}
+	if err := wrap.Parse([]byte(":110302006486\r\n")); err != nil {
+		t.Fatalf("Parse() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(wrap.ModbusFrame, []byte{0x11, 0x03, 0x02, 0x00, 0x64}) {
+		t.Errorf("unexpected frame: %v", wrap.ModbusFrame)
+	}
+
+	tests := map[string]string{
+		"bad LRC":       ":110302006487\r\n",
+		"missing colon": "1103020064860\r\n",
+		"missing CR LF": ":11030200648600",
+		"invalid hex":   ":1103020064XX\r\n",
+	}
+	for name, adu := range tests {
+		if err := wrap.Parse([]byte(adu)); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
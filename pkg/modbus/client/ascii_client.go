@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// maxASCIIFrameLength is the longest valid ASCII frame: ':', 2 hex characters
+// for each of up to 255 frame bytes plus the LRC, and CR LF.
+const maxASCIIFrameLength = 513
+
+// ASCIIClient talks Modbus ASCII over a serial line: an ASCIIPackager over a
+// SerialTransport.
+type ASCIIClient struct {
+	*Client
+}
+
+func NewASCIIClient(port io.ReadWriter, timeout time.Duration) *ASCIIClient {
+	return &ASCIIClient{
+		Client: NewClient(NewSerialTransport(port, 0), &ASCIIPackager{}, timeout),
+	}
+}
+
+// readASCIIFrame reads from port until a complete ':' ... LF frame has been
+// received. Anything before the ':' is line noise and is dropped.
+func readASCIIFrame(port io.Reader, deadline time.Time) ([]byte, error) {
+	frame := make([]byte, 0, maxASCIIFrameLength)
+	b := make([]byte, 1)
+
+	for {
+		n, err := port.Read(b)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil, ErrTimeout
+			}
+			return nil, fmt.Errorf("failed to read ASCII response: %w", err)
+		}
+		if n == 0 {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				return nil, ErrTimeout
+			}
+			continue
+		}
+
+		switch {
+		case b[0] == ':':
+			frame = append(frame[:0], b[0])
+		case len(frame) == 0:
+			continue
+		default:
+			frame = append(frame, b[0])
+		}
+
+		if b[0] == '\n' {
+			return frame, nil
+		}
+		if len(frame) > maxASCIIFrameLength {
+			return nil, fmt.Errorf("Modbus ASCII frame exceeds %d bytes", maxASCIIFrameLength)
+		}
+	}
+}
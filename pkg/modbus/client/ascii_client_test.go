@@ -0,0 +1,75 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"modbus_client/pkg/modbus"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestASCIIClientExecute(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+
+	request := []byte(":1103006B00037E\r\n")
+	go func() {
+		req := make([]byte, len(request))
+		if _, err := io.ReadFull(device, req); err != nil {
+			return
+		}
+		// Leading noise before the ':' must be skipped.
+		device.Write([]byte("\x00\xff:11030200"))
+		device.Write([]byte("6486\r\n"))
+	}()
+
+	client := NewASCIIClient(line, time.Second)
+	response, err := client.Execute(request)
+	if err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+
+	expected := []byte(":110302006486\r\n")
+	if !reflect.DeepEqual(response, expected) {
+		t.Errorf("Expected response %q, got %q", expected, response)
+	}
+}
+
+func TestASCIIClientSend_Exception(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+
+	go func() {
+		req := make([]byte, 17)
+		if _, err := io.ReadFull(device, req); err != nil {
+			return
+		}
+		wrap := &modbus.ASCIIRequestWrapper{ModbusFrame: []byte{0x11, 0x83, 0x06}}
+		reply, _ := wrap.Build()
+		device.Write(reply)
+	}()
+
+	client := NewASCIIClient(line, time.Second)
+	_, err := client.Send([]byte{0x11, 0x03, 0x00, 0x6B, 0x00, 0x03})
+	if !errors.Is(err, modbus.ErrSlaveDeviceBusy) {
+		t.Fatalf("expected ErrSlaveDeviceBusy, got %v", err)
+	}
+}
+
+func TestASCIIClientExecute_Timeout(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+
+	go io.Copy(io.Discard, device)
+
+	client := NewASCIIClient(line, 50*time.Millisecond)
+	_, err := client.Execute([]byte(":1103006B00037E\r\n"))
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+}
@@ -1,17 +1,38 @@
 package client
 
 import (
-	"encoding/binary"
+	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"time"
 )
 
+// TCPFraming selects how a TCPClient frames requests on the socket.
+type TCPFraming int
+
+const (
+	// TCPFramingMBAP is standard Modbus TCP: an MBAP header, no checksum.
+	TCPFramingMBAP TCPFraming = iota
+	// TCPFramingRTU sends raw RTU frames (CRC, no MBAP header), as expected
+	// by transparent Ethernet-to-serial gateways.
+	TCPFramingRTU
+	// TCPFramingASCII sends Modbus ASCII frames over the socket.
+	TCPFramingASCII
+)
+
+// TCPClient talks Modbus over a TCP socket: a TCPTransport combined with the
+// Packager selected by Framing, or a pipelined connection when created with
+// NewPipelinedTCPClient.
 type TCPClient struct {
 	Host    string
 	Timeout time.Duration
 	Port    int
 	Pool    *TCPConnectionPool
+	Framing TCPFraming
+
+	mbap TCPPackager
+	mux  *tcpMux
 }
 
 func NewTCPClient(host string, timeout time.Duration, port int, pool *TCPConnectionPool) *TCPClient {
@@ -23,63 +44,100 @@ func NewTCPClient(host string, timeout time.Duration, port int, pool *TCPConnect
 	}
 }
 
-func (c *TCPClient) Execute(tcpRequest []byte) ([]byte, error) {
-
-	//conenction establishment
-	var conn net.Conn
-	var err error
-
-	address := fmt.Sprintf("%s:%d", c.Host, c.Port)
+// NewRTUOverTCPClient returns a client that exchanges RTU frames with a
+// serial gateway over TCP.
+func NewRTUOverTCPClient(host string, timeout time.Duration, port int, pool *TCPConnectionPool) *TCPClient {
+	c := NewTCPClient(host, timeout, port, pool)
+	c.Framing = TCPFramingRTU
+	return c
+}
 
-	if c.Pool != nil {
-		conn, err = c.Pool.Get()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get connection from pool: %w", err)
-		}
+// NewASCIIOverTCPClient returns a client that exchanges Modbus ASCII frames
+// with a serial gateway over TCP.
+func NewASCIIOverTCPClient(host string, timeout time.Duration, port int, pool *TCPConnectionPool) *TCPClient {
+	c := NewTCPClient(host, timeout, port, pool)
+	c.Framing = TCPFramingASCII
+	return c
+}
 
-		defer c.Pool.Put(conn)
-	} else {
-		conn, err = net.DialTimeout("tcp", address, c.Timeout)
-		if err != nil {
-			return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
-		}
-		defer conn.Close()
+// NewPipelinedTCPClient returns a client that keeps a single connection open
+// and lets up to maxInFlight requests be outstanding on it at once. Replies
+// are matched to callers by transaction ID, so concurrent Execute calls must
+// use distinct IDs (NextTransactionID provides them).
+func NewPipelinedTCPClient(host string, timeout time.Duration, port int, maxInFlight int) *TCPClient {
+	return &TCPClient{
+		Host:    host,
+		Timeout: timeout,
+		Port:    port,
+		mux:     newTCPMux(net.JoinHostPort(host, strconv.Itoa(port)), timeout, maxInFlight),
 	}
+}
 
-	conn.SetDeadline(time.Now().Add(c.Timeout))
-
-	_, err = conn.Write(tcpRequest)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+// Close releases the connection held by a pipelined client. It is a no-op
+// for clients created with NewTCPClient.
+func (c *TCPClient) Close() error {
+	if c.mux != nil {
+		return c.mux.close()
 	}
+	return nil
+}
 
-	header := make([]byte, 7)
-	n, err := conn.Read(header)
+// NextTransactionID returns the transaction ID to put in the MBAP header of
+// the next request sent through this client.
+func (c *TCPClient) NextTransactionID() uint16 {
+	return c.mbap.NextTransactionID()
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to read Modbus header: %w", err)
-	}
+// Execute sends a request in the client's Framing and returns the reply. For
+// MBAP framing the reply is the one carrying the same transaction ID.
+func (c *TCPClient) Execute(tcpRequest []byte) ([]byte, error) {
+	return c.ExecuteContext(context.Background(), tcpRequest)
+}
 
-	if n != 7 {
-		return nil, fmt.Errorf("incomplete Modbus header: expected 7 bytes, got %d", n)
+// ExecuteContext is Execute, abandoned when ctx is done: while dialing,
+// while waiting for a pooled connection or an in-flight slot, and during I/O.
+func (c *TCPClient) ExecuteContext(ctx context.Context, tcpRequest []byte) ([]byte, error) {
+	if c.Framing == TCPFramingMBAP && c.mux != nil {
+		if len(tcpRequest) < 8 {
+			return nil, fmt.Errorf("Modbus TCP request too short: %d bytes", len(tcpRequest))
+		}
+		return c.mux.execute(ctx, tcpRequest)
 	}
+	return c.client().ExecuteContext(ctx, tcpRequest)
+}
 
-	length := binary.BigEndian.Uint16(header[4:6])
-
-	remaining := int(length) - 1
-
-	pduResp := make([]byte, remaining)
-
-	totalRead := 0
+// Send frames request in the client's Framing and returns the reply frame
+// (unit ID onwards). MBAP headers carry the next transaction ID.
+func (c *TCPClient) Send(frame []byte) ([]byte, error) {
+	return c.SendContext(context.Background(), frame)
+}
 
-	for totalRead < remaining {
-		n, err = conn.Read(pduResp[totalRead:])
+// SendContext is Send, abandoned when ctx is done.
+func (c *TCPClient) SendContext(ctx context.Context, frame []byte) ([]byte, error) {
+	if c.Framing == TCPFramingMBAP && c.mux != nil {
+		tcpRequest, err := c.mbap.Encode(frame)
+		if err != nil {
+			return nil, err
+		}
+		tcpResponse, err := c.mux.execute(ctx, tcpRequest)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read PDU: %w", err)
+			return nil, err
 		}
-		totalRead += n
+		return c.mbap.Decode(tcpRequest, tcpResponse)
+	}
+	return c.client().SendContext(ctx, frame)
+}
+
+// client assembles the Packager and Transport matching the client's current
+// fields, so that they can still be changed after construction.
+func (c *TCPClient) client() *Client {
+	var packager Packager = &c.mbap
+	switch c.Framing {
+	case TCPFramingRTU:
+		packager = &RTUPackager{}
+	case TCPFramingASCII:
+		packager = &ASCIIPackager{}
 	}
 
-	response := append(header, pduResp...)
-	return response, nil
+	return NewClient(NewTCPTransport(c.Host, c.Port, c.Timeout, c.Pool), packager, c.Timeout)
 }
@@ -1,7 +1,13 @@
 package client
 
 import (
+	"context"
+	"errors"
+	"io"
+	"modbus_client/pkg/modbus"
 	"net"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -23,13 +29,13 @@ func TestTCPClientExecute(t *testing.T) {
 	//   Length:         0x00, 0x05  (1 byte for Unit ID + 4 bytes for PDU)
 	//   Unit Identifier: 0x01
 	// PDU:
-	//   Data bytes: 0xAA, 0xBB, 0xCC, 0xDD
+	//   Function code: 0x03, Byte count: 0x02, Data bytes: 0xCC, 0xDD
 	expectedResponse := []byte{
 		0x00, 0x02, // Transaction ID
 		0x00, 0x00, // Protocol ID
 		0x00, 0x05, // Length
 		0x01,                   // Unit Identifier
-		0xAA, 0xBB, 0xCC, 0xDD, // PDU bytes
+		0x03, 0x02, 0xCC, 0xDD, // PDU bytes
 	}
 
 	// Start a goroutine to simulate the Modbus TCP server.
@@ -61,8 +67,8 @@ func TestTCPClientExecute(t *testing.T) {
 	// Create a TCPClient that will connect to our temporary server.
 	client := NewTCPClient("127.0.0.1", 5*time.Second, port, nil)
 
-	// Create a dummy request (the content isn't important for this test).
-	dummyRequest := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x02}
+	// Create a request whose transaction ID matches the simulated response.
+	dummyRequest := []byte{0x00, 0x02, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x00, 0x00, 0x02}
 
 	// Execute the request.
 	response, err := client.Execute(dummyRequest)
@@ -84,3 +90,240 @@ func TestTCPClientExecute(t *testing.T) {
 	// Wait for the server goroutine to finish.
 	<-done
 }
+
+// TestTCPClientExecute_Exception checks that an exception reply is returned
+// as a *ModbusException instead of response data.
+func TestTCPClientExecute_Exception(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reqBuf := make([]byte, 1024)
+		_, _ = conn.Read(reqBuf)
+
+		// Read Holding Registers exception: Illegal Data Address.
+		_, _ = conn.Write([]byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x03, 0x01, 0x83, 0x02})
+	}()
+
+	client := NewTCPClient("127.0.0.1", 5*time.Second, ln.Addr().(*net.TCPAddr).Port, nil)
+
+	_, err = client.Execute([]byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x00, 0x00, 0x02})
+	if !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Fatalf("expected ErrIllegalDataAddress, got %v", err)
+	}
+
+	var exc *modbus.ModbusException
+	if !errors.As(err, &exc) {
+		t.Fatalf("expected *ModbusException, got %T", err)
+	}
+}
+
+// TestTCPClientExecute_TransactionID checks that replies carrying a stale
+// transaction ID are skipped and that a protocol ID mismatch is rejected.
+func TestTCPClientExecute_TransactionID(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			reqBuf := make([]byte, 12)
+			_, _ = io.ReadFull(conn, reqBuf)
+
+			// A stale reply to an earlier transaction, then the real one.
+			_, _ = conn.Write([]byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x05, 0x01, 0x03, 0x02, 0x00, 0x01})
+			_, _ = conn.Write([]byte{reqBuf[0], reqBuf[1], 0x00, 0x01, 0x00, 0x05, 0x01, 0x03, 0x02, 0x00, 0x02})
+			conn.Close()
+		}
+	}()
+
+	client := NewTCPClient("127.0.0.1", 5*time.Second, ln.Addr().(*net.TCPAddr).Port, nil)
+
+	tid := client.NextTransactionID()
+	if next := client.NextTransactionID(); next != tid+1 {
+		t.Fatalf("expected transaction IDs to increase, got %d then %d", tid, next)
+	}
+
+	// The real reply above carries protocol ID 0x0001, so it must be rejected
+	// once the stale reply has been skipped.
+	request := []byte{0x00, 0x07, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x00, 0x00, 0x01}
+	_, err = client.Execute(request)
+	if err == nil || !strings.Contains(err.Error(), "protocol ID mismatch") {
+		t.Fatalf("expected protocol ID mismatch error, got %v", err)
+	}
+
+	// With a matching protocol ID the stale reply is skipped and the matching
+	// one returned.
+	request = []byte{0x00, 0x08, 0x00, 0x01, 0x00, 0x06, 0x01, 0x03, 0x00, 0x00, 0x00, 0x01}
+	response, err := client.Execute(request)
+	if err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	if response[0] != 0x00 || response[1] != 0x08 || response[10] != 0x02 {
+		t.Errorf("expected reply to transaction 8, got % X", response)
+	}
+}
+
+// startRTUGateway simulates an Ethernet-to-serial gateway that passes RTU
+// frames through unchanged. Each 8-byte request is answered with reply,
+// split in two writes to exercise length detection.
+func startRTUGateway(t *testing.T, reply []byte) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				for {
+					req := make([]byte, 8)
+					if _, err := io.ReadFull(conn, req); err != nil {
+						return
+					}
+					conn.Write(reply[:3])
+					time.Sleep(10 * time.Millisecond)
+					conn.Write(reply[3:])
+				}
+			}(conn)
+		}
+	}()
+
+	return ln
+}
+
+func TestRTUOverTCPClientSend(t *testing.T) {
+	reply := withCRC([]byte{0x01, 0x03, 0x04, 0x00, 0x0A, 0x00, 0x0B})
+	ln := startRTUGateway(t, reply)
+	defer ln.Close()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	pool := NewTCPConnectionPool(addr.String(), time.Second, 1)
+	defer pool.Close()
+
+	client := NewRTUOverTCPClient("127.0.0.1", time.Second, addr.Port, pool)
+
+	// Two calls in a row also check that the pooled connection is reused.
+	for i := 0; i < 2; i++ {
+		frame, err := client.Send([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x02})
+		if err != nil {
+			t.Fatalf("Send() error: %v", err)
+		}
+		expected := []byte{0x01, 0x03, 0x04, 0x00, 0x0A, 0x00, 0x0B}
+		if !reflect.DeepEqual(frame, expected) {
+			t.Errorf("Expected frame %v, got %v", expected, frame)
+		}
+	}
+}
+
+func TestRTUOverTCPClientSend_BadCRCClosesConnection(t *testing.T) {
+	reply := withCRC([]byte{0x01, 0x06, 0x00, 0x01, 0x00, 0x03})
+	reply[7] ^= 0xFF
+	ln := startRTUGateway(t, reply)
+	defer ln.Close()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	pool := NewTCPConnectionPool(addr.String(), time.Second, 1)
+	defer pool.Close()
+
+	client := NewRTUOverTCPClient("127.0.0.1", time.Second, addr.Port, pool)
+	if _, err := client.Send([]byte{0x01, 0x06, 0x00, 0x01, 0x00, 0x03}); err == nil {
+		t.Fatal("expected CRC error, got nil")
+	}
+	if len(pool.pool) != 0 {
+		t.Error("expected connection with a failed transaction not to be returned to the pool")
+	}
+}
+
+func TestASCIIOverTCPClientSend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req := make([]byte, 17)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		conn.Write([]byte(":110302006486\r\n"))
+	}()
+
+	client := NewASCIIOverTCPClient("127.0.0.1", time.Second, ln.Addr().(*net.TCPAddr).Port, nil)
+	frame, err := client.Send([]byte{0x11, 0x03, 0x00, 0x6B, 0x00, 0x01})
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	expected := []byte{0x11, 0x03, 0x02, 0x00, 0x64}
+	if !reflect.DeepEqual(frame, expected) {
+		t.Errorf("Expected frame %v, got %v", expected, frame)
+	}
+}
+
+// TestTCPClientExecuteContext_Cancel checks that cancelling the context
+// interrupts a transaction with a device that never answers.
+func TestTCPClientExecuteContext_Cancel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	client := NewTCPClient("127.0.0.1", 10*time.Second, addr.Port, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	request := []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x00, 0x00, 0x01}
+	_, err = client.ExecuteContext(ctx, request)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cancellation took %v", elapsed)
+	}
+
+	// A context deadline shorter than Timeout bounds the wait as well.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.ExecuteContext(ctx, request); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"modbus_client/pkg/modbus"
+	"sync/atomic"
+	"time"
+)
+
+// Packager converts between Modbus frames (slave ID, function code and data)
+// and the application data units of one wire format: MBAP for Modbus TCP,
+// CRC-terminated RTU frames or ':'-delimited ASCII frames. It knows nothing
+// about how the bytes are carried; that is the Transport's job.
+type Packager interface {
+	// Encode wraps frame into a request ADU.
+	Encode(frame []byte) ([]byte, error)
+	// ReadResponse reads the reply to aduRequest from r, giving up once
+	// deadline has passed. It returns a nil ADU if aduRequest expects no reply
+	// (an RTU or ASCII broadcast). Replies belonging to other requests are
+	// skipped where the format allows telling them apart.
+	ReadResponse(r io.Reader, aduRequest []byte, deadline time.Time) ([]byte, error)
+	// Decode verifies aduResponse against aduRequest and returns the reply
+	// frame. Exception replies are returned as *modbus.ModbusException.
+	Decode(aduRequest, aduResponse []byte) ([]byte, error)
+}
+
+var (
+	_ Packager = (*TCPPackager)(nil)
+	_ Packager = (*RTUPackager)(nil)
+	_ Packager = (*ASCIIPackager)(nil)
+)
+
+// TCPPackager frames requests with an MBAP header. Each Encode call uses the
+// next value of the packager's transaction counter.
+type TCPPackager struct {
+	transactionID atomic.Uint32
+}
+
+// NextTransactionID returns the transaction ID to put in the MBAP header of
+// the next request.
+func (p *TCPPackager) NextTransactionID() uint16 {
+	return uint16(p.transactionID.Add(1))
+}
+
+func (p *TCPPackager) Encode(frame []byte) ([]byte, error) {
+	wrap := &modbus.TCPRequestWrapper{
+		TransactionID: p.NextTransactionID(),
+		ModbusFrame:   frame,
+	}
+	return wrap.Build()
+}
+
+// ReadResponse skips replies carrying another transaction ID: they answer
+// earlier requests that timed out and are still queued on a reused
+// connection.
+func (p *TCPPackager) ReadResponse(r io.Reader, tcpRequest []byte, deadline time.Time) ([]byte, error) {
+	if len(tcpRequest) < 8 {
+		return nil, fmt.Errorf("Modbus TCP request too short: %d bytes", len(tcpRequest))
+	}
+
+	for {
+		response, err := readTCPResponse(r, deadline)
+		if err != nil {
+			return nil, err
+		}
+		if response[0] == tcpRequest[0] && response[1] == tcpRequest[1] {
+			return response, nil
+		}
+	}
+}
+
+func (p *TCPPackager) Decode(tcpRequest, tcpResponse []byte) ([]byte, error) {
+	if _, err := checkTCPResponse(tcpRequest, tcpResponse); err != nil {
+		return nil, err
+	}
+
+	wrap := &modbus.TCPResponseWrapper{}
+	if err := wrap.Parse(tcpResponse); err != nil {
+		return nil, err
+	}
+	return wrap.ModbusFrame, nil
+}
+
+// readTCPResponse reads one complete Modbus TCP frame (MBAP header included).
+func readTCPResponse(r io.Reader, deadline time.Time) ([]byte, error) {
+	header := make([]byte, 7)
+	if err := readFullBefore(r, header, deadline); err != nil {
+		return nil, fmt.Errorf("failed to read Modbus header: %w", err)
+	}
+
+	length := binary.BigEndian.Uint16(header[4:6])
+	if length < 2 {
+		return nil, fmt.Errorf("invalid Modbus header length: %d", length)
+	}
+
+	pduResp := make([]byte, int(length)-1)
+	if err := readFullBefore(r, pduResp, deadline); err != nil {
+		return nil, fmt.Errorf("failed to read PDU: %w", err)
+	}
+
+	return append(header, pduResp...), nil
+}
+
+// checkTCPResponse validates the protocol ID of a reply whose transaction ID
+// already matched tcpRequest and turns exception replies into errors.
+func checkTCPResponse(tcpRequest, response []byte) ([]byte, error) {
+	if response[2] != tcpRequest[2] || response[3] != tcpRequest[3] {
+		return nil, fmt.Errorf("protocol ID mismatch: expected 0x%02X%02X, got 0x%02X%02X",
+			tcpRequest[2], tcpRequest[3], response[2], response[3])
+	}
+
+	if err := modbus.CheckException(response[6:]); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// RTUPackager frames requests as Modbus RTU: the frame followed by its CRC.
+type RTUPackager struct{}
+
+func (p *RTUPackager) Encode(frame []byte) ([]byte, error) {
+	wrap := &modbus.RTURequestWrapper{ModbusFrame: frame}
+	return wrap.Build()
+}
+
+// ReadResponse sizes the reply from its function code and byte count, or
+// object list, since RTU frames carry no length field.
+func (p *RTUPackager) ReadResponse(r io.Reader, rtuRequest []byte, deadline time.Time) ([]byte, error) {
+	if len(rtuRequest) < 4 {
+		return nil, fmt.Errorf("Modbus RTU request too short: %d bytes", len(rtuRequest))
+	}
+
+	// A broadcast (slave ID 0) is never answered.
+	if rtuRequest[0] == 0 {
+		return nil, nil
+	}
+
+	response := make([]byte, 3)
+	if err := readFullBefore(r, response, deadline); err != nil {
+		return nil, fmt.Errorf("failed to read RTU response: %w", err)
+	}
+
+	for {
+		n, err := modbus.RTUResponseRemaining(response)
+		if err != nil {
+			return nil, err
+		}
+		if response[1] == byte(modbus.FCDiagnostics) {
+			// Diagnostics replies are as long as the request they answer.
+			n = len(rtuRequest) - len(response)
+		}
+		if n <= 0 {
+			return response, nil
+		}
+
+		more := make([]byte, n)
+		if err := readFullBefore(r, more, deadline); err != nil {
+			return nil, fmt.Errorf("failed to read RTU response: %w", err)
+		}
+		response = append(response, more...)
+	}
+}
+
+func (p *RTUPackager) Decode(rtuRequest, rtuResponse []byte) ([]byte, error) {
+	wrap := &modbus.RTUResponseWrapper{}
+	if err := wrap.Parse(rtuResponse); err != nil {
+		return nil, err
+	}
+	if rtuResponse[0] != rtuRequest[0] {
+		return nil, fmt.Errorf("SlaveID mismatch: expected 0x%02X, got 0x%02X", rtuRequest[0], rtuResponse[0])
+	}
+
+	if err := modbus.CheckException(wrap.ModbusFrame); err != nil {
+		return nil, err
+	}
+
+	return wrap.ModbusFrame, nil
+}
+
+// ASCIIPackager frames requests as Modbus ASCII: ':', the hex-encoded frame
+// and LRC, then CR LF.
+type ASCIIPackager struct{}
+
+func (p *ASCIIPackager) Encode(frame []byte) ([]byte, error) {
+	wrap := &modbus.ASCIIRequestWrapper{ModbusFrame: frame}
+	return wrap.Build()
+}
+
+func (p *ASCIIPackager) ReadResponse(r io.Reader, asciiRequest []byte, deadline time.Time) ([]byte, error) {
+	// A broadcast (slave ID "00") is never answered.
+	if len(asciiRequest) >= 3 && string(asciiRequest[1:3]) == "00" {
+		return nil, nil
+	}
+	return readASCIIFrame(r, deadline)
+}
+
+func (p *ASCIIPackager) Decode(asciiRequest, asciiResponse []byte) ([]byte, error) {
+	req := &modbus.ASCIIResponseWrapper{}
+	if err := req.Parse(asciiRequest); err != nil {
+		return nil, fmt.Errorf("invalid Modbus ASCII request: %w", err)
+	}
+
+	wrap := &modbus.ASCIIResponseWrapper{}
+	if err := wrap.Parse(asciiResponse); err != nil {
+		return nil, err
+	}
+	if wrap.ModbusFrame[0] != req.ModbusFrame[0] {
+		return nil, fmt.Errorf("SlaveID mismatch: expected 0x%02X, got 0x%02X", req.ModbusFrame[0], wrap.ModbusFrame[0])
+	}
+
+	if err := modbus.CheckException(wrap.ModbusFrame); err != nil {
+		return nil, err
+	}
+
+	return wrap.ModbusFrame, nil
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"modbus_client/pkg/modbus"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTCPPackager_EncodeDecode(t *testing.T) {
+	p := &TCPPackager{}
+	frame := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01}
+
+	first, err := p.Encode(frame)
+	if err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+	second, _ := p.Encode(frame)
+	if first[1] != 0x01 || second[1] != 0x02 {
+		t.Errorf("expected consecutive transaction IDs 1 and 2, got % X and % X", first[:2], second[:2])
+	}
+
+	// A stale reply to the first request is queued before the reply to the
+	// second; ReadResponse must skip it.
+	stream := bytes.NewBuffer([]byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x05, 0x01, 0x03, 0x02, 0x00, 0x01})
+	stream.Write([]byte{0x00, 0x02, 0x00, 0x00, 0x00, 0x05, 0x01, 0x03, 0x02, 0x00, 0x02})
+
+	response, err := p.ReadResponse(stream, second, time.Time{})
+	if err != nil {
+		t.Fatalf("ReadResponse() error: %v", err)
+	}
+	respFrame, err := p.Decode(second, response)
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if !reflect.DeepEqual(respFrame, []byte{0x01, 0x03, 0x02, 0x00, 0x02}) {
+		t.Errorf("unexpected reply frame: %v", respFrame)
+	}
+}
+
+func TestRTUPackager_ReadResponse(t *testing.T) {
+	p := &RTUPackager{}
+	request, _ := p.Encode([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x02})
+
+	reply := withCRC([]byte{0x01, 0x03, 0x04, 0x00, 0x0A, 0x00, 0x0B})
+	// Trailing bytes belong to the next frame and must not be consumed.
+	stream := bytes.NewBuffer(append(append([]byte{}, reply...), 0xFF))
+
+	response, err := p.ReadResponse(stream, request, time.Time{})
+	if err != nil {
+		t.Fatalf("ReadResponse() error: %v", err)
+	}
+	if !reflect.DeepEqual(response, reply) || stream.Len() != 1 {
+		t.Errorf("expected exactly %v to be read, got %v", reply, response)
+	}
+
+	if _, err := p.Decode(request, withCRC([]byte{0x02, 0x03, 0x02, 0x00, 0x0A})); err == nil {
+		t.Error("expected SlaveID mismatch error, got nil")
+	}
+
+	// Broadcasts are never answered.
+	broadcast, _ := p.Encode([]byte{0x00, 0x06, 0x00, 0x01, 0x00, 0x03})
+	if response, err := p.ReadResponse(stream, broadcast, time.Time{}); response != nil || err != nil {
+		t.Errorf("expected no response to broadcast, got %v, %v", response, err)
+	}
+}
+
+func TestASCIIPackager_Decode(t *testing.T) {
+	p := &ASCIIPackager{}
+	request, _ := p.Encode([]byte{0x11, 0x03, 0x00, 0x6B, 0x00, 0x01})
+
+	response, err := p.ReadResponse(bytes.NewBufferString(":1183026A\r\n"), request, time.Time{})
+	if err != nil {
+		t.Fatalf("ReadResponse() error: %v", err)
+	}
+	if _, err := p.Decode(request, response); !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Errorf("expected ErrIllegalDataAddress, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// RTUClient talks Modbus RTU over a serial line: an RTUPackager over a
+// SerialTransport.
+type RTUClient struct {
+	*Client
+}
+
+func NewRTUClient(port io.ReadWriter, baudRate int, timeout time.Duration) *RTUClient {
+	return &RTUClient{
+		Client: NewClient(NewSerialTransport(port, baudRate), &RTUPackager{}, timeout),
+	}
+}
+
+// rtuCharTime is the time taken to transmit one RTU character: start bit,
+// 8 data bits, parity (or second stop) bit and stop bit.
+func rtuCharTime(baudRate int) time.Duration {
+	if baudRate <= 0 {
+		baudRate = 9600
+	}
+	return time.Duration(11 * int64(time.Second) / int64(baudRate))
+}
+
+// rtuFrameDelay returns the 3.5 character silence that separates RTU frames.
+// Above 19200 baud the spec fixes it at 1.75ms.
+func rtuFrameDelay(baudRate int) time.Duration {
+	if baudRate > 19200 {
+		return 1750 * time.Microsecond
+	}
+	return rtuCharTime(baudRate) * 7 / 2
+}
+
+// readFullBefore fills buf from r, giving up once deadline (if set) has
+// passed. Readers without deadline support are expected to return (0, nil)
+// when their own read timeout expires.
+func readFullBefore(r io.Reader, buf []byte, deadline time.Time) error {
+	for total := 0; total < len(buf); {
+		n, err := r.Read(buf[total:])
+		total += n
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return ErrTimeout
+			}
+			return err
+		}
+		if n == 0 && !deadline.IsZero() && time.Now().After(deadline) {
+			return ErrTimeout
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"modbus_client/pkg/modbus"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func withCRC(frame []byte) []byte {
+	wrap := &modbus.RTURequestWrapper{ModbusFrame: frame}
+	adu, _ := wrap.Build()
+	return adu
+}
+
+// serveRTU reads one 8-byte request from the device end of the pipe and
+// writes reply back, possibly in several chunks.
+func serveRTU(device net.Conn, reply ...[]byte) {
+	go func() {
+		req := make([]byte, 8)
+		if _, err := io.ReadFull(device, req); err != nil {
+			return
+		}
+		for _, chunk := range reply {
+			if _, err := device.Write(chunk); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func TestRTUClientExecute(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+
+	response := withCRC([]byte{0x01, 0x03, 0x04, 0x00, 0x06, 0x00, 0x05})
+	serveRTU(device, response[:4], response[4:])
+
+	client := NewRTUClient(line, 19200, time.Second)
+	got, err := client.Execute(withCRC([]byte{0x01, 0x03, 0x00, 0x6B, 0x00, 0x02}))
+	if err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	if !reflect.DeepEqual(got, response) {
+		t.Errorf("Expected response %v, got %v", response, got)
+	}
+}
+
+func TestRTUClientExecute_Exception(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+
+	serveRTU(device, withCRC([]byte{0x01, 0x83, 0x02}))
+
+	client := NewRTUClient(line, 9600, time.Second)
+	_, err := client.Execute(withCRC([]byte{0x01, 0x03, 0x00, 0x6B, 0x00, 0x02}))
+	if !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Fatalf("expected ErrIllegalDataAddress, got %v", err)
+	}
+}
+
+func TestRTUClientExecute_BadCRC(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+
+	response := withCRC([]byte{0x01, 0x06, 0x00, 0x01, 0x00, 0x03})
+	response[len(response)-1] ^= 0xFF
+	serveRTU(device, response)
+
+	client := NewRTUClient(line, 9600, time.Second)
+	if _, err := client.Execute(withCRC([]byte{0x01, 0x06, 0x00, 0x01, 0x00, 0x03})); err == nil {
+		t.Fatal("expected CRC error, got nil")
+	}
+}
+
+func TestRTUClientExecute_Timeout(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+
+	// The slave swallows the request and never answers.
+	serveRTU(device)
+
+	client := NewRTUClient(line, 115200, 50*time.Millisecond)
+	_, err := client.Execute(withCRC([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01}))
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+}
+
+func TestRTUFrameDelay(t *testing.T) {
+	// 3.5 characters of 11 bits at 9600 baud.
+	if d := rtuFrameDelay(9600); d < 4000*time.Microsecond || d > 4020*time.Microsecond {
+		t.Errorf("unexpected frame delay at 9600 baud: %v", d)
+	}
+	if d := rtuFrameDelay(115200); d != 1750*time.Microsecond {
+		t.Errorf("expected fixed 1.75ms frame delay above 19200 baud, got %v", d)
+	}
+}
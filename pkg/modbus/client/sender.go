@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTimeout is returned when a device does not answer within the client
+// timeout, whatever the framing and transport.
+var ErrTimeout = errors.New("timed out waiting for response")
+
+// Sender is the framing-independent view of a client: it takes a Modbus frame
+// (slave ID, function code and data, as built by the request types in package
+// modbus), adds whatever header or checksum the wire format needs, executes it
+// and returns the reply frame in the same form. A nil reply with a nil error
+// means the request was a broadcast that no slave answers. SendContext is the
+// same, abandoned when ctx is done.
+type Sender interface {
+	Send(frame []byte) ([]byte, error)
+	SendContext(ctx context.Context, frame []byte) ([]byte, error)
+}
+
+var (
+	_ Sender = (*Client)(nil)
+	_ Sender = (*TCPClient)(nil)
+	_ Sender = (*RTUClient)(nil)
+	_ Sender = (*ASCIIClient)(nil)
+	_ Sender = (*UDPClient)(nil)
+)
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"sync"
@@ -25,11 +26,22 @@ func NewTCPConnectionPool(address string, timeout time.Duration, maxConnections
 }
 
 func (p *TCPConnectionPool) Get() (net.Conn, error) {
+	return p.GetContext(context.Background())
+}
+
+// GetContext returns an idle connection, or dials a new one. The dial is
+// abandoned if ctx is done first.
+func (p *TCPConnectionPool) GetContext(ctx context.Context) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	select {
 	case conn := <-p.pool:
 		return conn, nil
 	default:
-		conn, err := net.DialTimeout("tcp", p.address, p.timeout)
+		dialer := &net.Dialer{Timeout: p.timeout}
+		conn, err := dialer.DialContext(ctx, "tcp", p.address)
 		if err != nil {
 			return nil, fmt.Errorf("failed to establish connection to %s: %w", p.address, err)
 		}
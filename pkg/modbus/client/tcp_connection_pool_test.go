@@ -1,6 +1,8 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"net"
 	"testing"
 	"time"
@@ -113,3 +115,20 @@ func TestTCPConnectionPool_Close(t *testing.T) {
 		t.Errorf("expected new connection to be closed after pool.Put on a closed pool")
 	}
 }
+
+func TestTCPConnectionPool_GetContextCancelled(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	pool := NewTCPConnectionPool(ln.Addr().String(), 2*time.Second, 1)
+	defer pool.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.GetContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+var errMuxClosed = errors.New("pipelined connection closed")
+
+type muxResult struct {
+	response []byte
+	err      error
+}
+
+// tcpMux multiplexes concurrent transactions over a single TCP connection.
+// Requests are written as soon as an in-flight slot is free; one reader
+// goroutine per connection hands each reply to the caller waiting on its
+// transaction ID.
+type tcpMux struct {
+	address string
+	timeout time.Duration
+	slots   chan struct{}
+	dial    func(ctx context.Context, network, address string) (net.Conn, error)
+
+	mu      sync.Mutex
+	conn    net.Conn
+	pending map[uint16]chan muxResult
+	closed  bool
+	// dialing is closed when the dial in progress, if any, ends. Dials
+	// happen outside mu so that a slow one does not block other callers.
+	dialing chan struct{}
+}
+
+func newTCPMux(address string, timeout time.Duration, maxInFlight int) *tcpMux {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	return &tcpMux{
+		address: address,
+		timeout: timeout,
+		slots:   make(chan struct{}, maxInFlight),
+		dial:    (&net.Dialer{Timeout: timeout}).DialContext,
+		pending: make(map[uint16]chan muxResult),
+	}
+}
+
+func (m *tcpMux) execute(ctx context.Context, tcpRequest []byte) ([]byte, error) {
+	timer := time.NewTimer(m.timeout)
+	defer timer.Stop()
+
+	select {
+	case m.slots <- struct{}{}:
+		defer func() { <-m.slots }()
+	case <-timer.C:
+		return nil, fmt.Errorf("no in-flight slot free on %s: %w", m.address, ErrTimeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	tid := uint16(tcpRequest[0])<<8 | uint16(tcpRequest[1])
+	result, err := m.send(ctx, tid, tcpRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// An abandoned transaction leaves the connection usable: its reply, if it
+	// ever comes, is dropped by the reader.
+	select {
+	case res := <-result:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return checkTCPResponse(tcpRequest, res.response)
+	case <-timer.C:
+		m.abandon(tid, result)
+		return nil, fmt.Errorf("transaction %d: %w", tid, ErrTimeout)
+	case <-ctx.Done():
+		m.abandon(tid, result)
+		return nil, ctx.Err()
+	}
+}
+
+func (m *tcpMux) abandon(tid uint16, result chan muxResult) {
+	m.mu.Lock()
+	if m.pending[tid] == result {
+		delete(m.pending, tid)
+	}
+	m.mu.Unlock()
+}
+
+// send registers tid as pending and writes the request, dialing first if no
+// connection is open.
+func (m *tcpMux) send(ctx context.Context, tid uint16, tcpRequest []byte) (chan muxResult, error) {
+	for {
+		conn, err := m.connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		m.mu.Lock()
+		if m.conn != conn {
+			// Lost since connect returned; dial again.
+			m.mu.Unlock()
+			continue
+		}
+		result, err := m.writeLocked(conn, tid, tcpRequest)
+		m.mu.Unlock()
+		return result, err
+	}
+}
+
+func (m *tcpMux) writeLocked(conn net.Conn, tid uint16, tcpRequest []byte) (chan muxResult, error) {
+	if _, busy := m.pending[tid]; busy {
+		return nil, fmt.Errorf("transaction %d is already in flight", tid)
+	}
+
+	result := make(chan muxResult, 1)
+	m.pending[tid] = result
+
+	conn.SetWriteDeadline(time.Now().Add(m.timeout))
+	if _, err := conn.Write(tcpRequest); err != nil {
+		m.failLocked(conn, fmt.Errorf("failed to send request: %w", err))
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	return result, nil
+}
+
+// connect returns the open connection, dialing one if there is none. Callers
+// arriving during a dial wait for it rather than dialing too.
+func (m *tcpMux) connect(ctx context.Context) (net.Conn, error) {
+	for {
+		m.mu.Lock()
+		if m.closed {
+			m.mu.Unlock()
+			return nil, errMuxClosed
+		}
+		if m.conn != nil {
+			conn := m.conn
+			m.mu.Unlock()
+			return conn, nil
+		}
+		if wait := m.dialing; wait != nil {
+			m.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		m.dialing = done
+		m.mu.Unlock()
+
+		conn, err := m.dial(ctx, "tcp", m.address)
+
+		m.mu.Lock()
+		m.dialing = nil
+		close(done)
+		if err != nil {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("failed to connect to %s: %w", m.address, err)
+		}
+		if m.closed {
+			m.mu.Unlock()
+			conn.Close()
+			return nil, errMuxClosed
+		}
+		m.conn = conn
+		go m.readLoop(conn)
+		m.mu.Unlock()
+		return conn, nil
+	}
+}
+
+func (m *tcpMux) readLoop(conn net.Conn) {
+	for {
+		response, err := readTCPResponse(conn, time.Time{})
+		if err != nil {
+			m.mu.Lock()
+			m.failLocked(conn, err)
+			m.mu.Unlock()
+			return
+		}
+
+		tid := uint16(response[0])<<8 | uint16(response[1])
+
+		m.mu.Lock()
+		result, ok := m.pending[tid]
+		delete(m.pending, tid)
+		m.mu.Unlock()
+
+		// Replies nobody is waiting for (e.g. the caller timed out) are dropped.
+		if ok {
+			result <- muxResult{response: response}
+		}
+	}
+}
+
+// failLocked closes conn and fails every pending transaction, if conn is still
+// the active connection. The next request dials a fresh one.
+func (m *tcpMux) failLocked(conn net.Conn, err error) {
+	if m.conn != conn {
+		return
+	}
+	conn.Close()
+	m.conn = nil
+
+	for tid, result := range m.pending {
+		result <- muxResult{err: err}
+		delete(m.pending, tid)
+	}
+}
+
+func (m *tcpMux) close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	if m.conn != nil {
+		m.failLocked(m.conn, errMuxClosed)
+	}
+	return nil
+}
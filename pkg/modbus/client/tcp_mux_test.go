@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startPipelinedServer accepts one connection, waits until batch requests have
+// arrived and answers them in reverse order, echoing each request's unit ID
+// and register address back as the register value.
+func startPipelinedServer(t *testing.T, batch int) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				for {
+					var requests [][]byte
+					for len(requests) < batch {
+						req := make([]byte, 12)
+						if _, err := io.ReadFull(conn, req); err != nil {
+							return
+						}
+						requests = append(requests, req)
+					}
+					for i := len(requests) - 1; i >= 0; i-- {
+						req := requests[i]
+						reply := []byte{req[0], req[1], 0x00, 0x00, 0x00, 0x05, req[6], req[7], 0x02, req[8], req[9]}
+						if _, err := conn.Write(reply); err != nil {
+							return
+						}
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return ln
+}
+
+func TestPipelinedTCPClient_OutOfOrderReplies(t *testing.T) {
+	const inFlight = 4
+	ln := startPipelinedServer(t, inFlight)
+	defer ln.Close()
+
+	client := NewPipelinedTCPClient("127.0.0.1", 2*time.Second, ln.Addr().(*net.TCPAddr).Port, inFlight)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2*inFlight; i++ {
+		wg.Add(1)
+		go func(address uint16) {
+			defer wg.Done()
+
+			request := []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x00, 0x00, 0x01}
+			binary.BigEndian.PutUint16(request[0:2], client.NextTransactionID())
+			binary.BigEndian.PutUint16(request[8:10], address)
+
+			response, err := client.Execute(request)
+			if err != nil {
+				t.Errorf("Execute() error for address %d: %v", address, err)
+				return
+			}
+			if got := binary.BigEndian.Uint16(response[9:11]); got != address {
+				t.Errorf("reply routed to wrong caller: expected %d, got %d", address, got)
+			}
+		}(uint16(i))
+	}
+	wg.Wait()
+}
+
+func TestPipelinedTCPClient_Reconnect(t *testing.T) {
+	ln := startPipelinedServer(t, 1)
+	defer ln.Close()
+
+	client := NewPipelinedTCPClient("127.0.0.1", 2*time.Second, ln.Addr().(*net.TCPAddr).Port, 2)
+	defer client.Close()
+
+	request := func() []byte {
+		req := []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x07, 0x00, 0x01}
+		binary.BigEndian.PutUint16(req[0:2], client.NextTransactionID())
+		return req
+	}
+
+	if _, err := client.Execute(request()); err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+
+	// Drop the connection under the client; the next call must dial again.
+	client.mux.mu.Lock()
+	client.mux.conn.Close()
+	client.mux.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+
+	if _, err := client.Execute(request()); err != nil {
+		t.Fatalf("Execute() after connection loss error: %v", err)
+	}
+}
+
+func TestPipelinedTCPClient_Closed(t *testing.T) {
+	client := NewPipelinedTCPClient("127.0.0.1", time.Second, 1, 1)
+	client.Close()
+
+	request := []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x00, 0x00, 0x01}
+	if _, err := client.Execute(request); err == nil {
+		t.Fatal("expected error on closed client, got nil")
+	}
+}
+
+func TestPipelinedTCPClient_Timeout(t *testing.T) {
+	// The server waits for a second request that never comes.
+	ln := startPipelinedServer(t, 2)
+	defer ln.Close()
+
+	client := NewPipelinedTCPClient("127.0.0.1", 50*time.Millisecond, ln.Addr().(*net.TCPAddr).Port, 1)
+	defer client.Close()
+
+	request := []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x00, 0x00, 0x01}
+	if _, err := client.Execute(request); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+}
+
+// TestPipelinedTCPClient_SlowDial checks that a dial in progress neither holds
+// the mux lock nor outlives the caller's context.
+func TestPipelinedTCPClient_SlowDial(t *testing.T) {
+	client := NewPipelinedTCPClient("192.0.2.1", time.Minute, 502, 2)
+	defer client.Close()
+	dialing := make(chan struct{})
+	client.mux.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		close(dialing)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	request := []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x00, 0x00, 0x01}
+	go func() {
+		_, err := client.ExecuteContext(ctx, request)
+		done <- err
+	}()
+
+	<-dialing
+	if !client.mux.mu.TryLock() {
+		t.Error("mux lock held while dialing")
+	} else {
+		client.mux.mu.Unlock()
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelling ctx did not interrupt the dial")
+	}
+}
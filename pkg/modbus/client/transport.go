@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Transport moves ADU bytes between the client and a device, independently
+// of how they are framed. Acquire hands out a byte stream for one
+// transaction, giving up if ctx is done first; Release gives it back. If
+// failed is true the transaction did not complete and the stream may still
+// hold part of a reply, so a transport that reuses streams must discard it.
+type Transport interface {
+	Acquire(ctx context.Context) (io.ReadWriter, error)
+	Release(conn io.ReadWriter, failed bool)
+	Close() error
+}
+
+var (
+	_ Transport = (*TCPTransport)(nil)
+	_ Transport = (*SerialTransport)(nil)
+)
+
+// TCPTransport dials a TCP connection per transaction, or borrows one from
+// Pool if set.
+type TCPTransport struct {
+	Host    string
+	Port    int
+	Timeout time.Duration
+	Pool    *TCPConnectionPool
+}
+
+func NewTCPTransport(host string, port int, timeout time.Duration, pool *TCPConnectionPool) *TCPTransport {
+	return &TCPTransport{
+		Host:    host,
+		Port:    port,
+		Timeout: timeout,
+		Pool:    pool,
+	}
+}
+
+func (t *TCPTransport) Acquire(ctx context.Context) (io.ReadWriter, error) {
+	if t.Pool != nil {
+		conn, err := t.Pool.GetContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get connection from pool: %w", err)
+		}
+		return conn, nil
+	}
+
+	address := net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+	dialer := &net.Dialer{Timeout: t.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	return conn, nil
+}
+
+// Release returns conn to the pool, or closes it. A connection on which a
+// transaction failed may still deliver a late reply, and RTU and ASCII frames
+// carry no transaction ID to tell it apart, so it is never reused.
+func (t *TCPTransport) Release(conn io.ReadWriter, failed bool) {
+	c := conn.(net.Conn)
+	if t.Pool != nil && !failed {
+		t.Pool.Put(c)
+		return
+	}
+	c.Close()
+}
+
+// Close does nothing: the pool, if any, is owned by the caller and may be
+// shared with other clients.
+func (t *TCPTransport) Close() error {
+	return nil
+}
+
+// SerialTransport carries frames over a serial line or any other
+// io.ReadWriter (a pty, net.Pipe). Only one transaction can be on the line at
+// a time, so Acquire waits until the previous one is released. When
+// BaudRate is set, Acquire keeps the 3.5 character silence between frames
+// and writes return only once the bytes have left the line; leave it at 0
+// for in-memory pipes.
+//
+// If Port supports SetReadDeadline it is used to enforce the client timeout;
+// otherwise the port must have its own read timeout and return (0, nil) when
+// it expires.
+//
+// A SerialTransport may be built as a struct literal; only Port is required.
+type SerialTransport struct {
+	Port     io.ReadWriter
+	BaudRate int
+
+	// line holds a token while a transaction owns the port. Unlike a mutex,
+	// waiting for it can be abandoned when the caller's context is done. It
+	// is created on first use.
+	line         chan struct{}
+	lineOnce     sync.Once
+	lastActivity time.Time
+}
+
+func NewSerialTransport(port io.ReadWriter, baudRate int) *SerialTransport {
+	return &SerialTransport{
+		Port:     port,
+		BaudRate: baudRate,
+	}
+}
+
+func (t *SerialTransport) Acquire(ctx context.Context) (io.ReadWriter, error) {
+	t.lineOnce.Do(func() { t.line = make(chan struct{}, 1) })
+	select {
+	case t.line <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if t.BaudRate > 0 {
+		if wait := time.Until(t.lastActivity.Add(rtuFrameDelay(t.BaudRate))); wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				<-t.line
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	return &serialConn{port: t.Port, charTime: t.charTime()}, nil
+}
+
+// Release gives the line back. After a failed transaction it first discards
+// whatever the port receives until the line has been silent for a frame gap:
+// a late reply would otherwise be read as the reply to the next request, and
+// pass every check if that is a poll of the same slave and function code.
+func (t *SerialTransport) Release(conn io.ReadWriter, failed bool) {
+	if failed {
+		t.drain()
+	}
+	t.lastActivity = time.Now()
+	<-t.line
+}
+
+// maxDrain bounds how long Release discards input, for a line that never
+// falls silent.
+const maxDrain = time.Second
+
+func (t *SerialTransport) drain() {
+	gap := rtuFrameDelay(t.BaudRate)
+	d, deadlines := t.Port.(readDeadliner)
+	buf := make([]byte, 256)
+	for start := time.Now(); time.Since(start) < maxDrain; {
+		if deadlines {
+			d.SetReadDeadline(time.Now().Add(gap))
+		}
+		n, err := t.Port.Read(buf)
+		if n == 0 || err != nil {
+			break
+		}
+	}
+	if deadlines {
+		d.SetReadDeadline(time.Time{})
+	}
+}
+
+// Close does nothing: the port is owned by the caller that opened it.
+func (t *SerialTransport) Close() error {
+	return nil
+}
+
+func (t *SerialTransport) charTime() time.Duration {
+	if t.BaudRate <= 0 {
+		return 0
+	}
+	return rtuCharTime(t.BaudRate)
+}
+
+// serialConn is the stream handed out by SerialTransport for one transaction.
+type serialConn struct {
+	port     io.ReadWriter
+	charTime time.Duration
+}
+
+func (c *serialConn) Read(b []byte) (int, error) {
+	return c.port.Read(b)
+}
+
+// Write blocks until the frame has been transmitted, so that the response
+// timeout starts when the slave could first answer.
+func (c *serialConn) Write(b []byte) (int, error) {
+	n, err := c.port.Write(b)
+	if c.charTime > 0 {
+		time.Sleep(c.charTime * time.Duration(n))
+	}
+	return n, err
+}
+
+func (c *serialConn) SetReadDeadline(t time.Time) error {
+	if d, ok := c.port.(readDeadliner); ok {
+		return d.SetReadDeadline(t)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"modbus_client/pkg/modbus"
+	"time"
+)
+
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// Client runs Modbus transactions by combining a Packager, which decides what
+// goes on the wire, with a Transport, which decides how it gets there. Any
+// combination works: RTU frames over TCP, MBAP over a serial line, etc.
+type Client struct {
+	Transport Transport
+	Packager  Packager
+	Timeout   time.Duration
+}
+
+func NewClient(transport Transport, packager Packager, timeout time.Duration) *Client {
+	return &Client{
+		Transport: transport,
+		Packager:  packager,
+		Timeout:   timeout,
+	}
+}
+
+// Send encodes frame with the Packager, executes it and returns the decoded
+// reply frame.
+func (c *Client) Send(frame []byte) ([]byte, error) {
+	return c.SendContext(context.Background(), frame)
+}
+
+// SendContext is Send, abandoned when ctx is done. The reply is awaited until
+// Timeout or the ctx deadline, whichever comes first.
+func (c *Client) SendContext(ctx context.Context, frame []byte) ([]byte, error) {
+	aduRequest, err := c.Packager.Encode(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	_, respFrame, err := c.exchange(ctx, aduRequest)
+	return respFrame, err
+}
+
+// Execute sends an already encoded ADU and returns the reply ADU once the
+// Packager has verified it.
+func (c *Client) Execute(aduRequest []byte) ([]byte, error) {
+	return c.ExecuteContext(context.Background(), aduRequest)
+}
+
+// ExecuteContext is Execute, abandoned when ctx is done.
+func (c *Client) ExecuteContext(ctx context.Context, aduRequest []byte) ([]byte, error) {
+	aduResponse, _, err := c.exchange(ctx, aduRequest)
+	return aduResponse, err
+}
+
+// Close closes the Transport.
+func (c *Client) Close() error {
+	return c.Transport.Close()
+}
+
+func (c *Client) exchange(ctx context.Context, aduRequest []byte) ([]byte, []byte, error) {
+	conn, err := c.Transport.Acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aduResponse, respFrame, err := c.transact(ctx, conn, aduRequest)
+
+	// Exception replies are complete transactions and leave the stream clean.
+	// A cancelled transaction may have been cut short, so its stream is not.
+	var exc *modbus.ModbusException
+	failed := err != nil && !errors.As(err, &exc)
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		failed = true
+		if err != nil {
+			err = ctxErr
+		}
+	}
+	c.Transport.Release(conn, failed)
+
+	return aduResponse, respFrame, err
+}
+
+func (c *Client) transact(ctx context.Context, conn io.ReadWriter, aduRequest []byte) ([]byte, []byte, error) {
+	stop := interruptOnDone(ctx, conn)
+	defer stop()
+
+	// Deadlines set after ctx is done would undo the interruption, so ctx is
+	// checked again once they are in place.
+	if d, ok := conn.(writeDeadliner); ok {
+		d.SetWriteDeadline(deadlineWithin(ctx, c.Timeout))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if _, err := conn.Write(aduRequest); err != nil {
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	deadline := deadlineWithin(ctx, c.Timeout)
+	if d, ok := conn.(readDeadliner); ok {
+		d.SetReadDeadline(deadline)
+		defer d.SetReadDeadline(time.Time{})
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	aduResponse, err := c.Packager.ReadResponse(conn, aduRequest, deadline)
+	if err != nil || aduResponse == nil {
+		return nil, nil, err
+	}
+
+	respFrame, err := c.Packager.Decode(aduRequest, aduResponse)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aduResponse, respFrame, nil
+}
+
+// deadlineWithin returns the end of a wait of timeout starting now, brought
+// forward to the ctx deadline if that is earlier.
+func deadlineWithin(ctx context.Context, timeout time.Duration) time.Time {
+	t := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(t) {
+		return d
+	}
+	return t
+}
+
+// interruptOnDone makes blocked I/O on conn return as soon as ctx is done, by
+// moving its deadlines to now. Streams without deadline support, such as some
+// serial ports, only notice cancellation when their own timeout expires. The
+// returned function stops watching ctx.
+func interruptOnDone(ctx context.Context, conn io.ReadWriter) func() bool {
+	return context.AfterFunc(ctx, func() {
+		now := time.Now()
+		if d, ok := conn.(writeDeadliner); ok {
+			d.SetWriteDeadline(now)
+		}
+		if d, ok := conn.(readDeadliner); ok {
+			d.SetReadDeadline(now)
+		}
+	})
+}
+
+// contextError is ctx.Err(), except that a passed deadline counts even if the
+// context has not noticed yet: I/O bounded by the same deadline may return
+// first.
+func contextError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
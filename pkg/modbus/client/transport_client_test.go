@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"modbus_client/pkg/modbus"
+	"net"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestClient_MBAPOverPipe checks that a Packager and a Transport can be
+// combined freely: here Modbus TCP framing over an in-memory pipe.
+func TestClient_MBAPOverPipe(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+
+	go func() {
+		req := make([]byte, 12)
+		if _, err := io.ReadFull(device, req); err != nil {
+			return
+		}
+		device.Write([]byte{req[0], req[1], 0x00, 0x00, 0x00, 0x05, 0x01, 0x04, 0x02, 0x12, 0x34})
+	}()
+
+	client := NewClient(NewSerialTransport(line, 0), &TCPPackager{}, time.Second)
+	frame, err := client.Send([]byte{0x01, 0x04, 0x00, 0x00, 0x00, 0x01})
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	if !reflect.DeepEqual(frame, []byte{0x01, 0x04, 0x02, 0x12, 0x34}) {
+		t.Errorf("unexpected reply frame: %v", frame)
+	}
+}
+
+func TestSerialTransport_FrameDelay(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+	go io.Copy(io.Discard, device)
+
+	transport := NewSerialTransport(line, 1200)
+
+	conn, _ := transport.Acquire(context.Background())
+	transport.Release(conn, false)
+
+	// At 1200 baud the 3.5 character silence is about 32ms.
+	start := time.Now()
+	conn, _ = transport.Acquire(context.Background())
+	transport.Release(conn, false)
+	if elapsed := time.Since(start); elapsed < rtuFrameDelay(1200) {
+		t.Errorf("expected Acquire to wait at least %v, waited %v", rtuFrameDelay(1200), elapsed)
+	}
+}
+
+func TestSerialTransport_AcquireCancelled(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+
+	transport := NewSerialTransport(line, 0)
+	conn, _ := transport.Acquire(context.Background())
+
+	// The line is held, so a second transaction waits until ctx gives up.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := transport.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	transport.Release(conn, false)
+	conn, err := transport.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error after Release: %v", err)
+	}
+	transport.Release(conn, false)
+}
+
+func TestSerialTransport_StructLiteral(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+
+	transport := &SerialTransport{Port: line}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		conn, err := transport.Acquire(ctx)
+		if err != nil {
+			t.Fatalf("Acquire() error: %v", err)
+		}
+		transport.Release(conn, false)
+	}
+}
+
+func TestTCPTransport_ReleaseFailed(t *testing.T) {
+	ln := startTestServer(t)
+	defer ln.Close()
+
+	pool := NewTCPConnectionPool(ln.Addr().String(), time.Second, 1)
+	defer pool.Close()
+	transport := NewTCPTransport("", 0, time.Second, pool)
+
+	conn, err := transport.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error: %v", err)
+	}
+	transport.Release(conn, true)
+
+	if len(pool.pool) != 0 {
+		t.Error("expected failed connection to be closed instead of pooled")
+	}
+	if _, err := conn.Write([]byte("test")); err == nil {
+		t.Error("expected failed connection to be closed")
+	}
+}
+
+// latePort is a serial line to a slave that answers each read of a holding
+// register with the number of the request. Its reply to the first request
+// only arrives once the client has timed out.
+type latePort struct {
+	mu       sync.Mutex
+	in       []byte
+	deadline time.Time
+	requests int
+	late     []byte
+}
+
+func (p *latePort) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requests++
+	reply, _ := (&modbus.RTURequestWrapper{ModbusFrame: []byte{b[0], b[1], 0x02, 0x00, byte(p.requests)}}).Build()
+	if p.requests == 1 {
+		p.late = reply
+	} else {
+		p.in = append(p.in, reply...)
+	}
+	return len(b), nil
+}
+
+func (p *latePort) Read(b []byte) (int, error) {
+	for {
+		p.mu.Lock()
+		if len(p.in) > 0 {
+			n := copy(b, p.in)
+			p.in = p.in[n:]
+			p.mu.Unlock()
+			return n, nil
+		}
+		if !p.deadline.IsZero() && time.Now().After(p.deadline) {
+			p.in, p.late = append(p.in, p.late...), nil
+			p.mu.Unlock()
+			return 0, os.ErrDeadlineExceeded
+		}
+		p.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (p *latePort) SetReadDeadline(t time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deadline = t
+	return nil
+}
+
+func TestSerialTransport_DiscardsLateReply(t *testing.T) {
+	client := NewClient(NewSerialTransport(&latePort{}, 0), &RTUPackager{}, 50*time.Millisecond)
+	request := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01}
+
+	if _, err := client.Send(request); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	frame, err := client.Send(request)
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	if !reflect.DeepEqual(frame, []byte{0x01, 0x03, 0x02, 0x00, 0x02}) {
+		t.Errorf("expected the reply to the second request, got %v", frame)
+	}
+}
@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// maxDatagramLength bounds the datagrams read by UDPTransport. A Modbus TCP
+// ADU is at most 260 bytes; the extra room tolerates non-conforming devices.
+const maxDatagramLength = 1024
+
+var _ Transport = (*UDPTransport)(nil)
+
+// UDPTransport sends each transaction from its own connected UDP socket, so
+// replies to earlier transactions never reach it.
+type UDPTransport struct {
+	Host    string
+	Port    int
+	Timeout time.Duration
+}
+
+func NewUDPTransport(host string, port int, timeout time.Duration) *UDPTransport {
+	return &UDPTransport{
+		Host:    host,
+		Port:    port,
+		Timeout: timeout,
+	}
+}
+
+func (t *UDPTransport) Acquire(ctx context.Context) (io.ReadWriter, error) {
+	address := net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+	dialer := &net.Dialer{Timeout: t.Timeout}
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open UDP socket to %s: %w", address, err)
+	}
+	return &udpConn{Conn: conn}, nil
+}
+
+func (t *UDPTransport) Release(conn io.ReadWriter, failed bool) {
+	conn.(*udpConn).Close()
+}
+
+func (t *UDPTransport) Close() error {
+	return nil
+}
+
+// udpConn lets packagers read a datagram piecemeal: each datagram is read
+// whole and handed out across as many Read calls as needed, instead of the
+// unread tail being discarded by the socket.
+type udpConn struct {
+	net.Conn
+	pending []byte
+}
+
+func (c *udpConn) Read(b []byte) (int, error) {
+	if len(c.pending) == 0 {
+		datagram := make([]byte, maxDatagramLength)
+		n, err := c.Conn.Read(datagram)
+		if err != nil {
+			return 0, err
+		}
+		c.pending = datagram[:n]
+	}
+
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// UDPClient talks Modbus TCP framing (MBAP header) over UDP. Since datagrams
+// can be lost, a request that is not answered within Timeout is sent again,
+// with the same transaction ID, up to Retries more times.
+type UDPClient struct {
+	Transport *UDPTransport
+	Timeout   time.Duration
+	Retries   int
+
+	mbap TCPPackager
+}
+
+func NewUDPClient(host string, port int, timeout time.Duration, retries int) *UDPClient {
+	return &UDPClient{
+		Transport: NewUDPTransport(host, port, timeout),
+		Timeout:   timeout,
+		Retries:   retries,
+	}
+}
+
+// NextTransactionID returns the transaction ID to put in the MBAP header of
+// the next request sent through this client.
+func (c *UDPClient) NextTransactionID() uint16 {
+	return c.mbap.NextTransactionID()
+}
+
+// Execute sends a Modbus TCP frame as a datagram and returns the reply
+// carrying the same transaction ID, retransmitting on timeout.
+func (c *UDPClient) Execute(udpRequest []byte) ([]byte, error) {
+	return c.ExecuteContext(context.Background(), udpRequest)
+}
+
+// ExecuteContext is Execute, abandoned when ctx is done. No retransmission
+// is made past the ctx deadline.
+func (c *UDPClient) ExecuteContext(ctx context.Context, udpRequest []byte) ([]byte, error) {
+	udpResponse, _, err := c.exchange(ctx, udpRequest)
+	return udpResponse, err
+}
+
+// Send wraps frame in an MBAP header carrying the next transaction ID and
+// returns the reply frame (unit ID onwards).
+func (c *UDPClient) Send(frame []byte) ([]byte, error) {
+	return c.SendContext(context.Background(), frame)
+}
+
+// SendContext is Send, abandoned when ctx is done.
+func (c *UDPClient) SendContext(ctx context.Context, frame []byte) ([]byte, error) {
+	udpRequest, err := c.mbap.Encode(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	_, respFrame, err := c.exchange(ctx, udpRequest)
+	return respFrame, err
+}
+
+func (c *UDPClient) Close() error {
+	return c.Transport.Close()
+}
+
+func (c *UDPClient) exchange(ctx context.Context, udpRequest []byte) ([]byte, []byte, error) {
+	conn, err := c.Transport.Acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.Transport.Release(conn, false)
+
+	stop := interruptOnDone(ctx, conn)
+	defer stop()
+
+	// Every attempt uses the same socket, so a late reply to an earlier
+	// transmission of this request is still accepted.
+	attempts := c.Retries + 1
+	for i := 0; i < attempts; i++ {
+		udpResponse, err := c.attempt(ctx, conn.(*udpConn), udpRequest)
+		if err != nil {
+			if ctxErr := contextError(ctx); ctxErr != nil {
+				return nil, nil, ctxErr
+			}
+			if errors.Is(err, ErrTimeout) {
+				continue
+			}
+			return nil, nil, err
+		}
+
+		respFrame, err := c.mbap.Decode(udpRequest, udpResponse)
+		if err != nil {
+			return nil, nil, err
+		}
+		return udpResponse, respFrame, nil
+	}
+
+	return nil, nil, fmt.Errorf("no reply after %d attempts: %w", attempts, ErrTimeout)
+}
+
+func (c *UDPClient) attempt(ctx context.Context, conn *udpConn, udpRequest []byte) ([]byte, error) {
+	deadline := deadlineWithin(ctx, c.Timeout)
+	conn.SetDeadline(deadline)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write(udpRequest); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	// Drop whatever is left of a previous, partially read datagram.
+	conn.pending = nil
+	return c.mbap.ReadResponse(conn, udpRequest, deadline)
+}
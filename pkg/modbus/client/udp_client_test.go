@@ -0,0 +1,80 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// startUDPDevice answers Modbus TCP requests over UDP. drop is called with
+// the number of datagrams received so far and decides whether to ignore the
+// current one; before each real reply a stale reply with another transaction
+// ID is sent.
+func startUDPDevice(t *testing.T, drop func(received int) bool) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	go func() {
+		received := 0
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			received++
+			if n < 12 || drop(received) {
+				continue
+			}
+			req := buf[:n]
+			conn.WriteToUDP([]byte{req[0] + 1, req[1], 0x00, 0x00, 0x00, 0x05, req[6], req[7], 0x02, 0xFF, 0xFF}, addr)
+			conn.WriteToUDP([]byte{req[0], req[1], 0x00, 0x00, 0x00, 0x05, req[6], req[7], 0x02, req[8], req[9]}, addr)
+		}
+	}()
+
+	return conn
+}
+
+func TestUDPClientSend(t *testing.T) {
+	device := startUDPDevice(t, func(int) bool { return false })
+	defer device.Close()
+
+	client := NewUDPClient("127.0.0.1", device.LocalAddr().(*net.UDPAddr).Port, time.Second, 0)
+	frame, err := client.Send([]byte{0x01, 0x03, 0x00, 0x2A, 0x00, 0x01})
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	if !reflect.DeepEqual(frame, []byte{0x01, 0x03, 0x02, 0x00, 0x2A}) {
+		t.Errorf("unexpected reply frame: %v", frame)
+	}
+}
+
+func TestUDPClientSend_Retransmit(t *testing.T) {
+	// The first two datagrams are lost.
+	device := startUDPDevice(t, func(received int) bool { return received <= 2 })
+	defer device.Close()
+
+	client := NewUDPClient("127.0.0.1", device.LocalAddr().(*net.UDPAddr).Port, 100*time.Millisecond, 2)
+	frame, err := client.Send([]byte{0x01, 0x04, 0x00, 0x07, 0x00, 0x01})
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	if !reflect.DeepEqual(frame, []byte{0x01, 0x04, 0x02, 0x00, 0x07}) {
+		t.Errorf("unexpected reply frame: %v", frame)
+	}
+}
+
+func TestUDPClientSend_GiveUp(t *testing.T) {
+	device := startUDPDevice(t, func(int) bool { return true })
+	defer device.Close()
+
+	client := NewUDPClient("127.0.0.1", device.LocalAddr().(*net.UDPAddr).Port, 50*time.Millisecond, 1)
+	_, err := client.Send([]byte{0x01, 0x04, 0x00, 0x07, 0x00, 0x01})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+}
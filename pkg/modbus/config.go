@@ -5,3 +5,15 @@ type ModbusHeader struct {
 	SlaveID     byte
 	DataAddress [2]byte
 }
+
+func NewModbusHeader(fc FunctionCode, slaveID byte, address uint16) ModbusHeader {
+	return ModbusHeader{
+		FC:          fc,
+		SlaveID:     slaveID,
+		DataAddress: [2]byte{byte(address >> 8), byte(address & 0xFF)},
+	}
+}
+
+func (h ModbusHeader) Address() uint16 {
+	return uint16(h.DataAddress[0])<<8 | uint16(h.DataAddress[1])
+}
@@ -14,6 +14,26 @@ type WordByteOrder struct {
 	SwapWords bool
 }
 
+// ParseWordByteOrder maps the usual names of 32-bit register layouts, as
+// found in device manuals, to a WordByteOrder. The letters give the order in
+// which the bytes of the value 0xAABBCCDD appear on the wire: "ABCD" is plain
+// big-endian, "CDAB" big-endian with swapped words, "BADC" little-endian with
+// swapped words and "DCBA" plain little-endian.
+func ParseWordByteOrder(name string) (WordByteOrder, error) {
+	switch strings.ToUpper(name) {
+	case "ABCD", "":
+		return WordByteOrder{ByteOrder: binary.BigEndian}, nil
+	case "CDAB":
+		return WordByteOrder{ByteOrder: binary.BigEndian, SwapWords: true}, nil
+	case "BADC":
+		return WordByteOrder{ByteOrder: binary.LittleEndian, SwapWords: true}, nil
+	case "DCBA":
+		return WordByteOrder{ByteOrder: binary.LittleEndian}, nil
+	default:
+		return WordByteOrder{}, fmt.Errorf("unknown word/byte order %q: expected ABCD, CDAB, BADC or DCBA", name)
+	}
+}
+
 func swapWords32(b []byte) []byte {
 	if len(b) != 4 {
 		return b
@@ -208,3 +228,45 @@ func BytesToFloat64(data []byte, order WordByteOrder) (float64, error) {
 	}
 	return math.Float64frombits(u64), nil
 }
+
+// BoolsToCoilBytes packs coil states LSB first, as used by FC 15 requests
+// and FC 1/2 responses.
+func BoolsToCoilBytes(values []bool) []byte {
+	b := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			b[i/8] |= 1 << (uint(i) % 8)
+		}
+	}
+	return b
+}
+
+func CoilBytesToBools(data []byte, quantity int) ([]bool, error) {
+	if len(data) != (quantity+7)/8 {
+		return nil, fmt.Errorf("data length does not match %d coils: got %d bytes", quantity, len(data))
+	}
+	values := make([]bool, quantity)
+	for i := range values {
+		values[i] = data[i/8]&(1<<(uint(i)%8)) != 0
+	}
+	return values, nil
+}
+
+func Uint16sToBytes(values []uint16, order binary.ByteOrder) []byte {
+	b := make([]byte, 2*len(values))
+	for i, v := range values {
+		order.PutUint16(b[2*i:], v)
+	}
+	return b
+}
+
+func BytesToUint16s(data []byte, order binary.ByteOrder) ([]uint16, error) {
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("data length is not a multiple of 2 bytes: got %d", len(data))
+	}
+	values := make([]uint16, len(data)/2)
+	for i := range values {
+		values[i] = order.Uint16(data[2*i:])
+	}
+	return values, nil
+}
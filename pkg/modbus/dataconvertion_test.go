@@ -3,6 +3,7 @@ package modbus
 import (
 	"encoding/binary"
 	"math"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -250,3 +251,75 @@ func TestFloat64ToBytesAndBack(t *testing.T) {
 		}
 	}
 }
+
+// --------------------
+// Coil and Register Slices
+// --------------------
+
+func TestCoilBytesAndBack(t *testing.T) {
+	values := []bool{true, false, true, true, false, false, true, false, true, true}
+	b := BoolsToCoilBytes(values)
+	// Coils are packed LSB first: 0b01001101, 0b00000011
+	expected := []byte{0x4D, 0x03}
+	if len(b) != len(expected) || b[0] != expected[0] || b[1] != expected[1] {
+		t.Fatalf("BoolsToCoilBytes mismatch: expected %v, got %v", expected, b)
+	}
+	v2, err := CoilBytesToBools(b, len(values))
+	if err != nil {
+		t.Fatalf("CoilBytesToBools error: %v", err)
+	}
+	for i := range values {
+		if values[i] != v2[i] {
+			t.Errorf("coil %d mismatch: expected %v, got %v", i, values[i], v2[i])
+		}
+	}
+	if _, err := CoilBytesToBools(b, 17); err == nil {
+		t.Error("expected error for mismatched coil quantity, got nil")
+	}
+}
+
+func TestUint16sToBytesAndBack(t *testing.T) {
+	order := binary.BigEndian
+	values := []uint16{0, 1, 0x1234, 0xFFFF}
+	b := Uint16sToBytes(values, order)
+	if len(b) != 8 || b[4] != 0x12 || b[5] != 0x34 {
+		t.Fatalf("Uint16sToBytes mismatch: got %v", b)
+	}
+	v2, err := BytesToUint16s(b, order)
+	if err != nil {
+		t.Fatalf("BytesToUint16s error: %v", err)
+	}
+	for i := range values {
+		if values[i] != v2[i] {
+			t.Errorf("register %d mismatch: expected %d, got %d", i, values[i], v2[i])
+		}
+	}
+	if _, err := BytesToUint16s([]byte{0x01, 0x02, 0x03}, order); err == nil {
+		t.Error("expected error for odd data length, got nil")
+	}
+}
+
+func TestParseWordByteOrder(t *testing.T) {
+	expected := map[string][]byte{
+		"ABCD": {0xAA, 0xBB, 0xCC, 0xDD},
+		"cdab": {0xCC, 0xDD, 0xAA, 0xBB},
+		"BADC": {0xBB, 0xAA, 0xDD, 0xCC},
+		"DCBA": {0xDD, 0xCC, 0xBB, 0xAA},
+	}
+	for name, wire := range expected {
+		order, err := ParseWordByteOrder(name)
+		if err != nil {
+			t.Fatalf("ParseWordByteOrder(%q) error: %v", name, err)
+		}
+		if b := Uint32ToBytes(0xAABBCCDD, order); !reflect.DeepEqual(b, wire) {
+			t.Errorf("%s: Uint32ToBytes mismatch.\nExpected: %X\nGot:      %X", name, wire, b)
+		}
+		if v, _ := BytesToUint32(wire, order); v != 0xAABBCCDD {
+			t.Errorf("%s: BytesToUint32 = 0x%08X, expected 0xAABBCCDD", name, v)
+		}
+	}
+
+	if _, err := ParseWordByteOrder("ACBD"); err == nil {
+		t.Error("expected error for unknown order, got nil")
+	}
+}
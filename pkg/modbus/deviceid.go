@@ -0,0 +1,196 @@
+package modbus
+
+import (
+	"fmt"
+)
+
+// MEIReadDeviceIdentification is the MEI type of Read Device Identification
+// requests, carried by FCEncapsulatedInterface.
+const MEIReadDeviceIdentification byte = 0x0E
+
+// ReadDeviceIDCode selects the objects a ReadDeviceIDRequest asks for: a whole
+// category, streamed from ObjectID on, or one individual object.
+type ReadDeviceIDCode byte
+
+const (
+	ReadDeviceIDBasic      ReadDeviceIDCode = 1
+	ReadDeviceIDRegular    ReadDeviceIDCode = 2
+	ReadDeviceIDExtended   ReadDeviceIDCode = 3
+	ReadDeviceIDIndividual ReadDeviceIDCode = 4
+)
+
+// Standard object IDs. Objects 0x00-0x02 are basic and mandatory, 0x03-0x7F
+// regular, and 0x80-0xFF extended (vendor specific).
+const (
+	ObjectVendorName          byte = 0x00
+	ObjectProductCode         byte = 0x01
+	ObjectMajorMinorRevision  byte = 0x02
+	ObjectVendorURL           byte = 0x03
+	ObjectProductName         byte = 0x04
+	ObjectModelName           byte = 0x05
+	ObjectUserApplicationName byte = 0x06
+)
+
+// ReadDeviceIDRequest is FC 43 / MEI type 14.
+type ReadDeviceIDRequest struct {
+	SlaveID  byte
+	Code     ReadDeviceIDCode
+	ObjectID byte
+}
+
+// DeviceIDObject is one identification object, as found in a reply.
+type DeviceIDObject struct {
+	ID    byte
+	Value []byte
+}
+
+// ReadDeviceIDResponse is the reply to a ReadDeviceIDRequest. If the objects
+// did not fit in one reply, MoreFollows is set and the next request should
+// start at NextObjectID.
+type ReadDeviceIDResponse struct {
+	SlaveID         byte
+	Code            ReadDeviceIDCode
+	ConformityLevel byte
+	MoreFollows     bool
+	NextObjectID    byte
+	Objects         []DeviceIDObject
+}
+
+func (r *ReadDeviceIDRequest) header() ModbusHeader {
+	return ModbusHeader{FC: FCEncapsulatedInterface, SlaveID: r.SlaveID}
+}
+
+func (r *ReadDeviceIDRequest) Build() ([]byte, error) {
+	if r.Code < ReadDeviceIDBasic || r.Code > ReadDeviceIDIndividual {
+		return nil, fmt.Errorf("invalid Read Device ID code: %d", r.Code)
+	}
+
+	// Frame layout (Modbus PDU):
+	//   [0] SlaveID
+	//   [1] FunctionCode (43)
+	//   [2] MEI Type (14)
+	//   [3] Read Device ID code
+	//   [4] Object ID
+	return []byte{r.SlaveID, byte(FCEncapsulatedInterface), MEIReadDeviceIdentification, byte(r.Code), r.ObjectID}, nil
+}
+
+// Parse decodes a Read Device Identification request frame, as received by a
+// slave.
+func (r *ReadDeviceIDRequest) Parse(frame []byte) error {
+	if len(frame) != 5 {
+		return fmt.Errorf("Read Device ID request length mismatch: expected 5 bytes, got %d", len(frame))
+	}
+	if frame[2] != MEIReadDeviceIdentification {
+		return fmt.Errorf("unsupported MEI type: 0x%02X", frame[2])
+	}
+
+	r.SlaveID = frame[0]
+	r.Code = ReadDeviceIDCode(frame[3])
+	r.ObjectID = frame[4]
+
+	if r.Code < ReadDeviceIDBasic || r.Code > ReadDeviceIDIndividual {
+		return fmt.Errorf("invalid Read Device ID code: %d", r.Code)
+	}
+	return nil
+}
+
+func (r *ReadDeviceIDResponse) Build() ([]byte, error) {
+	// Frame layout
+	// [0] SlaveID
+	// [1] FunctionCode (43)
+	// [2] MEI Type (14)
+	// [3] Read Device ID code
+	// [4] Conformity level
+	// [5] More Follows (0x00 or 0xFF)
+	// [6] Next Object ID
+	// [7] Number of objects
+	// [n] objects: ID, length, value
+	if len(r.Objects) > 255 {
+		return nil, fmt.Errorf("too many objects: %d", len(r.Objects))
+	}
+
+	frame := []byte{r.SlaveID, byte(FCEncapsulatedInterface), MEIReadDeviceIdentification, byte(r.Code), r.ConformityLevel, 0x00, r.NextObjectID, byte(len(r.Objects))}
+	if r.MoreFollows {
+		frame[5] = 0xFF
+	}
+	for _, obj := range r.Objects {
+		if len(obj.Value) > 255 {
+			return nil, fmt.Errorf("object 0x%02X too long: %d bytes", obj.ID, len(obj.Value))
+		}
+		frame = append(frame, obj.ID, byte(len(obj.Value)))
+		frame = append(frame, obj.Value...)
+	}
+
+	return frame, nil
+}
+
+func (r *ReadDeviceIDResponse) Parse(frame []byte, req *ReadDeviceIDRequest) error {
+	if err := checkResponseHeader(frame, req.header()); err != nil {
+		return err
+	}
+	if len(frame) < 8 {
+		return fmt.Errorf("response frame too short: %d bytes", len(frame))
+	}
+	if frame[2] != MEIReadDeviceIdentification {
+		return fmt.Errorf("MEI type mismatch: expected 0x%02X, got 0x%02X", MEIReadDeviceIdentification, frame[2])
+	}
+	if ReadDeviceIDCode(frame[3]) != req.Code {
+		return fmt.Errorf("Read Device ID code mismatch: expected %d, got %d", req.Code, frame[3])
+	}
+
+	objects, end, err := parseDeviceIDObjects(frame)
+	if err != nil {
+		return err
+	}
+	if end != len(frame) {
+		return fmt.Errorf("%d unexpected bytes after the last object", len(frame)-end)
+	}
+
+	r.SlaveID = frame[0]
+	r.Code = ReadDeviceIDCode(frame[3])
+	r.ConformityLevel = frame[4]
+	r.MoreFollows = frame[5] == 0xFF
+	r.NextObjectID = frame[6]
+	r.Objects = objects
+
+	return nil
+}
+
+// parseDeviceIDObjects walks the object list of a Read Device ID reply frame
+// and returns the objects and the offset just past the last one.
+func parseDeviceIDObjects(frame []byte) ([]DeviceIDObject, int, error) {
+	count := int(frame[7])
+	objects := make([]DeviceIDObject, 0, count)
+
+	i := 8
+	for n := 0; n < count; n++ {
+		if i+2 > len(frame) {
+			return nil, 0, fmt.Errorf("response truncated in object %d of %d", n+1, count)
+		}
+		id, length := frame[i], int(frame[i+1])
+		if i+2+length > len(frame) {
+			return nil, 0, fmt.Errorf("response truncated in object 0x%02X", id)
+		}
+		objects = append(objects, DeviceIDObject{ID: id, Value: frame[i+2 : i+2+length]})
+		i += 2 + length
+	}
+	return objects, i, nil
+}
+
+// deviceIDResponseLength returns the length of the Read Device ID reply that
+// frame starts with, or the length needed to tell, if frame is too short.
+func deviceIDResponseLength(frame []byte) int {
+	if len(frame) < 8 {
+		return 8
+	}
+	count := int(frame[7])
+
+	i := 8
+	for n := 0; n < count; n++ {
+		if i+2 > len(frame) {
+			return i + 2
+		}
+		i += 2 + int(frame[i+1])
+	}
+	return i
+}
@@ -0,0 +1,102 @@
+package modbus
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReadDeviceIDRequest(t *testing.T) {
+	req := &ReadDeviceIDRequest{SlaveID: 0x01, Code: ReadDeviceIDBasic, ObjectID: 0x00}
+
+	expected := []byte{0x01, 0x2B, 0x0E, 0x01, 0x00}
+	frame, err := req.Build()
+	if err != nil {
+		t.Fatalf("ReadDeviceIDRequest Build() error: %v", err)
+	}
+	if !reflect.DeepEqual(frame, expected) {
+		t.Errorf("ReadDeviceIDRequest Build() failed.\nExpected: %v\nGot:      %v", expected, frame)
+	}
+
+	parsed := &ReadDeviceIDRequest{}
+	if err := parsed.Parse(frame); err != nil || *parsed != *req {
+		t.Errorf("ReadDeviceIDRequest Parse() = %+v, %v", parsed, err)
+	}
+
+	if _, err := (&ReadDeviceIDRequest{Code: 5}).Build(); err == nil {
+		t.Error("expected error for invalid code, got nil")
+	}
+	if err := parsed.Parse([]byte{0x01, 0x2B, 0x0D, 0x01, 0x00}); err == nil {
+		t.Error("expected error for MEI type 13, got nil")
+	}
+}
+
+func TestReadDeviceIDResponse(t *testing.T) {
+	req := &ReadDeviceIDRequest{SlaveID: 0x01, Code: ReadDeviceIDBasic}
+
+	// The example from the specification, with the last object split off.
+	frame := []byte{
+		0x01, 0x2B, 0x0E, 0x01, 0x01, 0xFF, 0x02, 0x02,
+		0x00, 0x16, 'C', 'o', 'm', 'p', 'a', 'n', 'y', ' ', 'i', 'd', 'e', 'n', 't', 'i', 'f', 'i', 'c', 'a', 't', 'i', 'o', 'n',
+		0x01, 0x0D, 'P', 'r', 'o', 'd', 'u', 'c', 't', ' ', 'c', 'o', 'd', 'e', ' ',
+	}
+
+	resp := &ReadDeviceIDResponse{}
+	if err := resp.Parse(frame, req); err != nil {
+		t.Fatalf("ReadDeviceIDResponse Parse() error: %v", err)
+	}
+	if !resp.MoreFollows || resp.NextObjectID != 0x02 || resp.ConformityLevel != 0x01 || len(resp.Objects) != 2 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if string(resp.Objects[0].Value) != "Company identification" || resp.Objects[1].ID != ObjectProductCode {
+		t.Errorf("unexpected objects: %+v", resp.Objects)
+	}
+
+	built, err := resp.Build()
+	if err != nil || !reflect.DeepEqual(built, frame) {
+		t.Errorf("ReadDeviceIDResponse Build() = %v, %v", built, err)
+	}
+
+	if err := resp.Parse(frame[:len(frame)-1], req); err == nil {
+		t.Error("expected error for truncated object, got nil")
+	}
+	if err := resp.Parse(append(frame[:len(frame):len(frame)], 0x00), req); err == nil {
+		t.Error("expected error for trailing byte, got nil")
+	}
+	if err := resp.Parse([]byte{0x01, 0xAB, 0x02}, req); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Errorf("expected ErrIllegalDataAddress, got %v", err)
+	}
+}
+
+func TestRTUResponseRemaining_DeviceID(t *testing.T) {
+	resp := &ReadDeviceIDResponse{
+		SlaveID: 0x01,
+		Code:    ReadDeviceIDRegular,
+		Objects: []DeviceIDObject{{ID: 0x00, Value: []byte("ACME")}, {ID: 0x04, Value: []byte{}}, {ID: 0x05, Value: []byte("M-1")}},
+	}
+	frame, _ := resp.Build()
+	adu, _ := (&RTURequestWrapper{ModbusFrame: frame}).Build()
+
+	// Read as a packager would, asking for more until nothing is missing.
+	got := adu[:3]
+	for {
+		n, err := RTUResponseRemaining(got)
+		if err != nil {
+			t.Fatalf("RTUResponseRemaining() error: %v", err)
+		}
+		if n == 0 {
+			break
+		}
+		if len(got)+n > len(adu) {
+			t.Fatalf("asked for %d bytes past %d, frame has %d", n, len(got), len(adu))
+		}
+		got = adu[:len(got)+n]
+	}
+	if len(got) != len(adu) {
+		t.Errorf("sized frame at %d bytes, expected %d", len(got), len(adu))
+	}
+
+	if n, err := RTUResponseRemaining([]byte{0x01, 0x03, 0x04}); err != nil || n != 6 {
+		t.Errorf("RTUResponseRemaining() for FC 3 = %d, %v, expected 6", n, err)
+	}
+}
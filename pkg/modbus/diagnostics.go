@@ -0,0 +1,431 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// DiagnosticSubFunction selects what a Diagnostics (FC 8) request does.
+type DiagnosticSubFunction uint16
+
+const (
+	DiagReturnQueryData                DiagnosticSubFunction = 0x00
+	DiagRestartCommunications          DiagnosticSubFunction = 0x01
+	DiagReturnDiagnosticRegister       DiagnosticSubFunction = 0x02
+	DiagChangeASCIIDelimiter           DiagnosticSubFunction = 0x03
+	DiagForceListenOnly                DiagnosticSubFunction = 0x04
+	DiagClearCounters                  DiagnosticSubFunction = 0x0A
+	DiagReturnBusMessageCount          DiagnosticSubFunction = 0x0B
+	DiagReturnBusCommErrorCount        DiagnosticSubFunction = 0x0C
+	DiagReturnBusExceptionErrorCount   DiagnosticSubFunction = 0x0D
+	DiagReturnSlaveMessageCount        DiagnosticSubFunction = 0x0E
+	DiagReturnSlaveNoResponseCount     DiagnosticSubFunction = 0x0F
+	DiagReturnSlaveNAKCount            DiagnosticSubFunction = 0x10
+	DiagReturnSlaveBusyCount           DiagnosticSubFunction = 0x11
+	DiagReturnBusCharacterOverrunCount DiagnosticSubFunction = 0x12
+	DiagClearOverrunCounter            DiagnosticSubFunction = 0x14
+)
+
+// DiagnosticCounters holds the counters a slave keeps since its last
+// restart, counter clear or power-up.
+type DiagnosticCounters struct {
+	BusMessages          uint16
+	BusCommErrors        uint16
+	BusExceptionErrors   uint16
+	SlaveMessages        uint16
+	SlaveNoResponses     uint16
+	SlaveNAKs            uint16
+	SlaveBusy            uint16
+	BusCharacterOverruns uint16
+}
+
+// BareRequest is a request made of the slave ID and function code alone, as
+// used by Read Exception Status (FC 7), Get Comm Event Counter (FC 11), Get
+// Comm Event Log (FC 12) and Report Slave ID (FC 17).
+type BareRequest struct {
+	SlaveID byte
+	FC      FunctionCode
+}
+
+// DiagnosticsRequest is FC 8. Data is usually a single register: 0x0000 for
+// the counters, 0xFF00 to also clear the event log on restart, etc.
+type DiagnosticsRequest struct {
+	SlaveID     byte
+	SubFunction DiagnosticSubFunction
+	Data        []byte
+}
+
+// DiagnosticsResponse is the reply to a DiagnosticsRequest: the request
+// echoed, or the requested counter in Data.
+type DiagnosticsResponse struct {
+	SlaveID     byte
+	SubFunction DiagnosticSubFunction
+	Data        []byte
+}
+
+// ExceptionStatusResponse is the reply to Read Exception Status: eight
+// device-defined status bits.
+type ExceptionStatusResponse struct {
+	SlaveID byte
+	Status  byte
+}
+
+// CommEventCounterResponse is the reply to Get Comm Event Counter. Busy is set
+// while the slave is still processing a previous program command.
+type CommEventCounterResponse struct {
+	SlaveID    byte
+	Busy       bool
+	EventCount uint16
+}
+
+// CommEventLogResponse is the reply to Get Comm Event Log. Events holds up to
+// 64 events, the most recent first.
+type CommEventLogResponse struct {
+	SlaveID      byte
+	Busy         bool
+	EventCount   uint16
+	MessageCount uint16
+	Events       []CommEvent
+}
+
+// ReportSlaveIDResponse is the reply to Report Slave ID. Its content is device
+// specific beyond the layout most devices follow: a one byte ID, the run
+// indicator (0x00 off, 0xFF on), then free-form data. Data keeps the whole
+// payload for devices that do otherwise.
+type ReportSlaveIDResponse struct {
+	SlaveID    byte
+	ID         byte
+	Running    bool
+	Additional []byte
+	Data       []byte
+}
+
+// CommEvent is one byte of a comm event log. Bit 7 set marks a receive event
+// and bits 7-6 equal to 01 a send event; the other bits are flags. 0x00 and
+// 0x04 mark a communications restart and entering listen only mode.
+type CommEvent byte
+
+const (
+	CommEventRestart    CommEvent = 0x00
+	CommEventListenOnly CommEvent = 0x04
+
+	CommEventReceive           CommEvent = 0x80
+	CommEventReceiveCommError  CommEvent = 0x02
+	CommEventReceiveOverrun    CommEvent = 0x10
+	CommEventReceiveListenOnly CommEvent = 0x20
+	CommEventReceiveBroadcast  CommEvent = 0x40
+
+	CommEventSend               CommEvent = 0x40
+	CommEventSendReadException  CommEvent = 0x01
+	CommEventSendAbortException CommEvent = 0x02
+	CommEventSendBusyException  CommEvent = 0x04
+	CommEventSendNAKException   CommEvent = 0x08
+	CommEventSendWriteTimeout   CommEvent = 0x10
+	CommEventSendListenOnly     CommEvent = 0x20
+)
+
+func (e CommEvent) IsReceive() bool {
+	return e&0x80 != 0
+}
+
+func (e CommEvent) IsSend() bool {
+	return e&0xC0 == 0x40
+}
+
+// Has reports whether flag, one of the CommEventReceive* or CommEventSend*
+// flags matching the kind of e, is set.
+func (e CommEvent) Has(flag CommEvent) bool {
+	return e&flag == flag
+}
+
+func (e CommEvent) String() string {
+	type flag struct {
+		bit  CommEvent
+		name string
+	}
+	var kind string
+	var flags []flag
+
+	switch {
+	case e.IsReceive():
+		kind = "receive"
+		flags = []flag{
+			{CommEventReceiveCommError, "communication error"},
+			{CommEventReceiveOverrun, "character overrun"},
+			{CommEventReceiveListenOnly, "listen only"},
+			{CommEventReceiveBroadcast, "broadcast"},
+		}
+	case e.IsSend():
+		kind = "send"
+		flags = []flag{
+			{CommEventSendReadException, "read exception"},
+			{CommEventSendAbortException, "abort exception"},
+			{CommEventSendBusyException, "busy exception"},
+			{CommEventSendNAKException, "NAK exception"},
+			{CommEventSendWriteTimeout, "write timeout"},
+			{CommEventSendListenOnly, "listen only"},
+		}
+	case e == CommEventRestart:
+		return "communications restart"
+	case e == CommEventListenOnly:
+		return "entered listen only mode"
+	default:
+		return fmt.Sprintf("unknown event 0x%02X", byte(e))
+	}
+
+	var set []string
+	for _, f := range flags {
+		if e.Has(f.bit) {
+			set = append(set, f.name)
+		}
+	}
+	if len(set) == 0 {
+		return kind
+	}
+	return kind + " (" + strings.Join(set, ", ") + ")"
+}
+
+func (r *BareRequest) header() ModbusHeader {
+	return ModbusHeader{FC: r.FC, SlaveID: r.SlaveID}
+}
+
+func (r *BareRequest) Build() ([]byte, error) {
+	switch r.FC {
+	case FCReadExceptionStatus, FCGetCommEventCounter, FCGetCommEventLog, FCReportSlaveID:
+	default:
+		return nil, fmt.Errorf("function code 0x%02X takes data", byte(r.FC))
+	}
+	return []byte{r.SlaveID, byte(r.FC)}, nil
+}
+
+// Parse decodes a bare request frame, as received by a slave.
+func (r *BareRequest) Parse(frame []byte) error {
+	if len(frame) != 2 {
+		return fmt.Errorf("request length mismatch: expected 2 bytes, got %d", len(frame))
+	}
+	r.SlaveID = frame[0]
+	r.FC = FunctionCode(frame[1])
+	return nil
+}
+
+func (r *DiagnosticsRequest) header() ModbusHeader {
+	return ModbusHeader{FC: FCDiagnostics, SlaveID: r.SlaveID}
+}
+
+func (r *DiagnosticsRequest) Build() ([]byte, error) {
+	if len(r.Data)%2 != 0 {
+		return nil, fmt.Errorf("diagnostic data must be whole registers, got %d bytes", len(r.Data))
+	}
+
+	// Frame layout (Modbus PDU):
+	//   [0]    SlaveID
+	//   [1]    FunctionCode (8)
+	//   [2-3]  Sub-function
+	//   [4...] Data
+	frame := make([]byte, 4+len(r.Data))
+	frame[0] = r.SlaveID
+	frame[1] = byte(FCDiagnostics)
+	binary.BigEndian.PutUint16(frame[2:4], uint16(r.SubFunction))
+	copy(frame[4:], r.Data)
+
+	return frame, nil
+}
+
+// Parse decodes a diagnostics request frame, as received by a slave.
+func (r *DiagnosticsRequest) Parse(frame []byte) error {
+	if len(frame) < 4 || len(frame)%2 != 0 {
+		return fmt.Errorf("invalid diagnostics request length: %d bytes", len(frame))
+	}
+	r.SlaveID = frame[0]
+	r.SubFunction = DiagnosticSubFunction(binary.BigEndian.Uint16(frame[2:4]))
+	r.Data = frame[4:]
+	return nil
+}
+
+func (r *DiagnosticsResponse) Build() ([]byte, error) {
+	req := &DiagnosticsRequest{SlaveID: r.SlaveID, SubFunction: r.SubFunction, Data: r.Data}
+	return req.Build()
+}
+
+func (r *DiagnosticsResponse) Parse(frame []byte, req *DiagnosticsRequest) error {
+	if err := checkResponseHeader(frame, req.header()); err != nil {
+		return err
+	}
+
+	// Frame layout
+	// [0]   SlaveID
+	// [1]   FunctionCode (8)
+	// [2-3] Sub-function
+	// [n]   Data
+	if len(frame) < 4 {
+		return fmt.Errorf("response frame too short: %d bytes", len(frame))
+	}
+	if sub := DiagnosticSubFunction(binary.BigEndian.Uint16(frame[2:4])); sub != req.SubFunction {
+		return fmt.Errorf("sub-function mismatch: expected 0x%04X, got 0x%04X", uint16(req.SubFunction), uint16(sub))
+	}
+	if req.SubFunction == DiagReturnQueryData && !bytes.Equal(frame[4:], req.Data) {
+		return fmt.Errorf("query data not echoed: sent %X, got %X", req.Data, frame[4:])
+	}
+
+	r.SlaveID = frame[0]
+	r.SubFunction = req.SubFunction
+	r.Data = frame[4:]
+
+	return nil
+}
+
+// Value returns Data as a single register, as carried by counter replies.
+func (r *DiagnosticsResponse) Value() (uint16, error) {
+	if len(r.Data) != 2 {
+		return 0, fmt.Errorf("expected 2 data bytes, got %d", len(r.Data))
+	}
+	return binary.BigEndian.Uint16(r.Data), nil
+}
+
+func (r *ExceptionStatusResponse) Build() ([]byte, error) {
+	return []byte{r.SlaveID, byte(FCReadExceptionStatus), r.Status}, nil
+}
+
+func (r *ExceptionStatusResponse) Parse(frame []byte, req *BareRequest) error {
+	if err := checkResponseHeader(frame, req.header()); err != nil {
+		return err
+	}
+	if len(frame) != 3 {
+		return fmt.Errorf("response frame length mismatch: expected 3 bytes, got %d", len(frame))
+	}
+
+	r.SlaveID = frame[0]
+	r.Status = frame[2]
+	return nil
+}
+
+func (r *CommEventCounterResponse) Build() ([]byte, error) {
+	frame := []byte{r.SlaveID, byte(FCGetCommEventCounter), 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(frame[2:4], commStatus(r.Busy))
+	binary.BigEndian.PutUint16(frame[4:6], r.EventCount)
+	return frame, nil
+}
+
+func (r *CommEventCounterResponse) Parse(frame []byte, req *BareRequest) error {
+	if err := checkResponseHeader(frame, req.header()); err != nil {
+		return err
+	}
+
+	// Frame layout
+	// [0]   SlaveID
+	// [1]   FunctionCode (11)
+	// [2-3] Status (0xFFFF busy, 0x0000 ready)
+	// [4-5] Event count
+	if len(frame) != 6 {
+		return fmt.Errorf("response frame length mismatch: expected 6 bytes, got %d", len(frame))
+	}
+
+	r.SlaveID = frame[0]
+	r.Busy = binary.BigEndian.Uint16(frame[2:4]) == 0xFFFF
+	r.EventCount = binary.BigEndian.Uint16(frame[4:6])
+	return nil
+}
+
+func (r *CommEventLogResponse) Build() ([]byte, error) {
+	if len(r.Events) > 64 {
+		return nil, fmt.Errorf("too many events: %d (limit 64)", len(r.Events))
+	}
+
+	frame := make([]byte, 9, 9+len(r.Events))
+	frame[0] = r.SlaveID
+	frame[1] = byte(FCGetCommEventLog)
+	frame[2] = byte(6 + len(r.Events))
+	binary.BigEndian.PutUint16(frame[3:5], commStatus(r.Busy))
+	binary.BigEndian.PutUint16(frame[5:7], r.EventCount)
+	binary.BigEndian.PutUint16(frame[7:9], r.MessageCount)
+	for _, e := range r.Events {
+		frame = append(frame, byte(e))
+	}
+	return frame, nil
+}
+
+func (r *CommEventLogResponse) Parse(frame []byte, req *BareRequest) error {
+	if err := checkResponseHeader(frame, req.header()); err != nil {
+		return err
+	}
+
+	// Frame layout
+	// [0]   SlaveID
+	// [1]   FunctionCode (12)
+	// [2]   Byte count
+	// [3-4] Status
+	// [5-6] Event count
+	// [7-8] Message count
+	// [n]   Events, most recent first
+	if len(frame) < 9 {
+		return fmt.Errorf("response frame too short: %d bytes", len(frame))
+	}
+	if byteCount := int(frame[2]); byteCount != len(frame)-3 {
+		return fmt.Errorf("ByteCount mismatch: header says %d, got %d", byteCount, len(frame)-3)
+	}
+
+	r.SlaveID = frame[0]
+	r.Busy = binary.BigEndian.Uint16(frame[3:5]) == 0xFFFF
+	r.EventCount = binary.BigEndian.Uint16(frame[5:7])
+	r.MessageCount = binary.BigEndian.Uint16(frame[7:9])
+	r.Events = make([]CommEvent, len(frame)-9)
+	for i, b := range frame[9:] {
+		r.Events[i] = CommEvent(b)
+	}
+	return nil
+}
+
+func (r *ReportSlaveIDResponse) Build() ([]byte, error) {
+	data := r.Data
+	if data == nil {
+		data = append([]byte{r.ID, 0x00}, r.Additional...)
+		if r.Running {
+			data[1] = 0xFF
+		}
+	}
+	if len(data) > 251 {
+		return nil, fmt.Errorf("slave ID report too long: %d bytes", len(data))
+	}
+	return append([]byte{r.SlaveID, byte(FCReportSlaveID), byte(len(data))}, data...), nil
+}
+
+func (r *ReportSlaveIDResponse) Parse(frame []byte, req *BareRequest) error {
+	if err := checkResponseHeader(frame, req.header()); err != nil {
+		return err
+	}
+
+	// Frame layout
+	// [0] SlaveID
+	// [1] FunctionCode (17)
+	// [2] Byte count
+	// [3] Slave ID (device specific)
+	// [4] Run indicator status
+	// [n] Additional data
+	if len(frame) < 3 {
+		return fmt.Errorf("response frame too short: %d bytes", len(frame))
+	}
+	if byteCount := int(frame[2]); byteCount != len(frame)-3 {
+		return fmt.Errorf("ByteCount mismatch: header says %d, got %d", byteCount, len(frame)-3)
+	}
+
+	r.SlaveID = frame[0]
+	r.Data = frame[3:]
+	r.ID, r.Running, r.Additional = 0, false, nil
+	if len(r.Data) > 0 {
+		r.ID = r.Data[0]
+	}
+	if len(r.Data) > 1 {
+		r.Running = r.Data[1] == 0xFF
+		r.Additional = r.Data[2:]
+	}
+	return nil
+}
+
+func commStatus(busy bool) uint16 {
+	if busy {
+		return 0xFFFF
+	}
+	return 0x0000
+}
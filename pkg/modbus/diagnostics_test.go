@@ -0,0 +1,109 @@
+package modbus
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDiagnosticsRequest(t *testing.T) {
+	req := &DiagnosticsRequest{SlaveID: 0x01, SubFunction: DiagReturnQueryData, Data: []byte{0xA5, 0x37}}
+
+	expected := []byte{0x01, 0x08, 0x00, 0x00, 0xA5, 0x37}
+	frame, err := req.Build()
+	if err != nil {
+		t.Fatalf("DiagnosticsRequest Build() error: %v", err)
+	}
+	if !reflect.DeepEqual(frame, expected) {
+		t.Errorf("DiagnosticsRequest Build() failed.\nExpected: %v\nGot:      %v", expected, frame)
+	}
+
+	parsed := &DiagnosticsRequest{}
+	if err := parsed.Parse(frame); err != nil || !reflect.DeepEqual(parsed, req) {
+		t.Errorf("DiagnosticsRequest Parse() = %+v, %v", parsed, err)
+	}
+	if _, err := (&DiagnosticsRequest{Data: []byte{0x01}}).Build(); err == nil {
+		t.Error("expected error for odd data length, got nil")
+	}
+}
+
+func TestDiagnosticsResponseParse(t *testing.T) {
+	echo := &DiagnosticsRequest{SlaveID: 0x01, SubFunction: DiagReturnQueryData, Data: []byte{0xA5, 0x37}}
+	resp := &DiagnosticsResponse{}
+	if err := resp.Parse([]byte{0x01, 0x08, 0x00, 0x00, 0xA5, 0x37}, echo); err != nil {
+		t.Fatalf("DiagnosticsResponse Parse() error: %v", err)
+	}
+	if err := resp.Parse([]byte{0x01, 0x08, 0x00, 0x00, 0xA5, 0x36}, echo); err == nil {
+		t.Error("expected error for data not echoed, got nil")
+	}
+
+	counter := &DiagnosticsRequest{SlaveID: 0x01, SubFunction: DiagReturnBusMessageCount, Data: []byte{0x00, 0x00}}
+	if err := resp.Parse([]byte{0x01, 0x08, 0x00, 0x0B, 0x01, 0x2C}, counter); err != nil {
+		t.Fatalf("DiagnosticsResponse Parse() error: %v", err)
+	}
+	if v, err := resp.Value(); err != nil || v != 300 {
+		t.Errorf("Value() = %d, %v, expected 300", v, err)
+	}
+	if err := resp.Parse([]byte{0x01, 0x08, 0x00, 0x0C, 0x01, 0x2C}, counter); err == nil {
+		t.Error("expected error for sub-function mismatch, got nil")
+	}
+	if err := resp.Parse([]byte{0x01, 0x88, 0x01}, counter); !errors.Is(err, ErrIllegalFunction) {
+		t.Errorf("expected ErrIllegalFunction, got %v", err)
+	}
+}
+
+func TestBareResponses(t *testing.T) {
+	status := &ExceptionStatusResponse{}
+	if err := status.Parse([]byte{0x11, 0x07, 0x6D}, &BareRequest{SlaveID: 0x11, FC: FCReadExceptionStatus}); err != nil || status.Status != 0x6D {
+		t.Errorf("ExceptionStatusResponse Parse() = %+v, %v", status, err)
+	}
+
+	counter := &CommEventCounterResponse{}
+	if err := counter.Parse([]byte{0x11, 0x0B, 0xFF, 0xFF, 0x01, 0x08}, &BareRequest{SlaveID: 0x11, FC: FCGetCommEventCounter}); err != nil {
+		t.Fatalf("CommEventCounterResponse Parse() error: %v", err)
+	}
+	if !counter.Busy || counter.EventCount != 0x0108 {
+		t.Errorf("unexpected counter: %+v", counter)
+	}
+
+	// The example from the specification.
+	logFrame := []byte{0x11, 0x0C, 0x08, 0x00, 0x00, 0x01, 0x08, 0x01, 0x21, 0x20, 0x00}
+	log := &CommEventLogResponse{}
+	if err := log.Parse(logFrame, &BareRequest{SlaveID: 0x11, FC: FCGetCommEventLog}); err != nil {
+		t.Fatalf("CommEventLogResponse Parse() error: %v", err)
+	}
+	if log.Busy || log.EventCount != 0x0108 || log.MessageCount != 0x0121 || !reflect.DeepEqual(log.Events, []CommEvent{0x20, 0x00}) {
+		t.Errorf("unexpected log: %+v", log)
+	}
+	if built, err := log.Build(); err != nil || !reflect.DeepEqual(built, logFrame) {
+		t.Errorf("CommEventLogResponse Build() = %v, %v", built, err)
+	}
+
+	report := &ReportSlaveIDResponse{}
+	if err := report.Parse([]byte{0x11, 0x11, 0x05, 0x2A, 0xFF, 'P', 'M', '3'}, &BareRequest{SlaveID: 0x11, FC: FCReportSlaveID}); err != nil {
+		t.Fatalf("ReportSlaveIDResponse Parse() error: %v", err)
+	}
+	if report.ID != 0x2A || !report.Running || string(report.Additional) != "PM3" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	if _, err := (&BareRequest{SlaveID: 1, FC: FCReadHoldingRegisters}).Build(); err == nil {
+		t.Error("expected error for a function code that takes data, got nil")
+	}
+}
+
+func TestCommEventString(t *testing.T) {
+	tests := map[CommEvent]string{
+		0x00: "communications restart",
+		0x04: "entered listen only mode",
+		0xC2: "receive (communication error, broadcast)",
+		0x80: "receive",
+		0x44: "send (busy exception)",
+		0x61: "send (read exception, listen only)",
+	}
+	for e, expected := range tests {
+		if s := e.String(); s != expected {
+			t.Errorf("CommEvent(0x%02X).String() = %q, expected %q", byte(e), s, expected)
+		}
+	}
+}
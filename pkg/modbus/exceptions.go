@@ -30,6 +30,21 @@ var exceptionDescriptions = map[ModbusExceptionCode]string{
 	ExceptionGatewayTargetFailedToRespond: "Gateway Target Device Failed to Respond: No response was obtained from the target device.",
 }
 
+// Sentinel errors for each exception code. A *ModbusException returned by the
+// client matches the sentinel with the same code under errors.Is.
+var (
+	ErrIllegalFunction              error = &ModbusException{Code: ExceptionIllegalFunction}
+	ErrIllegalDataAddress           error = &ModbusException{Code: ExceptionIllegalDataAddress}
+	ErrIllegalDataValue             error = &ModbusException{Code: ExceptionIllegalDataValue}
+	ErrSlaveDeviceFailure           error = &ModbusException{Code: ExceptionSlaveDeviceFailure}
+	ErrAcknowledge                  error = &ModbusException{Code: ExceptionAcknowledge}
+	ErrSlaveDeviceBusy              error = &ModbusException{Code: ExceptionSlaveDeviceBusy}
+	ErrNegativeAcknowledge          error = &ModbusException{Code: ExceptionNegativeAcknowledge}
+	ErrMemoryParityError            error = &ModbusException{Code: ExceptionMemoryParityError}
+	ErrGatewayPathUnavailable       error = &ModbusException{Code: ExceptionGatewayPathUnavailable}
+	ErrGatewayTargetFailedToRespond error = &ModbusException{Code: ExceptionGatewayTargetFailedToRespond}
+)
+
 type ModbusException struct {
 	Code ModbusExceptionCode
 }
@@ -42,6 +57,48 @@ func (e *ModbusException) Error() string {
 	return fmt.Sprintf("Modbus Exception (0x%02X): %s", e.Code, desc)
 }
 
+// ExceptionResponse is the reply a slave sends when it cannot serve a request.
+type ExceptionResponse struct {
+	Header ModbusHeader
+	Code   ModbusExceptionCode
+}
+
+func (r *ExceptionResponse) Build() ([]byte, error) {
+	// Frame layout
+	// [0] SlaveID
+	// [1] FunctionCode | 0x80
+	// [2] Exception code
+	frame := make([]byte, 3)
+	frame[0] = r.Header.SlaveID
+	frame[1] = byte(r.Header.FC) | 0x80
+	frame[2] = byte(r.Code)
+
+	return frame, nil
+}
+
 func NewModbusException(code byte) error {
 	return &ModbusException{Code: ModbusExceptionCode(code)}
 }
+
+func (e *ModbusException) Is(target error) bool {
+	t, ok := target.(*ModbusException)
+	return ok && t.Code == e.Code
+}
+
+// IsException reports whether frame (unit ID onwards) is an exception
+// response, i.e. its function code has the high bit set.
+func IsException(frame []byte) bool {
+	return len(frame) >= 2 && frame[1]&0x80 != 0
+}
+
+// CheckException returns a *ModbusException if frame is an exception
+// response and nil otherwise.
+func CheckException(frame []byte) error {
+	if !IsException(frame) {
+		return nil
+	}
+	if len(frame) < 3 {
+		return fmt.Errorf("exception response too short: %d bytes", len(frame))
+	}
+	return NewModbusException(frame[2])
+}
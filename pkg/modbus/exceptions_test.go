@@ -1,6 +1,8 @@
 package modbus
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -67,3 +69,52 @@ func TestModbusException_Error(t *testing.T) {
 		}
 	}
 }
+
+func TestModbusException_Is(t *testing.T) {
+	err := fmt.Errorf("read failed: %w", NewModbusException(0x02))
+
+	if !errors.Is(err, ErrIllegalDataAddress) {
+		t.Errorf("expected error to match ErrIllegalDataAddress")
+	}
+	if errors.Is(err, ErrSlaveDeviceBusy) {
+		t.Errorf("expected error not to match ErrSlaveDeviceBusy")
+	}
+
+	var exc *ModbusException
+	if !errors.As(err, &exc) || exc.Code != ExceptionIllegalDataAddress {
+		t.Errorf("expected errors.As to yield code 0x02, got %v", exc)
+	}
+}
+
+func TestCheckException(t *testing.T) {
+	if err := CheckException([]byte{0x01, 0x03, 0x02, 0x00, 0x01}); err != nil {
+		t.Errorf("expected nil for normal response, got %v", err)
+	}
+
+	err := CheckException([]byte{0x01, 0x83, 0x06})
+	if !errors.Is(err, ErrSlaveDeviceBusy) {
+		t.Errorf("expected ErrSlaveDeviceBusy, got %v", err)
+	}
+
+	if err := CheckException([]byte{0x01, 0x83}); err == nil {
+		t.Error("expected error for truncated exception response, got nil")
+	}
+}
+
+func TestExceptionResponseBuild(t *testing.T) {
+	r := &ExceptionResponse{
+		Header: NewModbusHeader(FCReadHoldingRegisters, 0x0A, 0x0000),
+		Code:   ExceptionIllegalDataAddress,
+	}
+
+	frame, err := r.Build()
+	if err != nil {
+		t.Fatalf("ExceptionResponse Build() error: %v", err)
+	}
+	if len(frame) != 3 || frame[0] != 0x0A || frame[1] != 0x83 || frame[2] != 0x02 {
+		t.Errorf("unexpected exception frame: %v", frame)
+	}
+	if !errors.Is(CheckException(frame), ErrIllegalDataAddress) {
+		t.Errorf("expected built frame to parse back as ErrIllegalDataAddress")
+	}
+}
@@ -0,0 +1,317 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// FileRecordReferenceType is the only reference type file record requests
+// may carry.
+const FileRecordReferenceType byte = 0x06
+
+// A file holds up to 10000 records of one register each. MaxReadFileRecords
+// and MaxWriteFileRecords are the most records a single sub-request can carry
+// within the PDU size limit.
+const (
+	MaxFileRecordNumber uint16 = 0x270F
+	MaxReadFileRecords  uint16 = 121
+	MaxWriteFileRecords uint16 = 122
+)
+
+// FileRecordRef addresses RecordLength records of a file, from RecordNumber
+// on: one sub-request of a ReadFileRecordRequest.
+type FileRecordRef struct {
+	FileNumber   uint16
+	RecordNumber uint16
+	RecordLength uint16
+}
+
+// FileRecord is a run of records of a file and their content, two bytes per
+// record.
+type FileRecord struct {
+	FileNumber   uint16
+	RecordNumber uint16
+	Data         []byte
+}
+
+// ReadFileRecordRequest is FC 20. Each reference is a sub-request of its own,
+// answered in order.
+type ReadFileRecordRequest struct {
+	SlaveID byte
+	Refs    []FileRecordRef
+}
+
+// ReadFileRecordResponse is the reply to a ReadFileRecordRequest: one record
+// per reference, in order. The reply does not carry file and record numbers;
+// Parse copies them from the request.
+type ReadFileRecordResponse struct {
+	SlaveID byte
+	Records []FileRecord
+}
+
+// WriteFileRecordRequest is FC 21. Each record is a sub-request of its own.
+type WriteFileRecordRequest struct {
+	SlaveID byte
+	Records []FileRecord
+}
+
+// WriteFileRecordResponse is the reply to a WriteFileRecordRequest, which
+// echoes it.
+type WriteFileRecordResponse struct {
+	SlaveID byte
+	Records []FileRecord
+}
+
+// checkFileRecord verifies that length records from record on lie in a file.
+func checkFileRecord(file, record uint16, length int) error {
+	if file == 0 {
+		return fmt.Errorf("invalid file number: 0")
+	}
+	if length == 0 {
+		return fmt.Errorf("file %d record %d: empty record", file, record)
+	}
+	if int(record)+length-1 > int(MaxFileRecordNumber) {
+		return fmt.Errorf("file %d: records %d-%d out of range (max %d)", file, record, int(record)+length-1, MaxFileRecordNumber)
+	}
+	return nil
+}
+
+func (r *ReadFileRecordRequest) header() ModbusHeader {
+	return ModbusHeader{FC: FCReadFileRecord, SlaveID: r.SlaveID}
+}
+
+func (r *ReadFileRecordRequest) Build() ([]byte, error) {
+	if len(r.Refs) == 0 {
+		return nil, fmt.Errorf("no file record to read")
+	}
+
+	// The reply holds two bytes per sub-request plus the records.
+	replyLength := 0
+	for _, ref := range r.Refs {
+		if err := checkFileRecord(ref.FileNumber, ref.RecordNumber, int(ref.RecordLength)); err != nil {
+			return nil, err
+		}
+		replyLength += 2 + 2*int(ref.RecordLength)
+	}
+	if 7*len(r.Refs) > 0xF5 {
+		return nil, fmt.Errorf("too many sub-requests: %d (max %d)", len(r.Refs), 0xF5/7)
+	}
+	if replyLength > 0xF5 {
+		return nil, fmt.Errorf("records do not fit in a reply: %d bytes (max %d)", replyLength, 0xF5)
+	}
+
+	// Frame layout (Modbus PDU):
+	//   [0]   SlaveID
+	//   [1]   FunctionCode (20)
+	//   [2]   Byte count
+	//   then for each sub-request:
+	//   [0]   Reference type (6)
+	//   [1-2] File number
+	//   [3-4] Record number
+	//   [5-6] Record length
+	frame := []byte{r.SlaveID, byte(FCReadFileRecord), byte(7 * len(r.Refs))}
+	for _, ref := range r.Refs {
+		frame = append(frame, FileRecordReferenceType)
+		frame = binary.BigEndian.AppendUint16(frame, ref.FileNumber)
+		frame = binary.BigEndian.AppendUint16(frame, ref.RecordNumber)
+		frame = binary.BigEndian.AppendUint16(frame, ref.RecordLength)
+	}
+
+	return frame, nil
+}
+
+// Parse decodes a Read File Record request frame, as received by a slave.
+func (r *ReadFileRecordRequest) Parse(frame []byte) error {
+	if len(frame) < 3 || int(frame[2]) != len(frame)-3 || frame[2]%7 != 0 {
+		return fmt.Errorf("invalid Read File Record request length: %d bytes", len(frame))
+	}
+
+	refs := make([]FileRecordRef, 0, frame[2]/7)
+	for i := 3; i < len(frame); i += 7 {
+		if frame[i] != FileRecordReferenceType {
+			return fmt.Errorf("invalid reference type: %d", frame[i])
+		}
+		refs = append(refs, FileRecordRef{
+			FileNumber:   binary.BigEndian.Uint16(frame[i+1 : i+3]),
+			RecordNumber: binary.BigEndian.Uint16(frame[i+3 : i+5]),
+			RecordLength: binary.BigEndian.Uint16(frame[i+5 : i+7]),
+		})
+	}
+
+	r.SlaveID = frame[0]
+	r.Refs = refs
+	return nil
+}
+
+func (r *ReadFileRecordResponse) Build() ([]byte, error) {
+	// Frame layout
+	// [0] SlaveID
+	// [1] FunctionCode (20)
+	// [2] Response data length
+	// then for each sub-request:
+	// [0] File response length (reference type and data)
+	// [1] Reference type (6)
+	// [n] Record data
+	frame := []byte{r.SlaveID, byte(FCReadFileRecord), 0}
+	for _, rec := range r.Records {
+		if len(rec.Data)%2 != 0 || len(rec.Data) > 2*int(MaxReadFileRecords) {
+			return nil, fmt.Errorf("invalid record data length: %d bytes", len(rec.Data))
+		}
+		frame = append(frame, byte(1+len(rec.Data)), FileRecordReferenceType)
+		frame = append(frame, rec.Data...)
+	}
+	if len(frame)-3 > 0xF5 {
+		return nil, fmt.Errorf("records do not fit in a reply: %d bytes", len(frame)-3)
+	}
+	frame[2] = byte(len(frame) - 3)
+
+	return frame, nil
+}
+
+func (r *ReadFileRecordResponse) Parse(frame []byte, req *ReadFileRecordRequest) error {
+	if err := checkResponseHeader(frame, req.header()); err != nil {
+		return err
+	}
+	if len(frame) < 3 {
+		return fmt.Errorf("response frame too short: %d bytes", len(frame))
+	}
+	if int(frame[2]) != len(frame)-3 {
+		return fmt.Errorf("ByteCount mismatch: header says %d, got %d", frame[2], len(frame)-3)
+	}
+
+	records := make([]FileRecord, 0, len(req.Refs))
+	i := 3
+	for _, ref := range req.Refs {
+		if i+2 > len(frame) {
+			return fmt.Errorf("response truncated: %d of %d sub-responses", len(records), len(req.Refs))
+		}
+		length := int(frame[i])
+		if frame[i+1] != FileRecordReferenceType {
+			return fmt.Errorf("invalid reference type: %d", frame[i+1])
+		}
+		if length != 1+2*int(ref.RecordLength) {
+			return fmt.Errorf("file %d record %d: expected %d records, got %d bytes", ref.FileNumber, ref.RecordNumber, ref.RecordLength, length-1)
+		}
+		if i+1+length > len(frame) {
+			return fmt.Errorf("response truncated in file %d record %d", ref.FileNumber, ref.RecordNumber)
+		}
+		records = append(records, FileRecord{FileNumber: ref.FileNumber, RecordNumber: ref.RecordNumber, Data: frame[i+2 : i+1+length]})
+		i += 1 + length
+	}
+	if i != len(frame) {
+		return fmt.Errorf("%d unexpected bytes after the last sub-response", len(frame)-i)
+	}
+
+	r.SlaveID = frame[0]
+	r.Records = records
+	return nil
+}
+
+func (r *WriteFileRecordRequest) header() ModbusHeader {
+	return ModbusHeader{FC: FCWriteFileRecord, SlaveID: r.SlaveID}
+}
+
+func (r *WriteFileRecordRequest) Build() ([]byte, error) {
+	if len(r.Records) == 0 {
+		return nil, fmt.Errorf("no file record to write")
+	}
+
+	// Frame layout (Modbus PDU):
+	//   [0]    SlaveID
+	//   [1]    FunctionCode (21)
+	//   [2]    Request data length
+	//   then for each sub-request:
+	//   [0]    Reference type (6)
+	//   [1-2]  File number
+	//   [3-4]  Record number
+	//   [5-6]  Record length
+	//   [7...] Record data
+	frame := []byte{r.SlaveID, byte(FCWriteFileRecord), 0}
+	for _, rec := range r.Records {
+		if len(rec.Data)%2 != 0 {
+			return nil, fmt.Errorf("file %d record %d: data must be whole records, got %d bytes", rec.FileNumber, rec.RecordNumber, len(rec.Data))
+		}
+		if err := checkFileRecord(rec.FileNumber, rec.RecordNumber, len(rec.Data)/2); err != nil {
+			return nil, err
+		}
+		frame = append(frame, FileRecordReferenceType)
+		frame = binary.BigEndian.AppendUint16(frame, rec.FileNumber)
+		frame = binary.BigEndian.AppendUint16(frame, rec.RecordNumber)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(rec.Data)/2))
+		frame = append(frame, rec.Data...)
+	}
+	if len(frame)-3 > 0xFB {
+		return nil, fmt.Errorf("records do not fit in a request: %d bytes (max %d)", len(frame)-3, 0xFB)
+	}
+	frame[2] = byte(len(frame) - 3)
+
+	return frame, nil
+}
+
+// Parse decodes a Write File Record request frame, as received by a slave.
+func (r *WriteFileRecordRequest) Parse(frame []byte) error {
+	records, err := parseFileRecords(frame)
+	if err != nil {
+		return err
+	}
+	r.SlaveID = frame[0]
+	r.Records = records
+	return nil
+}
+
+func (r *WriteFileRecordResponse) Build() ([]byte, error) {
+	req := &WriteFileRecordRequest{SlaveID: r.SlaveID, Records: r.Records}
+	return req.Build()
+}
+
+func (r *WriteFileRecordResponse) Parse(frame []byte, req *WriteFileRecordRequest) error {
+	if err := checkResponseHeader(frame, req.header()); err != nil {
+		return err
+	}
+	expected, err := req.Build()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(frame, expected) {
+		return fmt.Errorf("response does not echo the request")
+	}
+
+	records, err := parseFileRecords(frame)
+	if err != nil {
+		return err
+	}
+	r.SlaveID = frame[0]
+	r.Records = records
+	return nil
+}
+
+// parseFileRecords walks the sub-requests of a Write File Record frame, which
+// its reply echoes.
+func parseFileRecords(frame []byte) ([]FileRecord, error) {
+	if len(frame) < 3 || int(frame[2]) != len(frame)-3 {
+		return nil, fmt.Errorf("invalid Write File Record length: %d bytes", len(frame))
+	}
+
+	var records []FileRecord
+	for i := 3; i < len(frame); {
+		if i+7 > len(frame) {
+			return nil, fmt.Errorf("frame truncated in sub-request %d", len(records)+1)
+		}
+		if frame[i] != FileRecordReferenceType {
+			return nil, fmt.Errorf("invalid reference type: %d", frame[i])
+		}
+		rec := FileRecord{
+			FileNumber:   binary.BigEndian.Uint16(frame[i+1 : i+3]),
+			RecordNumber: binary.BigEndian.Uint16(frame[i+3 : i+5]),
+		}
+		end := i + 7 + 2*int(binary.BigEndian.Uint16(frame[i+5:i+7]))
+		if end > len(frame) {
+			return nil, fmt.Errorf("frame truncated in file %d record %d", rec.FileNumber, rec.RecordNumber)
+		}
+		rec.Data = frame[i+7 : end]
+		records = append(records, rec)
+		i = end
+	}
+	return records, nil
+}
@@ -0,0 +1,126 @@
+package modbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadFileRecordRequest(t *testing.T) {
+	// The example from the specification: two groups from file 4 and 3.
+	req := &ReadFileRecordRequest{SlaveID: 0x01, Refs: []FileRecordRef{
+		{FileNumber: 4, RecordNumber: 1, RecordLength: 2},
+		{FileNumber: 3, RecordNumber: 9, RecordLength: 2},
+	}}
+
+	expected := []byte{0x01, 0x14, 0x0E, 0x06, 0x00, 0x04, 0x00, 0x01, 0x00, 0x02, 0x06, 0x00, 0x03, 0x00, 0x09, 0x00, 0x02}
+	frame, err := req.Build()
+	if err != nil {
+		t.Fatalf("ReadFileRecordRequest Build() error: %v", err)
+	}
+	if !reflect.DeepEqual(frame, expected) {
+		t.Errorf("ReadFileRecordRequest Build() failed.\nExpected: %v\nGot:      %v", expected, frame)
+	}
+
+	parsed := &ReadFileRecordRequest{}
+	if err := parsed.Parse(frame); err != nil || !reflect.DeepEqual(parsed, req) {
+		t.Errorf("ReadFileRecordRequest Parse() = %+v, %v", parsed, err)
+	}
+
+	invalid := []*ReadFileRecordRequest{
+		{SlaveID: 0x01},
+		{SlaveID: 0x01, Refs: []FileRecordRef{{FileNumber: 0, RecordNumber: 0, RecordLength: 1}}},
+		{SlaveID: 0x01, Refs: []FileRecordRef{{FileNumber: 1, RecordNumber: 0, RecordLength: 0}}},
+		{SlaveID: 0x01, Refs: []FileRecordRef{{FileNumber: 1, RecordNumber: 9999, RecordLength: 2}}},
+		{SlaveID: 0x01, Refs: []FileRecordRef{{FileNumber: 1, RecordNumber: 0, RecordLength: MaxReadFileRecords + 1}}},
+	}
+	for _, r := range invalid {
+		if _, err := r.Build(); err == nil {
+			t.Errorf("expected error for %+v, got nil", r.Refs)
+		}
+	}
+
+	if _, err := (&ReadFileRecordRequest{Refs: []FileRecordRef{{FileNumber: 1, RecordLength: MaxReadFileRecords}}}).Build(); err != nil {
+		t.Errorf("expected %d records to fit, got %v", MaxReadFileRecords, err)
+	}
+}
+
+func TestReadFileRecordResponse(t *testing.T) {
+	req := &ReadFileRecordRequest{SlaveID: 0x01, Refs: []FileRecordRef{
+		{FileNumber: 4, RecordNumber: 1, RecordLength: 2},
+		{FileNumber: 3, RecordNumber: 9, RecordLength: 2},
+	}}
+	frame := []byte{0x01, 0x14, 0x0C, 0x05, 0x06, 0x0D, 0xFE, 0x00, 0x20, 0x05, 0x06, 0x33, 0xCD, 0x00, 0x40}
+
+	resp := &ReadFileRecordResponse{}
+	if err := resp.Parse(frame, req); err != nil {
+		t.Fatalf("ReadFileRecordResponse Parse() error: %v", err)
+	}
+	expected := []FileRecord{
+		{FileNumber: 4, RecordNumber: 1, Data: []byte{0x0D, 0xFE, 0x00, 0x20}},
+		{FileNumber: 3, RecordNumber: 9, Data: []byte{0x33, 0xCD, 0x00, 0x40}},
+	}
+	if !reflect.DeepEqual(resp.Records, expected) {
+		t.Errorf("ReadFileRecordResponse Parse() = %+v, expected %+v", resp.Records, expected)
+	}
+
+	built, err := resp.Build()
+	if err != nil || !reflect.DeepEqual(built, frame) {
+		t.Errorf("ReadFileRecordResponse Build() = %v, %v", built, err)
+	}
+
+	if err := resp.Parse(frame[:len(frame)-2], req); err == nil {
+		t.Error("expected error for truncated response, got nil")
+	}
+	short := &ReadFileRecordRequest{SlaveID: 0x01, Refs: req.Refs[:1]}
+	if err := resp.Parse(frame, short); err == nil {
+		t.Error("expected error for extra sub-response, got nil")
+	}
+	if err := resp.Parse([]byte{0x01, 0x94, 0x02}, req); err == nil {
+		t.Error("expected exception error, got nil")
+	}
+}
+
+func TestWriteFileRecord(t *testing.T) {
+	// The example from the specification.
+	req := &WriteFileRecordRequest{SlaveID: 0x01, Records: []FileRecord{
+		{FileNumber: 4, RecordNumber: 7, Data: []byte{0x06, 0xAF, 0x04, 0xBE, 0x10, 0x0D}},
+	}}
+
+	expected := []byte{0x01, 0x15, 0x0D, 0x06, 0x00, 0x04, 0x00, 0x07, 0x00, 0x03, 0x06, 0xAF, 0x04, 0xBE, 0x10, 0x0D}
+	frame, err := req.Build()
+	if err != nil {
+		t.Fatalf("WriteFileRecordRequest Build() error: %v", err)
+	}
+	if !reflect.DeepEqual(frame, expected) {
+		t.Errorf("WriteFileRecordRequest Build() failed.\nExpected: %v\nGot:      %v", expected, frame)
+	}
+
+	parsed := &WriteFileRecordRequest{}
+	if err := parsed.Parse(frame); err != nil || !reflect.DeepEqual(parsed, req) {
+		t.Errorf("WriteFileRecordRequest Parse() = %+v, %v", parsed, err)
+	}
+
+	resp := &WriteFileRecordResponse{}
+	if err := resp.Parse(frame, req); err != nil || !reflect.DeepEqual(resp.Records, req.Records) {
+		t.Errorf("WriteFileRecordResponse Parse() = %+v, %v", resp, err)
+	}
+	if built, err := resp.Build(); err != nil || !reflect.DeepEqual(built, frame) {
+		t.Errorf("WriteFileRecordResponse Build() = %v, %v", built, err)
+	}
+
+	altered := append([]byte(nil), frame...)
+	altered[len(altered)-1] = 0x0E
+	if err := resp.Parse(altered, req); err == nil {
+		t.Error("expected error for a reply that does not echo the request, got nil")
+	}
+
+	if _, err := (&WriteFileRecordRequest{Records: []FileRecord{{FileNumber: 1, Data: []byte{0x01}}}}).Build(); err == nil {
+		t.Error("expected error for odd data length, got nil")
+	}
+	if _, err := (&WriteFileRecordRequest{Records: []FileRecord{{FileNumber: 1, Data: make([]byte, 2*(MaxWriteFileRecords+1))}}}).Build(); err == nil {
+		t.Error("expected error for oversized request, got nil")
+	}
+	if _, err := (&WriteFileRecordRequest{Records: []FileRecord{{FileNumber: 1, Data: make([]byte, 2*MaxWriteFileRecords)}}}).Build(); err != nil {
+		t.Errorf("expected %d records to fit, got %v", MaxWriteFileRecords, err)
+	}
+}
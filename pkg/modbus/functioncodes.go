@@ -1,17 +1,27 @@
 package modbus
 
-//FC type
+// FC type
 type FunctionCode byte
 
 const (
-	FCReadCoils               FunctionCode = 1
-	FCReadInputStatus         FunctionCode = 2
-	FCReadHoldingRegisters    FunctionCode = 3
-	FCReadInputRegisters      FunctionCode = 4
-	FCForceSingleCoil         FunctionCode = 5
-	FCPresetSingleRegister    FunctionCode = 6
-	FCForceMultipleCoils      FunctionCode = 15
-	FCPresetMultipleRegisters FunctionCode = 16
+	FCReadCoils                  FunctionCode = 1
+	FCReadInputStatus            FunctionCode = 2
+	FCReadHoldingRegisters       FunctionCode = 3
+	FCReadInputRegisters         FunctionCode = 4
+	FCForceSingleCoil            FunctionCode = 5
+	FCPresetSingleRegister       FunctionCode = 6
+	FCReadExceptionStatus        FunctionCode = 7
+	FCDiagnostics                FunctionCode = 8
+	FCGetCommEventCounter        FunctionCode = 11
+	FCGetCommEventLog            FunctionCode = 12
+	FCForceMultipleCoils         FunctionCode = 15
+	FCPresetMultipleRegisters    FunctionCode = 16
+	FCReportSlaveID              FunctionCode = 17
+	FCReadFileRecord             FunctionCode = 20
+	FCWriteFileRecord            FunctionCode = 21
+	FCMaskWriteRegister          FunctionCode = 22
+	FCReadWriteMultipleRegisters FunctionCode = 23
+	FCEncapsulatedInterface      FunctionCode = 43
 )
 
 func (fc FunctionCode) String() string {
@@ -28,10 +38,30 @@ func (fc FunctionCode) String() string {
 		return "Force Single Coil"
 	case FCPresetSingleRegister:
 		return "Preset Single Register"
+	case FCReadExceptionStatus:
+		return "Read Exception Status"
+	case FCDiagnostics:
+		return "Diagnostics"
+	case FCGetCommEventCounter:
+		return "Get Comm Event Counter"
+	case FCGetCommEventLog:
+		return "Get Comm Event Log"
 	case FCForceMultipleCoils:
 		return "Force Multiple Coils"
 	case FCPresetMultipleRegisters:
 		return "Preset Multiple Registers"
+	case FCReportSlaveID:
+		return "Report Slave ID"
+	case FCReadFileRecord:
+		return "Read File Record"
+	case FCWriteFileRecord:
+		return "Write File Record"
+	case FCMaskWriteRegister:
+		return "Mask Write Register"
+	case FCReadWriteMultipleRegisters:
+		return "Read/Write Multiple Registers"
+	case FCEncapsulatedInterface:
+		return "Encapsulated Interface Transport"
 	default:
 		return "Unknown Function Code"
 	}
@@ -0,0 +1,216 @@
+package modbus_client
+
+import (
+	"context"
+	"errors"
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/client"
+)
+
+// Serial line diagnostics. These function codes are meant for devices on a
+// serial line, but gateways often pass them through from Modbus TCP.
+
+// ReadExceptionStatus returns the eight device-defined status bits of FC 7.
+func (c *ModbusClient) ReadExceptionStatus(unitID byte) (byte, error) {
+	return c.ReadExceptionStatusContext(context.Background(), unitID)
+}
+
+// Diagnostic runs an FC 8 sub-function and returns the data of the reply.
+func (c *ModbusClient) Diagnostic(unitID byte, sub modbus.DiagnosticSubFunction, data []byte) ([]byte, error) {
+	return c.DiagnosticContext(context.Background(), unitID, sub, data)
+}
+
+// ReturnQueryData sends data to be echoed back, to test the line.
+func (c *ModbusClient) ReturnQueryData(unitID byte, data []byte) error {
+	return c.ReturnQueryDataContext(context.Background(), unitID, data)
+}
+
+// RestartCommunications restarts the serial port of the device and takes it
+// out of listen only mode. If clearLog is set the comm event log is cleared
+// too.
+func (c *ModbusClient) RestartCommunications(unitID byte, clearLog bool) error {
+	return c.RestartCommunicationsContext(context.Background(), unitID, clearLog)
+}
+
+// ReadDiagnosticRegister returns the device-defined diagnostic register.
+func (c *ModbusClient) ReadDiagnosticRegister(unitID byte) (uint16, error) {
+	return c.ReadDiagnosticRegisterContext(context.Background(), unitID)
+}
+
+// ClearCounters clears the diagnostic counters and register.
+func (c *ModbusClient) ClearCounters(unitID byte) error {
+	return c.ClearCountersContext(context.Background(), unitID)
+}
+
+// ForceListenOnly makes the device stop answering, and sending, until it is
+// sent RestartCommunications. The device does not reply, so this returns
+// once the client timeout expires. If ctx ends first, its error is returned,
+// as the request may never have been sent.
+func (c *ModbusClient) ForceListenOnly(unitID byte) error {
+	return c.ForceListenOnlyContext(context.Background(), unitID)
+}
+
+// ReadDiagnosticCounters reads every bus and slave counter, one request each.
+func (c *ModbusClient) ReadDiagnosticCounters(unitID byte) (*modbus.DiagnosticCounters, error) {
+	return c.ReadDiagnosticCountersContext(context.Background(), unitID)
+}
+
+// GetCommEventCounter returns the FC 11 event counter of the device.
+func (c *ModbusClient) GetCommEventCounter(unitID byte) (*modbus.CommEventCounterResponse, error) {
+	return c.GetCommEventCounterContext(context.Background(), unitID)
+}
+
+// GetCommEventLog returns the FC 12 event log of the device.
+func (c *ModbusClient) GetCommEventLog(unitID byte) (*modbus.CommEventLogResponse, error) {
+	return c.GetCommEventLogContext(context.Background(), unitID)
+}
+
+// ReportSlaveID returns the FC 17 description of the device.
+func (c *ModbusClient) ReportSlaveID(unitID byte) (*modbus.ReportSlaveIDResponse, error) {
+	return c.ReportSlaveIDContext(context.Background(), unitID)
+}
+
+func (c *ModbusClient) ReadExceptionStatusContext(ctx context.Context, unitID byte) (byte, error) {
+	resp := &modbus.ExceptionStatusResponse{}
+	if err := c.bare(ctx, unitID, modbus.FCReadExceptionStatus, resp.Parse); err != nil {
+		return 0, err
+	}
+	return resp.Status, nil
+}
+
+func (c *ModbusClient) DiagnosticContext(ctx context.Context, unitID byte, sub modbus.DiagnosticSubFunction, data []byte) ([]byte, error) {
+	resp, err := c.diagnostic(ctx, unitID, sub, data)
+	if err != nil || resp == nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+func (c *ModbusClient) ReturnQueryDataContext(ctx context.Context, unitID byte, data []byte) error {
+	_, err := c.diagnostic(ctx, unitID, modbus.DiagReturnQueryData, data)
+	return err
+}
+
+func (c *ModbusClient) RestartCommunicationsContext(ctx context.Context, unitID byte, clearLog bool) error {
+	data := []byte{0x00, 0x00}
+	if clearLog {
+		data[0] = 0xFF
+	}
+	_, err := c.diagnostic(ctx, unitID, modbus.DiagRestartCommunications, data)
+	return err
+}
+
+func (c *ModbusClient) ReadDiagnosticRegisterContext(ctx context.Context, unitID byte) (uint16, error) {
+	return c.diagnosticValue(ctx, unitID, modbus.DiagReturnDiagnosticRegister)
+}
+
+func (c *ModbusClient) ClearCountersContext(ctx context.Context, unitID byte) error {
+	_, err := c.diagnostic(ctx, unitID, modbus.DiagClearCounters, []byte{0x00, 0x00})
+	return err
+}
+
+func (c *ModbusClient) ForceListenOnlyContext(ctx context.Context, unitID byte) error {
+	_, err := c.diagnostic(ctx, unitID, modbus.DiagForceListenOnly, []byte{0x00, 0x00})
+	if errors.Is(err, client.ErrTimeout) {
+		return nil
+	}
+	return err
+}
+
+func (c *ModbusClient) ReadDiagnosticCountersContext(ctx context.Context, unitID byte) (*modbus.DiagnosticCounters, error) {
+	counters := &modbus.DiagnosticCounters{}
+	for _, counter := range []struct {
+		sub   modbus.DiagnosticSubFunction
+		value *uint16
+	}{
+		{modbus.DiagReturnBusMessageCount, &counters.BusMessages},
+		{modbus.DiagReturnBusCommErrorCount, &counters.BusCommErrors},
+		{modbus.DiagReturnBusExceptionErrorCount, &counters.BusExceptionErrors},
+		{modbus.DiagReturnSlaveMessageCount, &counters.SlaveMessages},
+		{modbus.DiagReturnSlaveNoResponseCount, &counters.SlaveNoResponses},
+		{modbus.DiagReturnSlaveNAKCount, &counters.SlaveNAKs},
+		{modbus.DiagReturnSlaveBusyCount, &counters.SlaveBusy},
+		{modbus.DiagReturnBusCharacterOverrunCount, &counters.BusCharacterOverruns},
+	} {
+		value, err := c.diagnosticValue(ctx, unitID, counter.sub)
+		if err != nil {
+			return nil, err
+		}
+		*counter.value = value
+	}
+	return counters, nil
+}
+
+func (c *ModbusClient) GetCommEventCounterContext(ctx context.Context, unitID byte) (*modbus.CommEventCounterResponse, error) {
+	resp := &modbus.CommEventCounterResponse{}
+	if err := c.bare(ctx, unitID, modbus.FCGetCommEventCounter, resp.Parse); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ModbusClient) GetCommEventLogContext(ctx context.Context, unitID byte) (*modbus.CommEventLogResponse, error) {
+	resp := &modbus.CommEventLogResponse{}
+	if err := c.bare(ctx, unitID, modbus.FCGetCommEventLog, resp.Parse); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ModbusClient) ReportSlaveIDContext(ctx context.Context, unitID byte) (*modbus.ReportSlaveIDResponse, error) {
+	resp := &modbus.ReportSlaveIDResponse{}
+	if err := c.bare(ctx, unitID, modbus.FCReportSlaveID, resp.Parse); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// bare sends a request without data and hands the reply to parse.
+func (c *ModbusClient) bare(ctx context.Context, unitID byte, fc modbus.FunctionCode, parse func([]byte, *modbus.BareRequest) error) error {
+	req := &modbus.BareRequest{SlaveID: unitID, FC: fc}
+	frame, err := req.Build()
+	if err != nil {
+		return err
+	}
+
+	respFrame, err := c.send(ctx, frame, false)
+	if err != nil {
+		return err
+	}
+	return parse(respFrame, req)
+}
+
+// diagnostic runs an FC 8 sub-function. Only the sub-functions that read are
+// retried.
+func (c *ModbusClient) diagnostic(ctx context.Context, unitID byte, sub modbus.DiagnosticSubFunction, data []byte) (*modbus.DiagnosticsResponse, error) {
+	req := &modbus.DiagnosticsRequest{SlaveID: unitID, SubFunction: sub, Data: data}
+	frame, err := req.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	write := sub == modbus.DiagRestartCommunications || sub == modbus.DiagChangeASCIIDelimiter ||
+		sub == modbus.DiagForceListenOnly || sub == modbus.DiagClearCounters || sub == modbus.DiagClearOverrunCounter
+	respFrame, err := c.send(ctx, frame, write)
+	if err != nil || respFrame == nil {
+		// A nil reply means a broadcast, which is never answered.
+		return nil, err
+	}
+
+	resp := &modbus.DiagnosticsResponse{}
+	if err := resp.Parse(respFrame, req); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ModbusClient) diagnosticValue(ctx context.Context, unitID byte, sub modbus.DiagnosticSubFunction) (uint16, error) {
+	resp, err := c.diagnostic(ctx, unitID, sub, []byte{0x00, 0x00})
+	if err != nil {
+		return 0, err
+	}
+	if resp == nil {
+		return 0, errors.New("counters cannot be read with a broadcast")
+	}
+	return resp.Value()
+}
@@ -0,0 +1,157 @@
+package modbus_client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"modbus_client/pkg/modbus"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// diagnosticsDevice answers the serial line diagnostics function codes as a
+// device whose counters hold ten times their sub-function code. It does not
+// answer Force Listen Only, and returns nil then.
+func diagnosticsDevice(frame []byte) []byte {
+	switch modbus.FunctionCode(frame[1]) {
+	case modbus.FCReadExceptionStatus:
+		return []byte{frame[0], frame[1], 0x6D}
+	case modbus.FCDiagnostics:
+		sub := modbus.DiagnosticSubFunction(binary.BigEndian.Uint16(frame[2:4]))
+		switch {
+		case sub == modbus.DiagForceListenOnly:
+			return nil
+		case sub >= modbus.DiagReturnBusMessageCount && sub <= modbus.DiagReturnBusCharacterOverrunCount:
+			return []byte{frame[0], frame[1], frame[2], frame[3], 0x00, byte(sub) * 10}
+		}
+		return frame
+	case modbus.FCGetCommEventCounter:
+		return []byte{frame[0], frame[1], 0x00, 0x00, 0x01, 0x08}
+	case modbus.FCGetCommEventLog:
+		return []byte{frame[0], frame[1], 0x08, 0x00, 0x00, 0x01, 0x08, 0x01, 0x21, 0xA0, 0x00}
+	case modbus.FCReportSlaveID:
+		return []byte{frame[0], frame[1], 0x05, 0x2A, 0xFF, 'P', 'M', '3'}
+	}
+	return []byte{frame[0], frame[1] | 0x80, 0x01}
+}
+
+func TestModbusClient_Diagnostics(t *testing.T) {
+	var requests [][]byte
+	hang := make(chan struct{})
+	defer close(hang)
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		requests = append(requests, append([]byte(nil), frame...))
+		reply := diagnosticsDevice(frame)
+		if reply == nil {
+			<-hang
+		}
+		return reply
+	})
+	defer stop()
+
+	if status, err := c.ReadExceptionStatus(0x11); err != nil || status != 0x6D {
+		t.Errorf("ReadExceptionStatus() = 0x%02X, %v", status, err)
+	}
+
+	counters, err := c.ReadDiagnosticCounters(0x11)
+	if err != nil {
+		t.Fatalf("ReadDiagnosticCounters() error: %v", err)
+	}
+	expected := &modbus.DiagnosticCounters{
+		BusMessages:          110,
+		BusCommErrors:        120,
+		BusExceptionErrors:   130,
+		SlaveMessages:        140,
+		SlaveNoResponses:     150,
+		SlaveNAKs:            160,
+		SlaveBusy:            170,
+		BusCharacterOverruns: 180,
+	}
+	if !reflect.DeepEqual(counters, expected) {
+		t.Errorf("ReadDiagnosticCounters() = %+v, expected %+v", counters, expected)
+	}
+
+	if err := c.ReturnQueryData(0x11, []byte{0xA5, 0x37}); err != nil {
+		t.Errorf("ReturnQueryData() error: %v", err)
+	}
+	requests = nil
+	if err := c.RestartCommunications(0x11, true); err != nil {
+		t.Errorf("RestartCommunications() error: %v", err)
+	}
+	if !reflect.DeepEqual(requests, [][]byte{{0x11, 0x08, 0x00, 0x01, 0xFF, 0x00}}) {
+		t.Errorf("unexpected restart request: %v", requests)
+	}
+
+	if counter, err := c.GetCommEventCounter(0x11); err != nil || counter.Busy || counter.EventCount != 0x0108 {
+		t.Errorf("GetCommEventCounter() = %+v, %v", counter, err)
+	}
+	log, err := c.GetCommEventLog(0x11)
+	if err != nil {
+		t.Fatalf("GetCommEventLog() error: %v", err)
+	}
+	if log.MessageCount != 0x0121 || len(log.Events) != 2 || !log.Events[0].IsReceive() || log.Events[1] != modbus.CommEventRestart {
+		t.Errorf("unexpected event log: %+v", log)
+	}
+	if report, err := c.ReportSlaveID(0x11); err != nil || report.ID != 0x2A || !report.Running {
+		t.Errorf("ReportSlaveID() = %+v, %v", report, err)
+	}
+
+	// Only the client timeout means the request went out unanswered; a ctx
+	// that has already expired must be reported.
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	if err := c.ForceListenOnlyContext(ctx, 0x11); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded with an expired ctx, got %v", err)
+	}
+	c.TCPClient.Timeout = 50 * time.Millisecond
+	if err := c.ForceListenOnly(0x11); err != nil {
+		t.Errorf("ForceListenOnly() error: %v", err)
+	}
+}
+
+// TestModbusClient_ForceListenOnlyPipelined checks that the client timeout of
+// a pipelined client counts as success.
+func TestModbusClient_ForceListenOnlyPipelined(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	c := NewPipelinedModbusClient("127.0.0.1", ln.Addr().(*net.TCPAddr).Port, 50*time.Millisecond, 1)
+	if err := c.ForceListenOnly(0x11); err != nil {
+		t.Errorf("ForceListenOnly() error: %v", err)
+	}
+}
+
+func TestModbusClient_ReturnQueryDataRTU(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+
+	// Over RTU the echo is sized from the request, whatever its length.
+	go func() {
+		req := make([]byte, 10)
+		if _, err := io.ReadFull(device, req); err != nil {
+			return
+		}
+		device.Write(req)
+	}()
+
+	c := NewRTUModbusClient(line, 19200, time.Second)
+	if err := c.ReturnQueryData(0x01, []byte{0xA5, 0x37, 0x01, 0x02}); err != nil {
+		t.Errorf("ReturnQueryData() error: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package modbus_client
+
+import (
+	"context"
+	"fmt"
+	"modbus_client/pkg/modbus"
+)
+
+// File record access. A file is a sequence of up to 10000 records of two
+// bytes each; ReadFile and WriteFile address it by byte instead, and split
+// the range across as many requests as the PDU size limit requires.
+
+// ReadFile reads length bytes of file, starting at byte offset.
+func (c *ModbusClient) ReadFile(unitID byte, file uint16, offset, length int) ([]byte, error) {
+	return c.ReadFileContext(context.Background(), unitID, file, offset, length)
+}
+
+// WriteFile writes data to file, starting at byte offset. If the range does
+// not start or end on a record boundary, the records it shares are read first
+// and written back with their other byte unchanged, which is not atomic.
+func (c *ModbusClient) WriteFile(unitID byte, file uint16, offset int, data []byte) error {
+	return c.WriteFileContext(context.Background(), unitID, file, offset, data)
+}
+
+// ReadFileRecords sends one FC 20 request with a sub-request per reference,
+// and returns the records in the same order.
+func (c *ModbusClient) ReadFileRecords(unitID byte, refs []modbus.FileRecordRef) ([]modbus.FileRecord, error) {
+	return c.ReadFileRecordsContext(context.Background(), unitID, refs)
+}
+
+// WriteFileRecords sends one FC 21 request with a sub-request per record.
+func (c *ModbusClient) WriteFileRecords(unitID byte, records []modbus.FileRecord) error {
+	return c.WriteFileRecordsContext(context.Background(), unitID, records)
+}
+
+func (c *ModbusClient) ReadFileContext(ctx context.Context, unitID byte, file uint16, offset, length int) ([]byte, error) {
+	first, last, err := fileRecordRange(offset, length)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return []byte{}, nil
+	}
+
+	data := make([]byte, 0, 2*(last-first+1))
+	for record := first; record <= last; record += int(modbus.MaxReadFileRecords) {
+		count := min(last-record+1, int(modbus.MaxReadFileRecords))
+		records, err := c.ReadFileRecordsContext(ctx, unitID, []modbus.FileRecordRef{
+			{FileNumber: file, RecordNumber: uint16(record), RecordLength: uint16(count)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, records[0].Data...)
+	}
+	return data[offset%2 : offset%2+length], nil
+}
+
+func (c *ModbusClient) WriteFileContext(ctx context.Context, unitID byte, file uint16, offset int, data []byte) error {
+	first, last, err := fileRecordRange(offset, len(data))
+	if err != nil || len(data) == 0 {
+		return err
+	}
+
+	buf := make([]byte, 2*(last-first+1))
+	var edges []modbus.FileRecordRef
+	if offset%2 != 0 {
+		edges = append(edges, modbus.FileRecordRef{FileNumber: file, RecordNumber: uint16(first), RecordLength: 1})
+	}
+	if (offset+len(data))%2 != 0 && (len(edges) == 0 || last != first) {
+		edges = append(edges, modbus.FileRecordRef{FileNumber: file, RecordNumber: uint16(last), RecordLength: 1})
+	}
+	if len(edges) > 0 {
+		records, err := c.ReadFileRecordsContext(ctx, unitID, edges)
+		if err != nil {
+			return fmt.Errorf("reading partial records: %w", err)
+		}
+		for _, rec := range records {
+			copy(buf[2*(int(rec.RecordNumber)-first):], rec.Data)
+		}
+	}
+	copy(buf[offset%2:], data)
+
+	step := int(modbus.MaxWriteFileRecords)
+	for record := first; record <= last; record += step {
+		end := 2 * min(record-first+step, last-first+1)
+		err := c.WriteFileRecordsContext(ctx, unitID, []modbus.FileRecord{
+			{FileNumber: file, RecordNumber: uint16(record), Data: buf[2*(record-first) : end]},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ModbusClient) ReadFileRecordsContext(ctx context.Context, unitID byte, refs []modbus.FileRecordRef) ([]modbus.FileRecord, error) {
+	req := &modbus.ReadFileRecordRequest{SlaveID: unitID, Refs: refs}
+	frame, err := req.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	respFrame, err := c.send(ctx, frame, false)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &modbus.ReadFileRecordResponse{}
+	if err := resp.Parse(respFrame, req); err != nil {
+		return nil, err
+	}
+	return resp.Records, nil
+}
+
+func (c *ModbusClient) WriteFileRecordsContext(ctx context.Context, unitID byte, records []modbus.FileRecord) error {
+	req := &modbus.WriteFileRecordRequest{SlaveID: unitID, Records: records}
+	frame, err := req.Build()
+	if err != nil {
+		return err
+	}
+
+	respFrame, err := c.send(ctx, frame, true)
+	if err != nil || respFrame == nil {
+		// A nil reply means a broadcast write, which is never answered.
+		return err
+	}
+
+	resp := &modbus.WriteFileRecordResponse{}
+	return resp.Parse(respFrame, req)
+}
+
+// fileRecordRange returns the first and last records holding length bytes
+// from byte offset on.
+func fileRecordRange(offset, length int) (int, int, error) {
+	if offset < 0 || length < 0 {
+		return 0, 0, fmt.Errorf("invalid file range: offset %d, length %d", offset, length)
+	}
+	first, last := offset/2, (offset+length-1)/2
+	if length > 0 && last > int(modbus.MaxFileRecordNumber) {
+		return 0, 0, fmt.Errorf("file range %d-%d past the last record", offset, offset+length-1)
+	}
+	return first, last, nil
+}
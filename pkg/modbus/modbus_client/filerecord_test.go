@@ -0,0 +1,139 @@
+package modbus_client
+
+import (
+	"bytes"
+	"modbus_client/pkg/modbus"
+	"reflect"
+	"testing"
+)
+
+// fileDevice serves file 7 from content over FC 20 and 21, and counts the
+// requests of each.
+type fileDevice struct {
+	content []byte
+	reads   int
+	writes  int
+}
+
+func (d *fileDevice) respond(frame []byte) []byte {
+	exception := []byte{frame[0], frame[1] | 0x80, 0x02}
+	switch modbus.FunctionCode(frame[1]) {
+	case modbus.FCReadFileRecord:
+		d.reads++
+		req := &modbus.ReadFileRecordRequest{}
+		if err := req.Parse(frame); err != nil {
+			return exception
+		}
+		resp := &modbus.ReadFileRecordResponse{SlaveID: req.SlaveID}
+		for _, ref := range req.Refs {
+			start, end := 2*int(ref.RecordNumber), 2*(int(ref.RecordNumber)+int(ref.RecordLength))
+			if ref.FileNumber != 7 || end > len(d.content) {
+				return exception
+			}
+			resp.Records = append(resp.Records, modbus.FileRecord{Data: d.content[start:end]})
+		}
+		reply, _ := resp.Build()
+		return reply
+	case modbus.FCWriteFileRecord:
+		d.writes++
+		req := &modbus.WriteFileRecordRequest{}
+		if err := req.Parse(frame); err != nil {
+			return exception
+		}
+		for _, rec := range req.Records {
+			start := 2 * int(rec.RecordNumber)
+			if rec.FileNumber != 7 || start+len(rec.Data) > len(d.content) {
+				return exception
+			}
+			copy(d.content[start:], rec.Data)
+		}
+		return frame
+	}
+	return []byte{frame[0], frame[1] | 0x80, 0x01}
+}
+
+func TestModbusClient_ReadFile(t *testing.T) {
+	device := &fileDevice{content: make([]byte, 1000)}
+	for i := range device.content {
+		device.content[i] = byte(i)
+	}
+	c, stop := startFakeDevice(t, device.respond)
+	defer stop()
+
+	// 301 bytes from an odd offset span records 1 to 151: two requests.
+	data, err := c.ReadFile(1, 7, 3, 301)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	if !bytes.Equal(data, device.content[3:304]) {
+		t.Errorf("ReadFile() returned the wrong bytes: %v", data)
+	}
+	if device.reads != 2 {
+		t.Errorf("expected 2 requests, got %d", device.reads)
+	}
+
+	if data, err := c.ReadFile(1, 7, 10, 0); err != nil || len(data) != 0 {
+		t.Errorf("ReadFile() of nothing = %v, %v", data, err)
+	}
+	if _, err := c.ReadFile(1, 7, 19999, 2); err == nil {
+		t.Error("expected error for a range past the last record, got nil")
+	}
+	if _, err := c.ReadFile(1, 8, 0, 2); err == nil {
+		t.Error("expected error for a missing file, got nil")
+	}
+
+	records, err := c.ReadFileRecords(1, []modbus.FileRecordRef{
+		{FileNumber: 7, RecordNumber: 2, RecordLength: 1},
+		{FileNumber: 7, RecordNumber: 10, RecordLength: 2},
+	})
+	expected := []modbus.FileRecord{
+		{FileNumber: 7, RecordNumber: 2, Data: []byte{4, 5}},
+		{FileNumber: 7, RecordNumber: 10, Data: []byte{20, 21, 22, 23}},
+	}
+	if err != nil || !reflect.DeepEqual(records, expected) {
+		t.Errorf("ReadFileRecords() = %v, %v", records, err)
+	}
+}
+
+func TestModbusClient_WriteFile(t *testing.T) {
+	device := &fileDevice{content: bytes.Repeat([]byte{0xEE}, 1000)}
+	c, stop := startFakeDevice(t, device.respond)
+	defer stop()
+
+	data := make([]byte, 300)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	// Bytes 5 to 304 span records 2 to 152: both edge records are shared, and
+	// the 151 records take two requests.
+	if err := c.WriteFile(1, 7, 5, data); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	expected := bytes.Repeat([]byte{0xEE}, 1000)
+	copy(expected[5:], data)
+	if !bytes.Equal(device.content, expected) {
+		t.Errorf("WriteFile() left the file as %v", device.content[:310])
+	}
+	if device.reads != 1 || device.writes != 2 {
+		t.Errorf("expected 1 read and 2 writes, got %d and %d", device.reads, device.writes)
+	}
+
+	// An aligned range needs no read.
+	device.reads, device.writes = 0, 0
+	if err := c.WriteFile(1, 7, 10, []byte{1, 2, 3, 4}); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if !bytes.Equal(device.content[10:14], []byte{1, 2, 3, 4}) || device.reads != 0 || device.writes != 1 {
+		t.Errorf("unexpected aligned write: %v after %d reads", device.content[10:14], device.reads)
+	}
+
+	// A single byte inside one record reads that record once.
+	device.reads = 0
+	if err := c.WriteFile(1, 7, 11, []byte{0x55}); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if !bytes.Equal(device.content[10:12], []byte{1, 0x55}) || device.reads != 1 {
+		t.Errorf("unexpected single byte write: %v after %d reads", device.content[10:12], device.reads)
+	}
+}
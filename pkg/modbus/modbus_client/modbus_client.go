@@ -1,17 +1,521 @@
 package modbus_client
 
 import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"modbus_client/pkg/modbus"
 	"modbus_client/pkg/modbus/client"
 	"time"
 )
 
 // this is the edge layer between CLI and modbus package
 type ModbusClient struct {
+	Sender client.Sender
+	// Deprecated: use Sender. The Modbus TCP constructors set both, and a
+	// client built with only TCPClient uses it as its Sender.
 	TCPClient *client.TCPClient
+	// Planner decides how ReadTags groups tags into requests.
+	Planner modbus.Planner
+	// Retry, if set, retries requests that fail with a transient error.
+	Retry *RetryPolicy
+	// NoMaskWrite lists the unit IDs for which SetRegisterBit and
+	// ClearRegisterBit read, modify and write the register, for devices that
+	// do not support FC 22; behind a gateway the others may. Unlike FC 22
+	// this races with other masters writing the same register.
+	NoMaskWrite map[byte]bool
 }
 
 func NewModbusClient(host string, port int, timeout time.Duration, pool *client.TCPConnectionPool) *ModbusClient {
+	tcpClient := client.NewTCPClient(host, timeout, port, pool)
 	return &ModbusClient{
-		TCPClient: client.NewTCPClient(host, timeout, port, pool),
+		Sender:    tcpClient,
+		TCPClient: tcpClient,
 	}
 }
+
+// NewPipelinedModbusClient returns a client that multiplexes up to
+// maxInFlight concurrent calls over a single TCP connection.
+func NewPipelinedModbusClient(host string, port int, timeout time.Duration, maxInFlight int) *ModbusClient {
+	tcpClient := client.NewPipelinedTCPClient(host, timeout, port, maxInFlight)
+	return &ModbusClient{
+		Sender:    tcpClient,
+		TCPClient: tcpClient,
+	}
+}
+
+// NewRTUOverTCPModbusClient returns a client for Ethernet-to-serial gateways
+// that pass raw RTU frames over a TCP socket.
+func NewRTUOverTCPModbusClient(host string, port int, timeout time.Duration, pool *client.TCPConnectionPool) *ModbusClient {
+	tcpClient := client.NewRTUOverTCPClient(host, timeout, port, pool)
+	return &ModbusClient{
+		Sender:    tcpClient,
+		TCPClient: tcpClient,
+	}
+}
+
+// NewASCIIOverTCPModbusClient returns a client for gateways that pass Modbus
+// ASCII frames over a TCP socket.
+func NewASCIIOverTCPModbusClient(host string, port int, timeout time.Duration, pool *client.TCPConnectionPool) *ModbusClient {
+	tcpClient := client.NewASCIIOverTCPClient(host, timeout, port, pool)
+	return &ModbusClient{
+		Sender:    tcpClient,
+		TCPClient: tcpClient,
+	}
+}
+
+// NewUDPModbusClient returns a client for devices that speak Modbus TCP
+// framing over UDP. Unanswered requests are sent up to retries more times.
+func NewUDPModbusClient(host string, port int, timeout time.Duration, retries int) *ModbusClient {
+	return &ModbusClient{
+		Sender: client.NewUDPClient(host, port, timeout, retries),
+	}
+}
+
+// NewModbusClientWithTransport combines any Transport with any Packager, e.g.
+// MBAP frames over a serial line or RTU frames over UDP.
+func NewModbusClientWithTransport(transport client.Transport, packager client.Packager, timeout time.Duration) *ModbusClient {
+	return &ModbusClient{
+		Sender: client.NewClient(transport, packager, timeout),
+	}
+}
+
+func NewRTUModbusClient(port io.ReadWriter, baudRate int, timeout time.Duration) *ModbusClient {
+	return &ModbusClient{
+		Sender: client.NewRTUClient(port, baudRate, timeout),
+	}
+}
+
+func NewASCIIModbusClient(port io.ReadWriter, timeout time.Duration) *ModbusClient {
+	return &ModbusClient{
+		Sender: client.NewASCIIClient(port, timeout),
+	}
+}
+
+// Close releases the connections held by the underlying client, if it holds
+// any.
+func (c *ModbusClient) Close() error {
+	if closer, ok := c.sender().(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// sender returns Sender, or the deprecated TCPClient field if only that is
+// set.
+func (c *ModbusClient) sender() client.Sender {
+	if c.Sender == nil && c.TCPClient != nil {
+		return c.TCPClient
+	}
+	return c.Sender
+}
+
+func (c *ModbusClient) ReadCoils(unitID byte, address, quantity uint16) ([]bool, error) {
+	return c.ReadCoilsContext(context.Background(), unitID, address, quantity)
+}
+
+func (c *ModbusClient) ReadDiscreteInputs(unitID byte, address, quantity uint16) ([]bool, error) {
+	return c.ReadDiscreteInputsContext(context.Background(), unitID, address, quantity)
+}
+
+func (c *ModbusClient) ReadHoldingRegisters(unitID byte, address, quantity uint16) ([]uint16, error) {
+	return c.ReadHoldingRegistersContext(context.Background(), unitID, address, quantity)
+}
+
+func (c *ModbusClient) ReadInputRegisters(unitID byte, address, quantity uint16) ([]uint16, error) {
+	return c.ReadInputRegistersContext(context.Background(), unitID, address, quantity)
+}
+
+func (c *ModbusClient) WriteSingleCoil(unitID byte, address uint16, value bool) error {
+	return c.WriteSingleCoilContext(context.Background(), unitID, address, value)
+}
+
+func (c *ModbusClient) WriteSingleRegister(unitID byte, address, value uint16) error {
+	return c.WriteSingleRegisterContext(context.Background(), unitID, address, value)
+}
+
+func (c *ModbusClient) WriteMultipleCoils(unitID byte, address uint16, values []bool) error {
+	return c.WriteMultipleCoilsContext(context.Background(), unitID, address, values)
+}
+
+func (c *ModbusClient) WriteMultipleRegisters(unitID byte, address uint16, values []uint16) error {
+	return c.WriteMultipleRegistersContext(context.Background(), unitID, address, values)
+}
+
+// ReadWriteMultipleRegisters writes values from writeAddress, then reads
+// quantity registers from readAddress, in one FC 23 transaction.
+func (c *ModbusClient) ReadWriteMultipleRegisters(unitID byte, readAddress, quantity, writeAddress uint16, values []uint16) ([]uint16, error) {
+	return c.ReadWriteMultipleRegistersContext(context.Background(), unitID, readAddress, quantity, writeAddress, values)
+}
+
+// MaskWriteRegister sets the holding register at address to (current AND
+// andMask) OR (orMask AND NOT andMask) with FC 22.
+func (c *ModbusClient) MaskWriteRegister(unitID byte, address, andMask, orMask uint16) error {
+	return c.MaskWriteRegisterContext(context.Background(), unitID, address, andMask, orMask)
+}
+
+// SetRegisterBit sets bit (0 is the least significant) of the holding
+// register at address, leaving the other bits alone.
+func (c *ModbusClient) SetRegisterBit(unitID byte, address uint16, bit uint) error {
+	return c.SetRegisterBitContext(context.Background(), unitID, address, bit)
+}
+
+// ClearRegisterBit clears bit (0 is the least significant) of the holding
+// register at address, leaving the other bits alone.
+func (c *ModbusClient) ClearRegisterBit(unitID byte, address uint16, bit uint) error {
+	return c.ClearRegisterBitContext(context.Background(), unitID, address, bit)
+}
+
+// ReadDeviceIdentification reads identification objects with FC 43 / MEI
+// type 14 and returns their values keyed by object ID. For the basic, regular
+// and extended categories it reads from objectID (normally 0) on, following
+// continuations until the device has sent every object of the category; for
+// ReadDeviceIDIndividual it reads objectID alone.
+func (c *ModbusClient) ReadDeviceIdentification(unitID byte, code modbus.ReadDeviceIDCode, objectID byte) (map[byte]string, error) {
+	return c.ReadDeviceIdentificationContext(context.Background(), unitID, code, objectID)
+}
+
+// The Context variants below abandon the call when ctx is done, whether the
+// client is dialing, waiting for a pooled connection or the line, or waiting
+// for the reply. They return ctx.Err() in that case.
+
+func (c *ModbusClient) ReadCoilsContext(ctx context.Context, unitID byte, address, quantity uint16) ([]bool, error) {
+	return c.readBits(ctx, modbus.FCReadCoils, unitID, address, quantity)
+}
+
+func (c *ModbusClient) ReadDiscreteInputsContext(ctx context.Context, unitID byte, address, quantity uint16) ([]bool, error) {
+	return c.readBits(ctx, modbus.FCReadInputStatus, unitID, address, quantity)
+}
+
+func (c *ModbusClient) ReadHoldingRegistersContext(ctx context.Context, unitID byte, address, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(ctx, modbus.FCReadHoldingRegisters, unitID, address, quantity)
+}
+
+func (c *ModbusClient) ReadInputRegistersContext(ctx context.Context, unitID byte, address, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(ctx, modbus.FCReadInputRegisters, unitID, address, quantity)
+}
+
+func (c *ModbusClient) WriteSingleCoilContext(ctx context.Context, unitID byte, address uint16, value bool) error {
+	var v uint16
+	if value {
+		v = 0xFF00
+	}
+	return c.writeSingle(ctx, modbus.FCForceSingleCoil, unitID, address, v)
+}
+
+func (c *ModbusClient) WriteSingleRegisterContext(ctx context.Context, unitID byte, address, value uint16) error {
+	return c.writeSingle(ctx, modbus.FCPresetSingleRegister, unitID, address, value)
+}
+
+func (c *ModbusClient) WriteMultipleCoilsContext(ctx context.Context, unitID byte, address uint16, values []bool) error {
+	return c.writeMultiple(ctx, modbus.FCForceMultipleCoils, unitID, address, uint16(len(values)), modbus.BoolsToCoilBytes(values))
+}
+
+func (c *ModbusClient) WriteMultipleRegistersContext(ctx context.Context, unitID byte, address uint16, values []uint16) error {
+	return c.writeMultiple(ctx, modbus.FCPresetMultipleRegisters, unitID, address, uint16(len(values)), modbus.Uint16sToBytes(values, binary.BigEndian))
+}
+
+func (c *ModbusClient) ReadWriteMultipleRegistersContext(ctx context.Context, unitID byte, readAddress, quantity, writeAddress uint16, values []uint16) ([]uint16, error) {
+	req := &modbus.ReadWriteRequest{
+		Header:        modbus.NewModbusHeader(modbus.FCReadWriteMultipleRegisters, unitID, readAddress),
+		Quantity:      quantity,
+		WriteAddress:  writeAddress,
+		WriteQuantity: uint16(len(values)),
+		Values2Write:  modbus.Uint16sToBytes(values, binary.BigEndian),
+	}
+	frame, err := req.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	respFrame, err := c.send(ctx, frame, true)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &modbus.ReadWriteResponse{}
+	if err := resp.Parse(respFrame, req); err != nil {
+		return nil, err
+	}
+	return modbus.BytesToUint16s(resp.Response, binary.BigEndian)
+}
+
+func (c *ModbusClient) MaskWriteRegisterContext(ctx context.Context, unitID byte, address, andMask, orMask uint16) error {
+	req := &modbus.MaskWriteRequest{
+		Header:  modbus.NewModbusHeader(modbus.FCMaskWriteRegister, unitID, address),
+		AndMask: andMask,
+		OrMask:  orMask,
+	}
+	frame, err := req.Build()
+	if err != nil {
+		return err
+	}
+
+	respFrame, err := c.send(ctx, frame, true)
+	if err != nil || respFrame == nil {
+		// A nil reply means a broadcast write, which is never answered.
+		return err
+	}
+
+	resp := &modbus.MaskWriteResponse{}
+	return resp.Parse(respFrame, req)
+}
+
+func (c *ModbusClient) SetRegisterBitContext(ctx context.Context, unitID byte, address uint16, bit uint) error {
+	if bit > 15 {
+		return fmt.Errorf("bit %d out of range: registers have 16 bits", bit)
+	}
+	return c.maskWrite(ctx, unitID, address, ^uint16(1<<bit), 1<<bit)
+}
+
+func (c *ModbusClient) ClearRegisterBitContext(ctx context.Context, unitID byte, address uint16, bit uint) error {
+	if bit > 15 {
+		return fmt.Errorf("bit %d out of range: registers have 16 bits", bit)
+	}
+	return c.maskWrite(ctx, unitID, address, ^uint16(1<<bit), 0)
+}
+
+// maskWrite applies the masks with FC 22, or by reading and writing back the
+// register if NoMaskWrite is set for unitID.
+func (c *ModbusClient) maskWrite(ctx context.Context, unitID byte, address, andMask, orMask uint16) error {
+	if !c.NoMaskWrite[unitID] {
+		return c.MaskWriteRegisterContext(ctx, unitID, address, andMask, orMask)
+	}
+
+	values, err := c.ReadHoldingRegistersContext(ctx, unitID, address, 1)
+	if err != nil {
+		return err
+	}
+	req := &modbus.MaskWriteRequest{AndMask: andMask, OrMask: orMask}
+	return c.WriteSingleRegisterContext(ctx, unitID, address, req.Apply(values[0]))
+}
+
+func (c *ModbusClient) ReadDeviceIdentificationContext(ctx context.Context, unitID byte, code modbus.ReadDeviceIDCode, objectID byte) (map[byte]string, error) {
+	objects := make(map[byte]string)
+	for {
+		req := &modbus.ReadDeviceIDRequest{SlaveID: unitID, Code: code, ObjectID: objectID}
+		frame, err := req.Build()
+		if err != nil {
+			return nil, err
+		}
+
+		respFrame, err := c.send(ctx, frame, false)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &modbus.ReadDeviceIDResponse{}
+		if err := resp.Parse(respFrame, req); err != nil {
+			return nil, err
+		}
+		for _, obj := range resp.Objects {
+			objects[obj.ID] = string(obj.Value)
+		}
+
+		if code == modbus.ReadDeviceIDIndividual || !resp.MoreFollows {
+			return objects, nil
+		}
+		// A device that does not move forward would be asked forever.
+		if resp.NextObjectID <= objectID {
+			return nil, fmt.Errorf("device continues at object 0x%02X after a read from 0x%02X", resp.NextObjectID, objectID)
+		}
+		objectID = resp.NextObjectID
+	}
+}
+
+// ReadPlan executes every read of plan, one request at a time, and returns
+// the values of each point (see modbus.ReadPlan.Assemble).
+func (c *ModbusClient) ReadPlan(plan *modbus.ReadPlan) ([][]uint16, error) {
+	return c.ReadPlanContext(context.Background(), plan)
+}
+
+// ReadPlanContext is ReadPlan, abandoned when ctx is done.
+func (c *ModbusClient) ReadPlanContext(ctx context.Context, plan *modbus.ReadPlan) ([][]uint16, error) {
+	results := make([][]uint16, len(plan.Reads))
+	for i, r := range plan.Reads {
+		values, err := c.readTable(ctx, r.Table, r.UnitID, r.Address, r.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("reading %d %v from %d on unit %d: %w", r.Quantity, r.Table, r.Address, r.UnitID, err)
+		}
+		results[i] = values
+	}
+	return plan.Assemble(results)
+}
+
+// ReadTags reads tags in as few requests as Planner allows and returns their
+// engineering values, in the order of tags.
+func (c *ModbusClient) ReadTags(tags []modbus.Tag) ([]float64, error) {
+	return c.ReadTagsContext(context.Background(), tags)
+}
+
+// ReadTagsContext is ReadTags, abandoned when ctx is done.
+func (c *ModbusClient) ReadTagsContext(ctx context.Context, tags []modbus.Tag) ([]float64, error) {
+	points := make([]modbus.Point, len(tags))
+	for i := range tags {
+		if err := tags[i].Validate(); err != nil {
+			return nil, err
+		}
+		points[i] = tags[i].Point()
+	}
+
+	plan, err := c.Planner.Plan(points)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := c.ReadPlanContext(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]float64, len(tags))
+	for i := range tags {
+		if values[i], err = tags[i].Decode(raw[i]); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// WriteTags writes values[i] to tags[i], one request per tag. Single
+// register tags are written with FC 6, longer ones with FC 16. It stops at
+// the first tag that fails.
+func (c *ModbusClient) WriteTags(tags []modbus.Tag, values []float64) error {
+	return c.WriteTagsContext(context.Background(), tags, values)
+}
+
+// WriteTagsContext is WriteTags, abandoned when ctx is done. Tags written
+// before that stay written.
+func (c *ModbusClient) WriteTagsContext(ctx context.Context, tags []modbus.Tag, values []float64) error {
+	if len(tags) != len(values) {
+		return fmt.Errorf("got %d values for %d tags", len(values), len(tags))
+	}
+
+	for i := range tags {
+		tag := &tags[i]
+		if err := tag.Validate(); err != nil {
+			return err
+		}
+		if !tag.Table.Writable() {
+			return fmt.Errorf("tag %q: %v are read-only", tag.Name, tag.Table)
+		}
+
+		registers, err := tag.Encode(values[i])
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case tag.Table == modbus.TableCoils:
+			err = c.WriteSingleCoilContext(ctx, tag.UnitID, tag.Address, registers[0] != 0)
+		case len(registers) == 1:
+			err = c.WriteSingleRegisterContext(ctx, tag.UnitID, tag.Address, registers[0])
+		default:
+			err = c.WriteMultipleRegistersContext(ctx, tag.UnitID, tag.Address, registers)
+		}
+		if err != nil {
+			return fmt.Errorf("writing tag %q: %w", tag.Name, err)
+		}
+	}
+	return nil
+}
+
+// readTable reads any table, returning coils and discrete inputs as 0 or 1.
+func (c *ModbusClient) readTable(ctx context.Context, table modbus.Table, unitID byte, address, quantity uint16) ([]uint16, error) {
+	if !table.IsBit() {
+		return c.readRegisters(ctx, table.ReadFunctionCode(), unitID, address, quantity)
+	}
+
+	bits, err := c.readBits(ctx, table.ReadFunctionCode(), unitID, address, quantity)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]uint16, len(bits))
+	for i, bit := range bits {
+		if bit {
+			values[i] = 1
+		}
+	}
+	return values, nil
+}
+
+func (c *ModbusClient) readBits(ctx context.Context, fc modbus.FunctionCode, unitID byte, address, quantity uint16) ([]bool, error) {
+	data, err := c.read(ctx, fc, unitID, address, quantity)
+	if err != nil {
+		return nil, err
+	}
+	return modbus.CoilBytesToBools(data, int(quantity))
+}
+
+func (c *ModbusClient) readRegisters(ctx context.Context, fc modbus.FunctionCode, unitID byte, address, quantity uint16) ([]uint16, error) {
+	data, err := c.read(ctx, fc, unitID, address, quantity)
+	if err != nil {
+		return nil, err
+	}
+	return modbus.BytesToUint16s(data, binary.BigEndian)
+}
+
+// read sends a reading request and returns the validated data bytes of the
+// reply.
+func (c *ModbusClient) read(ctx context.Context, fc modbus.FunctionCode, unitID byte, address, quantity uint16) ([]byte, error) {
+	req := &modbus.ReadingRequest{
+		Header:   modbus.NewModbusHeader(fc, unitID, address),
+		Quantity: quantity,
+	}
+	frame, err := req.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	respFrame, err := c.send(ctx, frame, false)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &modbus.ReadingResponse{}
+	if err := resp.Parse(respFrame, req); err != nil {
+		return nil, err
+	}
+	return resp.Response, nil
+}
+
+func (c *ModbusClient) writeSingle(ctx context.Context, fc modbus.FunctionCode, unitID byte, address, value uint16) error {
+	req := &modbus.SingleWritingRequest{
+		Header:      modbus.NewModbusHeader(fc, unitID, address),
+		Value2Write: value,
+	}
+	frame, err := req.Build()
+	if err != nil {
+		return err
+	}
+
+	respFrame, err := c.send(ctx, frame, true)
+	if err != nil || respFrame == nil {
+		// A nil reply means a broadcast write, which is never answered.
+		return err
+	}
+
+	resp := &modbus.SingleWritingResponse{}
+	return resp.Parse(respFrame, req)
+}
+
+func (c *ModbusClient) writeMultiple(ctx context.Context, fc modbus.FunctionCode, unitID byte, address, quantity uint16, values []byte) error {
+	req := &modbus.MultipleWritingRequest{
+		Header:       modbus.NewModbusHeader(fc, unitID, address),
+		Quantity:     quantity,
+		Values2Write: values,
+	}
+	frame, err := req.Build()
+	if err != nil {
+		return err
+	}
+
+	respFrame, err := c.send(ctx, frame, true)
+	if err != nil || respFrame == nil {
+		// A nil reply means a broadcast write, which is never answered.
+		return err
+	}
+
+	resp := &modbus.MultipleWritingResponse{}
+	return resp.Parse(respFrame, req)
+}
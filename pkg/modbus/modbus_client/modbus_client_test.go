@@ -0,0 +1,627 @@
+package modbus_client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/client"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startFakeDevice starts a Modbus TCP listener that answers every request
+// with the frame returned by respond. The request frame passed to respond
+// starts at the unit ID; the MBAP header is added back automatically.
+func startFakeDevice(t *testing.T, respond func(frame []byte) []byte) (*ModbusClient, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				for {
+					header := make([]byte, 6)
+					if _, err := io.ReadFull(conn, header); err != nil {
+						return
+					}
+					frame := make([]byte, binary.BigEndian.Uint16(header[4:6]))
+					if _, err := io.ReadFull(conn, frame); err != nil {
+						return
+					}
+					reply := respond(frame)
+					binary.BigEndian.PutUint16(header[4:6], uint16(len(reply)))
+					if _, err := conn.Write(append(header, reply...)); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	return NewModbusClient("127.0.0.1", port, 2*time.Second, nil), func() { ln.Close() }
+}
+
+func TestModbusClient_ReadHoldingRegisters(t *testing.T) {
+	var got []byte
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		got = frame
+		return []byte{frame[0], frame[1], 0x04, 0x12, 0x34, 0xAB, 0xCD}
+	})
+	defer stop()
+
+	values, err := c.ReadHoldingRegisters(0x11, 0x006B, 2)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters() error: %v", err)
+	}
+
+	expectedRequest := []byte{0x11, 0x03, 0x00, 0x6B, 0x00, 0x02}
+	if !reflect.DeepEqual(got, expectedRequest) {
+		t.Errorf("request mismatch.\nExpected: %v\nGot:      %v", expectedRequest, got)
+	}
+	if !reflect.DeepEqual(values, []uint16{0x1234, 0xABCD}) {
+		t.Errorf("unexpected register values: %v", values)
+	}
+}
+
+func TestModbusClient_ReadCoils(t *testing.T) {
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		return []byte{frame[0], frame[1], 0x02, 0xCD, 0x01}
+	})
+	defer stop()
+
+	values, err := c.ReadCoils(0x01, 0x0013, 10)
+	if err != nil {
+		t.Fatalf("ReadCoils() error: %v", err)
+	}
+
+	expected := []bool{true, false, true, true, false, false, true, true, true, false}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("unexpected coil values.\nExpected: %v\nGot:      %v", expected, values)
+	}
+}
+
+func TestModbusClient_WriteMultipleRegisters(t *testing.T) {
+	var got []byte
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		got = frame
+		return frame[:6]
+	})
+	defer stop()
+
+	if err := c.WriteMultipleRegisters(0x01, 0x0001, []uint16{0x000A, 0x0102}); err != nil {
+		t.Fatalf("WriteMultipleRegisters() error: %v", err)
+	}
+
+	expectedRequest := []byte{0x01, 0x10, 0x00, 0x01, 0x00, 0x02, 0x04, 0x00, 0x0A, 0x01, 0x02}
+	if !reflect.DeepEqual(got, expectedRequest) {
+		t.Errorf("request mismatch.\nExpected: %v\nGot:      %v", expectedRequest, got)
+	}
+}
+
+func TestModbusClient_WriteMultipleLimits(t *testing.T) {
+	var mu sync.Mutex
+	sent := 0
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		mu.Lock()
+		defer mu.Unlock()
+		sent++
+		return frame[:6]
+	})
+	defer stop()
+
+	if err := c.WriteMultipleRegisters(0x01, 0, nil); err == nil {
+		t.Error("expected error writing no registers, got nil")
+	}
+	if err := c.WriteMultipleRegisters(0x01, 0, make([]uint16, 124)); err == nil {
+		t.Error("expected error writing 124 registers, got nil")
+	}
+	if err := c.WriteMultipleCoils(0x01, 0, []bool{}); err == nil {
+		t.Error("expected error writing no coils, got nil")
+	}
+	if err := c.WriteMultipleCoils(0x01, 0, make([]bool, 1969)); err == nil {
+		t.Error("expected error writing 1969 coils, got nil")
+	}
+	mu.Lock()
+	if sent != 0 {
+		t.Fatalf("%d invalid requests reached the device", sent)
+	}
+	mu.Unlock()
+
+	if err := c.WriteMultipleRegisters(0x01, 0, make([]uint16, 123)); err != nil {
+		t.Errorf("WriteMultipleRegisters() of 123 registers error: %v", err)
+	}
+	if err := c.WriteMultipleCoils(0x01, 0, make([]bool, 1968)); err != nil {
+		t.Errorf("WriteMultipleCoils() of 1968 coils error: %v", err)
+	}
+}
+
+func TestModbusClient_ReadWriteMultipleRegisters(t *testing.T) {
+	var got []byte
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		got = frame
+		return []byte{frame[0], frame[1], 0x04, 0x00, 0xFE, 0x0A, 0xCD}
+	})
+	defer stop()
+
+	values, err := c.ReadWriteMultipleRegisters(0x01, 0x0003, 2, 0x000E, []uint16{0x00FF})
+	if err != nil {
+		t.Fatalf("ReadWriteMultipleRegisters() error: %v", err)
+	}
+	if !reflect.DeepEqual(values, []uint16{0x00FE, 0x0ACD}) {
+		t.Errorf("unexpected values: %04X", values)
+	}
+
+	expectedRequest := []byte{0x01, 0x17, 0x00, 0x03, 0x00, 0x02, 0x00, 0x0E, 0x00, 0x01, 0x02, 0x00, 0xFF}
+	if !reflect.DeepEqual(got, expectedRequest) {
+		t.Errorf("request mismatch.\nExpected: %v\nGot:      %v", expectedRequest, got)
+	}
+
+	if _, err := c.ReadWriteMultipleRegisters(0x01, 0, 1, 0, make([]uint16, 122)); err == nil {
+		t.Error("expected error writing 122 registers, got nil")
+	}
+}
+
+func TestModbusClient_RegisterBits(t *testing.T) {
+	var mu sync.Mutex
+	register := uint16(0x0012)
+	var fcs []byte
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		mu.Lock()
+		defer mu.Unlock()
+		fcs = append(fcs, frame[1])
+
+		switch modbus.FunctionCode(frame[1]) {
+		case modbus.FCMaskWriteRegister:
+			req := &modbus.MaskWriteRequest{}
+			req.Parse(frame)
+			register = req.Apply(register)
+			return frame
+		case modbus.FCReadHoldingRegisters:
+			return []byte{frame[0], frame[1], 0x02, byte(register >> 8), byte(register)}
+		case modbus.FCPresetSingleRegister:
+			register = binary.BigEndian.Uint16(frame[4:6])
+			return frame
+		}
+		return []byte{frame[0], frame[1] | 0x80, 0x01}
+	})
+	defer stop()
+
+	if err := c.MaskWriteRegister(0x01, 0x0004, 0x00F2, 0x0025); err != nil {
+		t.Fatalf("MaskWriteRegister() error: %v", err)
+	}
+	if err := c.SetRegisterBit(0x01, 0x0004, 15); err != nil {
+		t.Fatalf("SetRegisterBit() error: %v", err)
+	}
+	if err := c.ClearRegisterBit(0x01, 0x0004, 0); err != nil {
+		t.Fatalf("ClearRegisterBit() error: %v", err)
+	}
+	if register != 0x8016 || !reflect.DeepEqual(fcs, []byte{22, 22, 22}) {
+		t.Errorf("expected 0x8016 after three FC 22 writes, got 0x%04X after %v", register, fcs)
+	}
+
+	// Behind a gateway, unit 2 lacks FC 22 while unit 1 keeps using it.
+	c.NoMaskWrite = map[byte]bool{0x02: true}
+	fcs = nil
+	if err := c.ClearRegisterBit(0x02, 0x0004, 15); err != nil {
+		t.Fatalf("ClearRegisterBit() error: %v", err)
+	}
+	if register != 0x0016 || !reflect.DeepEqual(fcs, []byte{3, 6}) {
+		t.Errorf("expected 0x0016 after read-modify-write, got 0x%04X after %v", register, fcs)
+	}
+	fcs = nil
+	if err := c.SetRegisterBit(0x01, 0x0004, 15); err != nil {
+		t.Fatalf("SetRegisterBit() error: %v", err)
+	}
+	if register != 0x8016 || !reflect.DeepEqual(fcs, []byte{22}) {
+		t.Errorf("expected 0x8016 after an FC 22 write to unit 1, got 0x%04X after %v", register, fcs)
+	}
+
+	if err := c.SetRegisterBit(0x01, 0x0004, 16); err == nil {
+		t.Error("expected error for bit 16, got nil")
+	}
+}
+
+func TestModbusClient_WriteSingleCoil(t *testing.T) {
+	var got []byte
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		got = frame
+		return frame
+	})
+	defer stop()
+
+	if err := c.WriteSingleCoil(0x01, 0x00AC, true); err != nil {
+		t.Fatalf("WriteSingleCoil() error: %v", err)
+	}
+
+	expectedRequest := []byte{0x01, 0x05, 0x00, 0xAC, 0xFF, 0x00}
+	if !reflect.DeepEqual(got, expectedRequest) {
+		t.Errorf("request mismatch.\nExpected: %v\nGot:      %v", expectedRequest, got)
+	}
+}
+
+func TestModbusClient_ExceptionResponse(t *testing.T) {
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		return []byte{frame[0], frame[1] | 0x80, 0x02}
+	})
+	defer stop()
+
+	_, err := c.ReadInputRegisters(0x01, 0x0100, 1)
+	var exc *modbus.ModbusException
+	if !errors.As(err, &exc) {
+		t.Fatalf("expected *ModbusException, got %v", err)
+	}
+	if exc.Code != modbus.ExceptionIllegalDataAddress {
+		t.Errorf("expected exception code 0x02, got 0x%02X", exc.Code)
+	}
+}
+
+func TestModbusClient_WrongRegisterCount(t *testing.T) {
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		// The device answers with a single register although two were asked for.
+		return []byte{frame[0], frame[1], 0x02, 0x12, 0x34}
+	})
+	defer stop()
+
+	if _, err := c.ReadHoldingRegisters(0x01, 0x0000, 2); err == nil {
+		t.Fatal("expected error for wrong register count, got nil")
+	}
+}
+
+func TestPipelinedModbusClient_ConcurrentReads(t *testing.T) {
+	plain, stop := startFakeDevice(t, func(frame []byte) []byte {
+		// Echo the requested address back as the register value.
+		return []byte{frame[0], frame[1], 0x02, frame[2], frame[3]}
+	})
+	defer stop()
+
+	tcp := plain.Sender.(*client.TCPClient)
+	c := NewPipelinedModbusClient(tcp.Host, tcp.Port, 2*time.Second, 3)
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := uint16(0); i < 10; i++ {
+		wg.Add(1)
+		go func(address uint16) {
+			defer wg.Done()
+			values, err := c.ReadHoldingRegisters(0x01, address, 1)
+			if err != nil {
+				t.Errorf("ReadHoldingRegisters(%d) error: %v", address, err)
+				return
+			}
+			if values[0] != address {
+				t.Errorf("expected value %d, got %d", address, values[0])
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestModbusClient_SerialFramings(t *testing.T) {
+	// The same high-level call must work whatever framing is underneath.
+	tests := []struct {
+		name   string
+		client func(port io.ReadWriter) *ModbusClient
+		serve  func(device net.Conn)
+	}{
+		{
+			name:   "RTU",
+			client: func(port io.ReadWriter) *ModbusClient { return NewRTUModbusClient(port, 19200, time.Second) },
+			serve: func(device net.Conn) {
+				req := make([]byte, 8)
+				if _, err := io.ReadFull(device, req); err != nil {
+					return
+				}
+				wrap := &modbus.RTURequestWrapper{ModbusFrame: []byte{0x05, 0x04, 0x02, 0x01, 0x02}}
+				reply, _ := wrap.Build()
+				device.Write(reply)
+			},
+		},
+		{
+			name:   "ASCII",
+			client: func(port io.ReadWriter) *ModbusClient { return NewASCIIModbusClient(port, time.Second) },
+			serve: func(device net.Conn) {
+				req := make([]byte, 17)
+				if _, err := io.ReadFull(device, req); err != nil {
+					return
+				}
+				wrap := &modbus.ASCIIRequestWrapper{ModbusFrame: []byte{0x05, 0x04, 0x02, 0x01, 0x02}}
+				reply, _ := wrap.Build()
+				device.Write(reply)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		line, device := net.Pipe()
+		go tc.serve(device)
+
+		values, err := tc.client(line).ReadInputRegisters(0x05, 0x0008, 1)
+		if err != nil {
+			t.Errorf("%s: ReadInputRegisters() error: %v", tc.name, err)
+		} else if !reflect.DeepEqual(values, []uint16{0x0102}) {
+			t.Errorf("%s: unexpected register values: %v", tc.name, values)
+		}
+
+		line.Close()
+		device.Close()
+	}
+}
+
+func TestModbusClient_ReadPlan(t *testing.T) {
+	var requests int
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		requests++
+		address := binary.BigEndian.Uint16(frame[2:4])
+		quantity := binary.BigEndian.Uint16(frame[4:6])
+		if modbus.FunctionCode(frame[1]) == modbus.FCReadCoils {
+			// Every coil is on.
+			bits := make([]bool, quantity)
+			for i := range bits {
+				bits[i] = true
+			}
+			data := modbus.BoolsToCoilBytes(bits)
+			return append([]byte{frame[0], frame[1], byte(len(data))}, data...)
+		}
+		// Each register holds its own address.
+		reply := []byte{frame[0], frame[1], byte(2 * quantity)}
+		for i := uint16(0); i < quantity; i++ {
+			reply = binary.BigEndian.AppendUint16(reply, address+i)
+		}
+		return reply
+	})
+	defer stop()
+
+	var points []modbus.Point
+	for a := uint16(0); a < 300; a += 3 {
+		points = append(points, modbus.Point{UnitID: 1, Table: modbus.TableHoldingRegisters, Address: a})
+	}
+	points = append(points, modbus.Point{UnitID: 1, Table: modbus.TableCoils, Address: 5, Length: 2})
+
+	plan, err := (&modbus.Planner{MaxGap: 2}).Plan(points)
+	if err != nil {
+		t.Fatalf("Plan() error: %v", err)
+	}
+	values, err := c.ReadPlan(plan)
+	if err != nil {
+		t.Fatalf("ReadPlan() error: %v", err)
+	}
+
+	if requests != 4 {
+		t.Errorf("expected 4 requests (1 coil read, 3 register reads), got %d", requests)
+	}
+	for i, pt := range points[:100] {
+		if values[i][0] != pt.Address {
+			t.Fatalf("point %d: expected %d, got %d", i, pt.Address, values[i][0])
+		}
+	}
+	if !reflect.DeepEqual(values[100], []uint16{1, 1}) {
+		t.Errorf("unexpected coil values: %v", values[100])
+	}
+}
+
+func TestModbusClient_ReadWriteTags(t *testing.T) {
+	// A device with 16 holding registers and 16 coils, read and written
+	// through the fake device.
+	var mu sync.Mutex
+	registers := make([]uint16, 16)
+	coils := make([]bool, 16)
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		mu.Lock()
+		defer mu.Unlock()
+
+		address := binary.BigEndian.Uint16(frame[2:4])
+		switch modbus.FunctionCode(frame[1]) {
+		case modbus.FCReadHoldingRegisters:
+			quantity := binary.BigEndian.Uint16(frame[4:6])
+			data := modbus.Uint16sToBytes(registers[address:address+quantity], binary.BigEndian)
+			return append([]byte{frame[0], frame[1], byte(len(data))}, data...)
+		case modbus.FCReadCoils:
+			quantity := binary.BigEndian.Uint16(frame[4:6])
+			data := modbus.BoolsToCoilBytes(coils[address : address+quantity])
+			return append([]byte{frame[0], frame[1], byte(len(data))}, data...)
+		case modbus.FCPresetSingleRegister:
+			registers[address] = binary.BigEndian.Uint16(frame[4:6])
+		case modbus.FCPresetMultipleRegisters:
+			values, _ := modbus.BytesToUint16s(frame[7:], binary.BigEndian)
+			copy(registers[address:], values)
+		case modbus.FCForceSingleCoil:
+			coils[address] = frame[4] == 0xFF
+		}
+		return frame[:6]
+	})
+	defer stop()
+
+	tags := []modbus.Tag{
+		{Name: "setpoint", UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 0, Type: modbus.TypeInt16, Scale: 0.1, Unit: "°C"},
+		{Name: "flow", UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 4, Type: modbus.TypeFloat32, Unit: "m3/h"},
+		{Name: "pump", UnitID: 1, Table: modbus.TableCoils, Address: 3, Type: modbus.TypeBool},
+	}
+
+	if err := c.WriteTags(tags, []float64{-12.5, 3.25, 1}); err != nil {
+		t.Fatalf("WriteTags() error: %v", err)
+	}
+	if registers[0] != 0xFF83 || registers[4] != 0x4050 || !coils[3] {
+		t.Errorf("unexpected device state: registers %04X, coils %v", registers, coils)
+	}
+
+	c.Planner.MaxGap = 8
+	values, err := c.ReadTags(tags)
+	if err != nil {
+		t.Fatalf("ReadTags() error: %v", err)
+	}
+	if !reflect.DeepEqual(values, []float64{-12.5, 3.25, 1}) {
+		t.Errorf("unexpected tag values: %v", values)
+	}
+
+	readOnly := []modbus.Tag{{Name: "ain", Table: modbus.TableInputRegisters, Type: modbus.TypeUint16}}
+	if err := c.WriteTags(readOnly, []float64{1}); err == nil {
+		t.Error("expected error writing an input register tag, got nil")
+	}
+}
+
+// TestModbusClient_ReadOverlappingTags reads tags viewing the same registers,
+// one of them split across two requests.
+func TestModbusClient_ReadOverlappingTags(t *testing.T) {
+	registers := make([]uint16, 200)
+	registers[0], registers[124], registers[125] = 7, 0x4050, 0x0001
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		address, quantity := binary.BigEndian.Uint16(frame[2:4]), binary.BigEndian.Uint16(frame[4:6])
+		data := modbus.Uint16sToBytes(registers[address:address+quantity], binary.BigEndian)
+		return append([]byte{frame[0], frame[1], byte(len(data))}, data...)
+	})
+	defer stop()
+
+	c.Planner.MaxGap = 200
+	tags := []modbus.Tag{
+		{Name: "first", UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 0, Type: modbus.TypeUint16},
+		{Name: "raw", UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 124, Type: modbus.TypeUint32},
+		{Name: "high", UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 124, Type: modbus.TypeUint16},
+		{Name: "low", UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 125, Type: modbus.TypeUint16},
+	}
+	values, err := c.ReadTags(tags)
+	if err != nil {
+		t.Fatalf("ReadTags() error: %v", err)
+	}
+	if !reflect.DeepEqual(values, []float64{7, 0x40500001, 0x4050, 1}) {
+		t.Errorf("unexpected tag values: %v", values)
+	}
+}
+
+// TestModbusClient_TCPClientField checks that clients built around the
+// deprecated TCPClient field still work.
+func TestModbusClient_TCPClientField(t *testing.T) {
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		return []byte{frame[0], frame[1], 0x02, 0x00, 0x2A}
+	})
+	defer stop()
+
+	if c.TCPClient == nil || c.Sender != client.Sender(c.TCPClient) {
+		t.Fatalf("expected NewModbusClient to set TCPClient and Sender alike")
+	}
+	legacy := &ModbusClient{TCPClient: c.TCPClient}
+	values, err := legacy.ReadHoldingRegisters(1, 0, 1)
+	if err != nil || values[0] != 42 {
+		t.Errorf("ReadHoldingRegisters() = %v, %v", values, err)
+	}
+}
+
+func TestModbusClient_ReadHoldingRegistersContext(t *testing.T) {
+	hang := make(chan struct{})
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		<-hang
+		return []byte{frame[0], frame[1], 0x02, 0x00, 0x00}
+	})
+	defer stop()
+	defer close(hang)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := c.ReadHoldingRegistersContext(ctx, 1, 0, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the call to end with the context, took %v", elapsed)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.ReadTagsContext(ctx, []modbus.Tag{{Name: "t", Table: modbus.TableHoldingRegisters, Type: modbus.TypeUint16}}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+// deviceIDReply answers a Read Device ID request from objects, two objects
+// per reply, as a device with little buffer space would.
+func deviceIDReply(frame []byte, objects []modbus.DeviceIDObject) []byte {
+	req := &modbus.ReadDeviceIDRequest{}
+	if err := req.Parse(frame); err != nil {
+		return []byte{frame[0], frame[1] | 0x80, 0x03}
+	}
+
+	resp := &modbus.ReadDeviceIDResponse{SlaveID: req.SlaveID, Code: req.Code, ConformityLevel: 0x83}
+	for i, obj := range objects {
+		if obj.ID < req.ObjectID ||
+			req.Code == modbus.ReadDeviceIDBasic && obj.ID > modbus.ObjectMajorMinorRevision ||
+			req.Code == modbus.ReadDeviceIDRegular && obj.ID > 0x7F {
+			continue
+		}
+		if req.Code == modbus.ReadDeviceIDIndividual {
+			if obj.ID == req.ObjectID {
+				resp.Objects = append(resp.Objects, obj)
+			}
+			continue
+		}
+		if len(resp.Objects) == 2 {
+			resp.MoreFollows, resp.NextObjectID = true, objects[i].ID
+			break
+		}
+		resp.Objects = append(resp.Objects, obj)
+	}
+	reply, _ := resp.Build()
+	return reply
+}
+
+func TestModbusClient_ReadDeviceIdentification(t *testing.T) {
+	objects := []modbus.DeviceIDObject{
+		{ID: modbus.ObjectVendorName, Value: []byte("ACME")},
+		{ID: modbus.ObjectProductCode, Value: []byte("PM-3")},
+		{ID: modbus.ObjectMajorMinorRevision, Value: []byte("V2.11")},
+		{ID: 0x80, Value: []byte("serial 1234")},
+	}
+	var requests int
+	c, stop := startFakeDevice(t, func(frame []byte) []byte {
+		requests++
+		return deviceIDReply(frame, objects)
+	})
+	defer stop()
+
+	got, err := c.ReadDeviceIdentification(0x01, modbus.ReadDeviceIDExtended, 0)
+	if err != nil {
+		t.Fatalf("ReadDeviceIdentification() error: %v", err)
+	}
+	expected := map[byte]string{0x00: "ACME", 0x01: "PM-3", 0x02: "V2.11", 0x80: "serial 1234"}
+	if !reflect.DeepEqual(got, expected) || requests != 2 {
+		t.Errorf("expected %v in 2 requests, got %v in %d", expected, got, requests)
+	}
+
+	got, err = c.ReadDeviceIdentification(0x01, modbus.ReadDeviceIDIndividual, 0x80)
+	if err != nil || !reflect.DeepEqual(got, map[byte]string{0x80: "serial 1234"}) {
+		t.Errorf("unexpected individual object: %v, %v", got, err)
+	}
+
+	// Over RTU the reply length is found by walking the object list.
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+	go func() {
+		for {
+			req := make([]byte, 7)
+			if _, err := io.ReadFull(device, req); err != nil {
+				return
+			}
+			reply, _ := (&modbus.RTURequestWrapper{ModbusFrame: deviceIDReply(req[:5], objects)}).Build()
+			device.Write(reply)
+		}
+	}()
+
+	got, err = NewRTUModbusClient(line, 19200, time.Second).ReadDeviceIdentification(0x01, modbus.ReadDeviceIDBasic, 0)
+	delete(expected, 0x80)
+	if err != nil || !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected objects over RTU: %v, %v", got, err)
+	}
+}
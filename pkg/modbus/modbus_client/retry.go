@@ -0,0 +1,123 @@
+package modbus_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/client"
+	"syscall"
+	"time"
+)
+
+// DefaultRetryOn lists the errors retried when RetryPolicy.RetryOn is nil:
+// timeouts, connections dropped by the device, and the exceptions a device or
+// gateway uses to say the request should be sent again later.
+var DefaultRetryOn = []error{
+	client.ErrTimeout,
+	io.EOF,
+	io.ErrUnexpectedEOF,
+	syscall.ECONNRESET,
+	syscall.EPIPE,
+	modbus.ErrSlaveDeviceBusy,
+	modbus.ErrAcknowledge,
+	modbus.ErrGatewayTargetFailedToRespond,
+}
+
+// RetryPolicy decides whether a failed request is sent again, and after how
+// long. Each attempt gets a connection of its own: one that failed is closed
+// rather than returned to the pool.
+type RetryPolicy struct {
+	// MaxAttempts is the number of tries, the first included. Values below 2
+	// disable retries.
+	MaxAttempts int
+	// Backoff is the wait before the first retry. Each further wait is
+	// Multiplier (2 if zero) times longer, up to MaxBackoff if set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Multiplier float64
+	// Jitter spreads each wait randomly by up to that fraction of it either
+	// way (0.2 gives 80% to 120%), so that clients do not retry in step.
+	Jitter float64
+	// RetryOn lists the errors worth retrying, matched with errors.Is.
+	// Exception codes are matched through their sentinels, e.g.
+	// modbus.ErrSlaveDeviceBusy. Nil means DefaultRetryOn.
+	RetryOn []error
+	// RetryWrites allows writes to be retried. A write whose reply was lost
+	// may have been applied, so this is only safe if writing twice is.
+	RetryWrites bool
+}
+
+// DefaultRetryPolicy makes up to 3 attempts, waiting about 100ms and then
+// 200ms in between.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     100 * time.Millisecond,
+		MaxBackoff:  2 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	retryOn := p.RetryOn
+	if retryOn == nil {
+		retryOn = DefaultRetryOn
+	}
+	for _, target := range retryOn {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// wait returns how long to wait before retry number retry (1 for the first).
+func (p *RetryPolicy) wait(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+
+	d := float64(p.Backoff)
+	for i := 1; i < retry && (p.MaxBackoff == 0 || d < float64(p.MaxBackoff)); i++ {
+		d *= multiplier
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(d)
+}
+
+// send executes frame through the Sender, retrying according to Retry. write
+// marks requests that change the device, which are only retried if the
+// policy allows it.
+func (c *ModbusClient) send(ctx context.Context, frame []byte, write bool) ([]byte, error) {
+	policy := c.Retry
+	if policy == nil || policy.MaxAttempts < 2 || (write && !policy.RetryWrites) {
+		return c.sender().SendContext(ctx, frame)
+	}
+
+	for attempt := 1; ; attempt++ {
+		respFrame, err := c.sender().SendContext(ctx, frame)
+		if err == nil || ctx.Err() != nil || !policy.retryable(err) {
+			return respFrame, err
+		}
+		if attempt >= policy.MaxAttempts {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		timer := time.NewTimer(policy.wait(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package modbus_client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/client"
+	"net"
+	"testing"
+	"time"
+)
+
+// scriptedSender fails the first len(errs) sends with errs, in order, and
+// answers the rest with reply.
+type scriptedSender struct {
+	errs  []error
+	reply []byte
+	sends int
+}
+
+func (s *scriptedSender) Send(frame []byte) ([]byte, error) {
+	return s.SendContext(context.Background(), frame)
+}
+
+func (s *scriptedSender) SendContext(ctx context.Context, frame []byte) ([]byte, error) {
+	s.sends++
+	if s.sends <= len(s.errs) {
+		return nil, s.errs[s.sends-1]
+	}
+	return s.reply, nil
+}
+
+func fastRetry() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+}
+
+func TestRetry_TransientErrors(t *testing.T) {
+	sender := &scriptedSender{
+		errs:  []error{modbus.ErrSlaveDeviceBusy, client.ErrTimeout},
+		reply: []byte{0x01, 0x03, 0x02, 0x00, 0x2A},
+	}
+	c := &ModbusClient{Sender: sender, Retry: fastRetry()}
+
+	values, err := c.ReadHoldingRegisters(1, 0, 1)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters() error: %v", err)
+	}
+	if values[0] != 42 || sender.sends != 3 {
+		t.Errorf("expected 42 after 3 sends, got %v after %d", values, sender.sends)
+	}
+
+	sender = &scriptedSender{errs: []error{client.ErrTimeout, client.ErrTimeout, client.ErrTimeout}}
+	c.Sender = sender
+	if _, err := c.ReadHoldingRegisters(1, 0, 1); !errors.Is(err, client.ErrTimeout) || sender.sends != 3 {
+		t.Errorf("expected ErrTimeout after 3 sends, got %v after %d", err, sender.sends)
+	}
+}
+
+func TestRetry_NotRetryable(t *testing.T) {
+	sender := &scriptedSender{errs: []error{modbus.ErrIllegalDataAddress}}
+	c := &ModbusClient{Sender: sender, Retry: fastRetry()}
+
+	if _, err := c.ReadCoils(1, 0, 1); !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Errorf("expected ErrIllegalDataAddress, got %v", err)
+	}
+	if sender.sends != 1 {
+		t.Errorf("expected 1 send, got %d", sender.sends)
+	}
+
+	// RetryOn replaces the defaults.
+	sender = &scriptedSender{errs: []error{modbus.ErrIllegalDataAddress}, reply: []byte{0x01, 0x01, 0x01, 0x01}}
+	c.Sender = sender
+	c.Retry.RetryOn = []error{modbus.ErrIllegalDataAddress}
+	if _, err := c.ReadCoils(1, 0, 1); err != nil || sender.sends != 2 {
+		t.Errorf("expected success after 2 sends, got %v after %d", err, sender.sends)
+	}
+}
+
+func TestRetry_Writes(t *testing.T) {
+	reply := []byte{0x01, 0x06, 0x00, 0x00, 0x00, 0x07}
+
+	sender := &scriptedSender{errs: []error{modbus.ErrSlaveDeviceBusy}, reply: reply}
+	c := &ModbusClient{Sender: sender, Retry: fastRetry()}
+	if err := c.WriteSingleRegister(1, 0, 7); !errors.Is(err, modbus.ErrSlaveDeviceBusy) || sender.sends != 1 {
+		t.Errorf("expected write not to be retried, got %v after %d sends", err, sender.sends)
+	}
+
+	sender = &scriptedSender{errs: []error{modbus.ErrSlaveDeviceBusy}, reply: reply}
+	c.Sender = sender
+	c.Retry.RetryWrites = true
+	if err := c.WriteSingleRegister(1, 0, 7); err != nil || sender.sends != 2 {
+		t.Errorf("expected write to succeed after 2 sends, got %v after %d", err, sender.sends)
+	}
+}
+
+func TestRetry_CancelledDuringBackoff(t *testing.T) {
+	sender := &scriptedSender{errs: []error{client.ErrTimeout}}
+	c := &ModbusClient{Sender: sender, Retry: &RetryPolicy{MaxAttempts: 2, Backoff: time.Hour}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.ReadHoldingRegistersContext(ctx, 1, 0, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// TestRetry_BrokenPooledConnection checks that a pooled connection the device
+// has dropped is discarded, and the retry dials a new one.
+func TestRetry_BrokenPooledConnection(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				request := make([]byte, 12)
+				if _, err := io.ReadFull(conn, request); err != nil {
+					return
+				}
+				reply := append(request[:4:4], 0x00, 0x05, request[6], 0x03, 0x02, 0x00, 0x2A)
+				conn.Write(reply)
+				// Hang up after one transaction, as a device that restarts.
+			}(conn)
+		}
+	}()
+
+	pool := client.NewTCPConnectionPool(ln.Addr().String(), time.Second, 1)
+	defer pool.Close()
+	c := NewModbusClient("", 0, time.Second, pool)
+	c.Retry = fastRetry()
+
+	for i := 0; i < 3; i++ {
+		values, err := c.ReadHoldingRegisters(1, 0, 1)
+		if err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+		if values[0] != 42 {
+			t.Errorf("read %d: expected 42, got %d", i, values[0])
+		}
+		// Let the device hang up the pooled connection.
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TestRetry_PipelinedTimeout checks that the default policy retries timeouts
+// of a pipelined client, whose replies the device may drop.
+func TestRetry_PipelinedTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for n := 1; ; n++ {
+			request := make([]byte, 12)
+			if _, err := io.ReadFull(conn, request); err != nil {
+				return
+			}
+			// The first request is lost.
+			if n > 1 {
+				conn.Write(append(request[:4:4], 0x00, 0x05, request[6], 0x03, 0x02, 0x00, 0x2A))
+			}
+		}
+	}()
+
+	c := NewPipelinedModbusClient("127.0.0.1", ln.Addr().(*net.TCPAddr).Port, 50*time.Millisecond, 2)
+	c.Retry = DefaultRetryPolicy()
+	values, err := c.ReadHoldingRegisters(1, 0, 1)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters() error: %v", err)
+	}
+	if values[0] != 42 {
+		t.Errorf("expected 42, got %d", values[0])
+	}
+}
+
+func TestRetryPolicy_Wait(t *testing.T) {
+	p := &RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for retry, expected := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 10: time.Second} {
+		if d := p.wait(retry); d != expected {
+			t.Errorf("wait(%d) = %v, expected %v", retry, d, expected)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.wait(1); d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Fatalf("wait(1) with jitter = %v, expected 50ms to 150ms", d)
+		}
+	}
+}
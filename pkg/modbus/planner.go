@@ -0,0 +1,180 @@
+package modbus
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Point is a value a caller wants read: Length consecutive coils or
+// registers starting at Address (e.g. 2 registers for a float32). A zero
+// Length means 1.
+type Point struct {
+	UnitID  byte
+	Table   Table
+	Address uint16
+	Length  uint16
+}
+
+// PlannedRead is one request of a ReadPlan. Slots tell where the values of
+// each point are within the values returned by the request.
+type PlannedRead struct {
+	UnitID   byte
+	Table    Table
+	Address  uint16
+	Quantity uint16
+	Slots    []PointSlot
+}
+
+// PointSlot maps values[Offset:Offset+Length] of a read onto the values of
+// point Point (an index into the slice given to Plan), starting at Start. A
+// point longer than one request allows is spread over several slots.
+type PointSlot struct {
+	Point  int
+	Offset int
+	Start  int
+	Length int
+}
+
+// ReadPlan is the set of requests that covers every point given to
+// Planner.Plan.
+type ReadPlan struct {
+	Reads   []PlannedRead
+	lengths []int
+}
+
+// Planner turns a set of wanted points into as few reading requests as
+// possible.
+type Planner struct {
+	// MaxGap is the number of unwanted addresses the planner may read to join
+	// two points into one request.
+	MaxGap int
+	// MaxRegisters and MaxBits lower the number of registers, and coils or
+	// discrete inputs, read per request for devices that cannot handle the
+	// protocol limits. Zero means the protocol limit.
+	MaxRegisters int
+	MaxBits      int
+}
+
+// Plan groups points by unit ID and table and covers each group with
+// requests that read at most the quantity limit each. Points may overlap.
+func (p *Planner) Plan(points []Point) (*ReadPlan, error) {
+	plan := &ReadPlan{lengths: make([]int, len(points))}
+
+	order := make([]int, len(points))
+	for i, pt := range points {
+		if pt.Table > TableInputRegisters {
+			return nil, fmt.Errorf("point %d: unknown Modbus table %d", i, pt.Table)
+		}
+		length := int(pt.Length)
+		if length == 0 {
+			length = 1
+		}
+		if int(pt.Address)+length > 0x10000 {
+			return nil, fmt.Errorf("point %d: %d values from address %d run past the end of the address space", i, length, pt.Address)
+		}
+		plan.lengths[i] = length
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := points[order[i]], points[order[j]]
+		if a.UnitID != b.UnitID {
+			return a.UnitID < b.UnitID
+		}
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		return a.Address < b.Address
+	})
+
+	group := 0
+	for _, i := range order {
+		pt := points[i]
+		limit := p.limit(pt.Table)
+
+		// Reads from group on are for the unit and table of pt.
+		if n := len(plan.Reads); n > group && (plan.Reads[n-1].UnitID != pt.UnitID || plan.Reads[n-1].Table != pt.Table) {
+			group = n
+		}
+
+		a, end := int(pt.Address), int(pt.Address)+plan.lengths[i]
+		for a < end {
+			// Points overlapping a point split across reads can start in a
+			// read before the last one, which only the last may grow.
+			cur := len(plan.Reads) - 1
+			for cur > group && a < int(plan.Reads[cur].Address) {
+				cur--
+			}
+			last := cur == len(plan.Reads)-1
+			if cur < group || a < int(plan.Reads[cur].Address) || a >= int(plan.Reads[cur].Address)+limit ||
+				(last && a > plan.Reads[cur].end()+p.MaxGap) || (!last && a >= plan.Reads[cur].end()) {
+				plan.Reads = append(plan.Reads, PlannedRead{UnitID: pt.UnitID, Table: pt.Table, Address: uint16(a)})
+				cur, last = len(plan.Reads)-1, true
+			}
+			read := &plan.Reads[cur]
+
+			segEnd := min(end, int(read.Address)+limit)
+			if !last {
+				segEnd = min(segEnd, read.end())
+			}
+			if segEnd > read.end() {
+				read.Quantity = uint16(segEnd - int(read.Address))
+			}
+			read.Slots = append(read.Slots, PointSlot{
+				Point:  i,
+				Offset: a - int(read.Address),
+				Start:  a - int(pt.Address),
+				Length: segEnd - a,
+			})
+			a = segEnd
+		}
+	}
+
+	return plan, nil
+}
+
+func (p *Planner) limit(table Table) int {
+	limit, max := MaxReadRegisters, p.MaxRegisters
+	if table.IsBit() {
+		limit, max = MaxReadCoils, p.MaxBits
+	}
+	if max > 0 && max < limit {
+		return max
+	}
+	return limit
+}
+
+// Request returns the reading request for r.
+func (r *PlannedRead) Request() *ReadingRequest {
+	return &ReadingRequest{
+		Header:   NewModbusHeader(r.Table.ReadFunctionCode(), r.UnitID, r.Address),
+		Quantity: r.Quantity,
+	}
+}
+
+func (r *PlannedRead) end() int {
+	return int(r.Address) + int(r.Quantity)
+}
+
+// Assemble maps the values returned by each read of the plan, in order, back
+// to the points: the result holds the values of every point, in the order the
+// points were given to Plan. Coils and discrete inputs are passed as 0 or 1.
+func (p *ReadPlan) Assemble(results [][]uint16) ([][]uint16, error) {
+	if len(results) != len(p.Reads) {
+		return nil, fmt.Errorf("expected results for %d reads, got %d", len(p.Reads), len(results))
+	}
+
+	values := make([][]uint16, len(p.lengths))
+	for i, length := range p.lengths {
+		values[i] = make([]uint16, length)
+	}
+	for i, read := range p.Reads {
+		if len(results[i]) != int(read.Quantity) {
+			return nil, fmt.Errorf("read %d: expected %d values, got %d", i, read.Quantity, len(results[i]))
+		}
+		for _, s := range read.Slots {
+			copy(values[s.Point][s.Start:], results[i][s.Offset:s.Offset+s.Length])
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,176 @@
+package modbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+// reads summarises a plan as [address, quantity] pairs.
+func reads(plan *ReadPlan) [][2]int {
+	var out [][2]int
+	for _, r := range plan.Reads {
+		out = append(out, [2]int{int(r.Address), int(r.Quantity)})
+	}
+	return out
+}
+
+// fakeResults answers every read of plan with values equal to their address.
+func fakeResults(plan *ReadPlan) [][]uint16 {
+	results := make([][]uint16, len(plan.Reads))
+	for i, r := range plan.Reads {
+		results[i] = make([]uint16, r.Quantity)
+		for j := range results[i] {
+			results[i][j] = r.Address + uint16(j)
+		}
+	}
+	return results
+}
+
+func TestPlanner_MergesWithinGap(t *testing.T) {
+	points := []Point{
+		{Table: TableHoldingRegisters, Address: 10, Length: 2},
+		{Table: TableHoldingRegisters, Address: 0},
+		{Table: TableHoldingRegisters, Address: 14},
+		{Table: TableHoldingRegisters, Address: 30},
+	}
+
+	p := &Planner{MaxGap: 2}
+	plan, err := p.Plan(points)
+	if err != nil {
+		t.Fatalf("Plan() error: %v", err)
+	}
+	if got := reads(plan); !reflect.DeepEqual(got, [][2]int{{0, 1}, {10, 5}, {30, 1}}) {
+		t.Errorf("unexpected reads with gap 2: %v", got)
+	}
+
+	p.MaxGap = 10
+	plan, _ = p.Plan(points)
+	if got := reads(plan); !reflect.DeepEqual(got, [][2]int{{0, 15}, {30, 1}}) {
+		t.Errorf("unexpected reads with gap 10: %v", got)
+	}
+
+	values, err := plan.Assemble(fakeResults(plan))
+	if err != nil {
+		t.Fatalf("Assemble() error: %v", err)
+	}
+	expected := [][]uint16{{10, 11}, {0}, {14}, {30}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("values not mapped back to points.\nExpected: %v\nGot:      %v", expected, values)
+	}
+}
+
+func TestPlanner_SplitsAtLimit(t *testing.T) {
+	var points []Point
+	for a := uint16(0); a < 300; a++ {
+		points = append(points, Point{Table: TableInputRegisters, Address: a})
+	}
+	// A float32 straddling the 125 register boundary.
+	points = append(points, Point{Table: TableInputRegisters, Address: 124, Length: 2})
+
+	plan, err := (&Planner{}).Plan(points)
+	if err != nil {
+		t.Fatalf("Plan() error: %v", err)
+	}
+	if got := reads(plan); !reflect.DeepEqual(got, [][2]int{{0, 125}, {125, 125}, {250, 50}}) {
+		t.Errorf("unexpected reads: %v", got)
+	}
+
+	values, _ := plan.Assemble(fakeResults(plan))
+	if !reflect.DeepEqual(values[300], []uint16{124, 125}) {
+		t.Errorf("point split across reads not reassembled: %v", values[300])
+	}
+	for _, r := range plan.Reads {
+		if _, err := r.Request().Build(); err != nil {
+			t.Errorf("planned request does not build: %v", err)
+		}
+	}
+}
+
+func TestPlanner_GroupsByUnitAndTable(t *testing.T) {
+	points := []Point{
+		{UnitID: 2, Table: TableCoils, Address: 0, Length: 8},
+		{UnitID: 1, Table: TableCoils, Address: 8, Length: 8},
+		{UnitID: 1, Table: TableDiscreteInputs, Address: 0},
+		{UnitID: 1, Table: TableCoils, Address: 0, Length: 8},
+	}
+
+	plan, err := (&Planner{MaxBits: 10}).Plan(points)
+	if err != nil {
+		t.Fatalf("Plan() error: %v", err)
+	}
+
+	var got []string
+	for _, r := range plan.Reads {
+		got = append(got, r.Request().Header.FC.String())
+		if r.Quantity > 10 {
+			t.Errorf("read of %d bits exceeds MaxBits", r.Quantity)
+		}
+	}
+	expected := []string{"Read Coils", "Read Coils", "Read Input Status", "Read Coils"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected reads: %v", got)
+	}
+	if plan.Reads[3].UnitID != 2 {
+		t.Errorf("expected last read to be for unit 2, got %d", plan.Reads[3].UnitID)
+	}
+}
+
+func TestPlanner_OverlappingPoints(t *testing.T) {
+	points := []Point{
+		{Table: TableHoldingRegisters, Address: 0, Length: 4},
+		{Table: TableHoldingRegisters, Address: 2, Length: 2},
+		{Table: TableHoldingRegisters, Address: 0, Length: 4},
+	}
+	plan, _ := (&Planner{}).Plan(points)
+	if got := reads(plan); !reflect.DeepEqual(got, [][2]int{{0, 4}}) {
+		t.Errorf("unexpected reads: %v", got)
+	}
+
+	values, _ := plan.Assemble(fakeResults(plan))
+	expected := [][]uint16{{0, 1, 2, 3}, {2, 3}, {0, 1, 2, 3}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("unexpected values: %v", values)
+	}
+}
+
+func TestPlanner_OverlappingPointsAcrossSplit(t *testing.T) {
+	points := []Point{
+		{Table: TableHoldingRegisters, Address: 0, Length: 200},
+		{Table: TableHoldingRegisters, Address: 50},
+		{Table: TableHoldingRegisters, Address: 124, Length: 2},
+		{Table: TableHoldingRegisters, Address: 130, Length: 80},
+	}
+	plan, err := (&Planner{}).Plan(points)
+	if err != nil {
+		t.Fatalf("Plan() error: %v", err)
+	}
+	if got := reads(plan); !reflect.DeepEqual(got, [][2]int{{0, 125}, {125, 85}}) {
+		t.Errorf("unexpected reads: %v", got)
+	}
+
+	values, err := plan.Assemble(fakeResults(plan))
+	if err != nil {
+		t.Fatalf("Assemble() error: %v", err)
+	}
+	for i, pt := range points {
+		for j, v := range values[i] {
+			if int(v) != int(pt.Address)+j {
+				t.Fatalf("point %d: unexpected values %v", i, values[i])
+			}
+		}
+	}
+}
+
+func TestPlanner_Errors(t *testing.T) {
+	if _, err := (&Planner{}).Plan([]Point{{Table: TableHoldingRegisters, Address: 0xFFFF, Length: 2}}); err == nil {
+		t.Error("expected error for point past the end of the address space, got nil")
+	}
+
+	plan, _ := (&Planner{}).Plan([]Point{{Table: TableCoils, Address: 0, Length: 3}})
+	if _, err := plan.Assemble([][]uint16{{1, 0}}); err == nil {
+		t.Error("expected error for short result, got nil")
+	}
+	if _, err := plan.Assemble(nil); err == nil {
+		t.Error("expected error for missing results, got nil")
+	}
+}
@@ -0,0 +1,269 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"modbus_client/pkg/modbus"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDeviceBusy is reported for a poll that was skipped because all of the
+	// device's concurrent slots were taken by slower polls.
+	ErrDeviceBusy = errors.New("poll skipped: device busy")
+	// ErrDeviceBackoff is reported for a poll that was skipped because the
+	// device failed too many times in a row and is being left alone for a
+	// while.
+	ErrDeviceBackoff = errors.New("poll skipped: device failing, backing off")
+)
+
+// Reader is the part of a Modbus client the poller uses.
+// *modbus_client.ModbusClient implements it. Reads are passed the context of
+// Run, so that stopping the poller abandons the reads in flight.
+type Reader interface {
+	ReadCoilsContext(ctx context.Context, unitID byte, address, quantity uint16) ([]bool, error)
+	ReadDiscreteInputsContext(ctx context.Context, unitID byte, address, quantity uint16) ([]bool, error)
+	ReadHoldingRegistersContext(ctx context.Context, unitID byte, address, quantity uint16) ([]uint16, error)
+	ReadInputRegistersContext(ctx context.Context, unitID byte, address, quantity uint16) ([]uint16, error)
+}
+
+// Job is a read repeated every Interval.
+type Job struct {
+	Name     string
+	Device   string
+	UnitID   byte
+	Table    modbus.Table
+	Address  uint16
+	Quantity uint16
+	Interval time.Duration
+}
+
+// Result is the outcome of one poll of a Job. Bits is set for coils and
+// discrete inputs, Registers for holding and input registers. Err is
+// ErrDeviceBusy or ErrDeviceBackoff for skipped polls.
+type Result struct {
+	Job       *Job
+	Time      time.Time
+	Latency   time.Duration
+	Bits      []bool
+	Registers []uint16
+	Err       error
+}
+
+type device struct {
+	reader Reader
+	slots  chan struct{}
+
+	mu       sync.Mutex
+	failures int
+	until    time.Time
+}
+
+// Poller runs Jobs against a set of named devices. Every job has its own
+// schedule, so a slow or failing device only delays the jobs that poll it.
+type Poller struct {
+	// Jitter delays every poll by a random amount up to Jitter, so that jobs
+	// with the same interval do not all hit the network at once.
+	Jitter time.Duration
+	// MaxFailures is the number of consecutive failed polls after which a
+	// device is skipped for Backoff. Zero disables the backoff.
+	MaxFailures int
+	Backoff     time.Duration
+
+	mu      sync.Mutex
+	devices map[string]*device
+	jobs    []*Job
+}
+
+func NewPoller() *Poller {
+	return &Poller{
+		devices: make(map[string]*device),
+	}
+}
+
+// AddDevice registers a device that jobs refer to by name. At most
+// maxConcurrent of its jobs are polled at the same time; use 1 for devices
+// that handle one request at a time.
+func (p *Poller) AddDevice(name string, reader Reader, maxConcurrent int) {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.devices[name] = &device{
+		reader: reader,
+		slots:  make(chan struct{}, maxConcurrent),
+	}
+}
+
+// AddJob adds a job to be started by the next call to Run.
+func (p *Poller) AddJob(job Job) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.devices[job.Device]; !ok {
+		return fmt.Errorf("job %q: unknown device %q", job.Name, job.Device)
+	}
+	if job.Interval <= 0 {
+		return fmt.Errorf("job %q: interval must be positive", job.Name)
+	}
+	if job.Table > modbus.TableInputRegisters {
+		return fmt.Errorf("job %q: unknown Modbus table %d", job.Name, job.Table)
+	}
+	if job.Quantity < 1 || int(job.Quantity) > job.Table.MaxReadQuantity() {
+		return fmt.Errorf("job %q: quantity %d out of range for %v (limit %d)",
+			job.Name, job.Quantity, job.Table, job.Table.MaxReadQuantity())
+	}
+
+	p.jobs = append(p.jobs, &job)
+	return nil
+}
+
+// Run polls every job until ctx is cancelled, passing each result to
+// deliver. deliver is called from several goroutines at once and should
+// return quickly: a job does not poll again until deliver returns.
+func (p *Poller) Run(ctx context.Context, deliver func(Result)) error {
+	p.mu.Lock()
+	jobs := p.jobs
+	p.mu.Unlock()
+
+	if len(jobs) == 0 {
+		return errors.New("no jobs to poll")
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.runJob(ctx, job, deliver)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Start runs the poller in the background and returns a channel carrying its
+// results. The channel is closed once ctx is cancelled and every job has
+// stopped.
+func (p *Poller) Start(ctx context.Context, buffer int) <-chan Result {
+	results := make(chan Result, buffer)
+	go func() {
+		defer close(results)
+		p.Run(ctx, func(r Result) {
+			select {
+			case results <- r:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return results
+}
+
+// runJob polls job on a fixed schedule. Ticks missed because a poll overran
+// the interval are dropped rather than polled in a burst.
+func (p *Poller) runJob(ctx context.Context, job *Job, deliver func(Result)) {
+	p.mu.Lock()
+	dev := p.devices[job.Device]
+	p.mu.Unlock()
+
+	next := time.Now()
+	timer := time.NewTimer(p.jitter())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		result := p.poll(ctx, job, dev)
+		if ctx.Err() != nil {
+			// The poll was cut short by the shutdown, not by the device.
+			return
+		}
+		deliver(result)
+
+		next = next.Add(job.Interval)
+		if now := time.Now(); !next.After(now) {
+			missed := now.Sub(next)/job.Interval + 1
+			next = next.Add(missed * job.Interval)
+		}
+		timer.Reset(time.Until(next) + p.jitter())
+	}
+}
+
+func (p *Poller) poll(ctx context.Context, job *Job, dev *device) Result {
+	result := Result{Job: job, Time: time.Now()}
+
+	dev.mu.Lock()
+	backingOff := result.Time.Before(dev.until)
+	dev.mu.Unlock()
+	if backingOff {
+		result.Err = ErrDeviceBackoff
+		return result
+	}
+
+	select {
+	case dev.slots <- struct{}{}:
+		defer func() { <-dev.slots }()
+	default:
+		result.Err = ErrDeviceBusy
+		return result
+	}
+	// The slot may have been freed by a read the shutdown abandoned; that
+	// must not let another read start.
+	if err := ctx.Err(); err != nil {
+		result.Err = err
+		return result
+	}
+
+	r := dev.reader
+	switch job.Table {
+	case modbus.TableCoils:
+		result.Bits, result.Err = r.ReadCoilsContext(ctx, job.UnitID, job.Address, job.Quantity)
+	case modbus.TableDiscreteInputs:
+		result.Bits, result.Err = r.ReadDiscreteInputsContext(ctx, job.UnitID, job.Address, job.Quantity)
+	case modbus.TableHoldingRegisters:
+		result.Registers, result.Err = r.ReadHoldingRegistersContext(ctx, job.UnitID, job.Address, job.Quantity)
+	case modbus.TableInputRegisters:
+		result.Registers, result.Err = r.ReadInputRegistersContext(ctx, job.UnitID, job.Address, job.Quantity)
+	}
+	result.Latency = time.Since(result.Time)
+
+	if ctx.Err() == nil {
+		p.recordOutcome(dev, result.Err)
+	}
+	return result
+}
+
+// recordOutcome counts consecutive failures of dev. A Modbus exception is an
+// answer from a working device, so it does not count.
+func (p *Poller) recordOutcome(dev *device, err error) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	var exc *modbus.ModbusException
+	if err == nil || errors.As(err, &exc) {
+		dev.failures = 0
+		return
+	}
+
+	dev.failures++
+	if p.MaxFailures > 0 && dev.failures >= p.MaxFailures {
+		dev.until = time.Now().Add(p.Backoff)
+		dev.failures = 0
+	}
+}
+
+func (p *Poller) jitter() time.Duration {
+	if p.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(p.Jitter)))
+}
@@ -0,0 +1,223 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/modbus_client"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var _ Reader = (*modbus_client.ModbusClient)(nil)
+
+// fakeReader answers every read with registers counting up from address,
+// after delay, or with err. A read cancelled during the delay returns
+// ctx.Err().
+type fakeReader struct {
+	delay time.Duration
+	err   error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *fakeReader) read(ctx context.Context, address, quantity uint16) ([]uint16, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+
+	timer := time.NewTimer(f.delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	values := make([]uint16, quantity)
+	for i := range values {
+		values[i] = address + uint16(i)
+	}
+	return values, nil
+}
+
+func (f *fakeReader) ReadCoilsContext(ctx context.Context, unitID byte, address, quantity uint16) ([]bool, error) {
+	values, err := f.read(ctx, address, quantity)
+	bits := make([]bool, len(values))
+	for i, v := range values {
+		bits[i] = v%2 == 1
+	}
+	return bits, err
+}
+
+func (f *fakeReader) ReadDiscreteInputsContext(ctx context.Context, unitID byte, address, quantity uint16) ([]bool, error) {
+	return f.ReadCoilsContext(ctx, unitID, address, quantity)
+}
+
+func (f *fakeReader) ReadHoldingRegistersContext(ctx context.Context, unitID byte, address, quantity uint16) ([]uint16, error) {
+	return f.read(ctx, address, quantity)
+}
+
+func (f *fakeReader) ReadInputRegistersContext(ctx context.Context, unitID byte, address, quantity uint16) ([]uint16, error) {
+	return f.read(ctx, address, quantity)
+}
+
+func (f *fakeReader) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// collect runs p for d and returns the results grouped by job name.
+func collect(t *testing.T, p *Poller, d time.Duration) map[string][]Result {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	results := make(map[string][]Result)
+	for r := range p.Start(ctx, 16) {
+		results[r.Job.Name] = append(results[r.Job.Name], r)
+	}
+	return results
+}
+
+func TestPoller_Results(t *testing.T) {
+	p := NewPoller()
+	p.AddDevice("plc", &fakeReader{}, 1)
+	p.AddJob(Job{Name: "registers", Device: "plc", Table: modbus.TableHoldingRegisters, Address: 10, Quantity: 3, Interval: 20 * time.Millisecond})
+	p.AddJob(Job{Name: "coils", Device: "plc", Table: modbus.TableCoils, Address: 0, Quantity: 2, Interval: 50 * time.Millisecond})
+
+	results := collect(t, p, 190*time.Millisecond)
+
+	registers := results["registers"]
+	if len(registers) < 7 || len(registers) > 11 {
+		t.Errorf("expected about 10 register polls, got %d", len(registers))
+	}
+	for _, r := range registers {
+		if r.Err != nil {
+			t.Fatalf("unexpected poll error: %v", r.Err)
+		}
+		if !reflect.DeepEqual(r.Registers, []uint16{10, 11, 12}) {
+			t.Fatalf("unexpected registers: %v", r.Registers)
+		}
+	}
+	for i := 1; i < len(registers); i++ {
+		if !registers[i].Time.After(registers[i-1].Time) {
+			t.Errorf("results not timestamped in order: %v then %v", registers[i-1].Time, registers[i].Time)
+		}
+	}
+
+	coils := results["coils"]
+	if len(coils) < 3 || !reflect.DeepEqual(coils[0].Bits, []bool{false, true}) {
+		t.Errorf("unexpected coil results: %+v", coils)
+	}
+}
+
+func TestPoller_SlowDeviceDoesNotStallOthers(t *testing.T) {
+	slow := &fakeReader{delay: 200 * time.Millisecond}
+	p := NewPoller()
+	p.AddDevice("slow", slow, 1)
+	p.AddDevice("fast", &fakeReader{}, 1)
+	p.AddJob(Job{Name: "slow-a", Device: "slow", Table: modbus.TableInputRegisters, Quantity: 1, Interval: 20 * time.Millisecond})
+	p.AddJob(Job{Name: "slow-b", Device: "slow", Table: modbus.TableInputRegisters, Quantity: 1, Interval: 20 * time.Millisecond})
+	p.AddJob(Job{Name: "fast", Device: "fast", Table: modbus.TableInputRegisters, Quantity: 1, Interval: 20 * time.Millisecond})
+
+	results := collect(t, p, 150*time.Millisecond)
+
+	if n := len(results["fast"]); n < 5 {
+		t.Errorf("expected the fast device to keep being polled, got %d results", n)
+	}
+
+	// The two slow jobs share one slot: whichever is second finds it taken.
+	var busy int
+	for _, r := range append(results["slow-a"], results["slow-b"]...) {
+		if errors.Is(r.Err, ErrDeviceBusy) {
+			busy++
+		}
+	}
+	if busy == 0 {
+		t.Error("expected polls of the busy device to be skipped")
+	}
+	if n := slow.callCount(); n != 1 {
+		t.Errorf("expected one read in flight on the slow device, got %d", n)
+	}
+}
+
+func TestPoller_CancelAbandonsReads(t *testing.T) {
+	p := NewPoller()
+	p.AddDevice("hung", &fakeReader{delay: time.Hour}, 1)
+	p.AddJob(Job{Name: "hung", Device: "hung", Table: modbus.TableHoldingRegisters, Quantity: 1, Interval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx, func(Result) {}) }()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return once ctx was cancelled")
+	}
+}
+
+func TestPoller_Backoff(t *testing.T) {
+	failing := &fakeReader{err: errors.New("connection refused")}
+	p := NewPoller()
+	p.MaxFailures = 2
+	p.Backoff = time.Hour
+	p.AddDevice("down", failing, 1)
+	p.AddJob(Job{Name: "down", Device: "down", Table: modbus.TableHoldingRegisters, Quantity: 1, Interval: 10 * time.Millisecond})
+
+	results := collect(t, p, 100*time.Millisecond)["down"]
+
+	if n := failing.callCount(); n != 2 {
+		t.Errorf("expected 2 reads before backing off, got %d", n)
+	}
+	if len(results) < 3 || !errors.Is(results[len(results)-1].Err, ErrDeviceBackoff) {
+		t.Errorf("expected later polls to be skipped with ErrDeviceBackoff, got %+v", results)
+	}
+}
+
+func TestPoller_ExceptionsDoNotTriggerBackoff(t *testing.T) {
+	reader := &fakeReader{err: modbus.ErrIllegalDataAddress}
+	p := NewPoller()
+	p.MaxFailures = 1
+	p.Backoff = time.Hour
+	p.AddDevice("plc", reader, 1)
+	p.AddJob(Job{Name: "bad-address", Device: "plc", Table: modbus.TableHoldingRegisters, Quantity: 1, Interval: 10 * time.Millisecond})
+
+	collect(t, p, 55*time.Millisecond)
+
+	if n := reader.callCount(); n < 3 {
+		t.Errorf("expected the device to keep being polled, got %d reads", n)
+	}
+}
+
+func TestPoller_AddJob(t *testing.T) {
+	p := NewPoller()
+	p.AddDevice("plc", &fakeReader{}, 1)
+
+	bad := []Job{
+		{Name: "unknown device", Device: "rtu", Table: modbus.TableCoils, Quantity: 1, Interval: time.Second},
+		{Name: "no interval", Device: "plc", Table: modbus.TableCoils, Quantity: 1},
+		{Name: "too many registers", Device: "plc", Table: modbus.TableHoldingRegisters, Quantity: 126, Interval: time.Second},
+		{Name: "zero quantity", Device: "plc", Table: modbus.TableCoils, Interval: time.Second},
+	}
+	for _, job := range bad {
+		if err := p.AddJob(job); err == nil {
+			t.Errorf("%s: expected error, got nil", job.Name)
+		}
+	}
+
+	if err := p.AddJob(Job{Name: "coils", Device: "plc", Table: modbus.TableCoils, Quantity: 2000, Interval: time.Second}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
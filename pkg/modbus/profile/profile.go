@@ -0,0 +1,330 @@
+package profile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"modbus_client/pkg/modbus"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is the register map of a device model.
+type Profile struct {
+	Name string
+	Tags []modbus.Tag
+}
+
+// tagRecord is one row of a register map, as found in every format. Length,
+// if given, is the number of coils or registers the vendor lists for the
+// point and must agree with Type. Order is one of ABCD, CDAB, BADC or DCBA.
+// UnitID defaults to the profile's unit_id; one of the two must be given, as
+// unit 0 is the broadcast address on a serial line.
+type tagRecord struct {
+	Name    string   `json:"name" yaml:"name"`
+	UnitID  *int     `json:"unit_id" yaml:"unit_id"`
+	Table   string   `json:"table" yaml:"table"`
+	Address *int     `json:"address" yaml:"address"`
+	Length  int      `json:"length" yaml:"length"`
+	Type    string   `json:"type" yaml:"type"`
+	Order   string   `json:"order" yaml:"order"`
+	Scale   *float64 `json:"scale" yaml:"scale"`
+	Offset  float64  `json:"offset" yaml:"offset"`
+	Unit    string   `json:"unit" yaml:"unit"`
+}
+
+// document is the layout of JSON and YAML profiles:
+//
+//	name: Power meter
+//	unit_id: 1
+//	tags:
+//	  - {name: voltage_l1, table: input, address: 0, type: float32, order: CDAB, unit: V}
+type document struct {
+	Name   string      `json:"name" yaml:"name"`
+	UnitID *int        `json:"unit_id" yaml:"unit_id"`
+	Tags   []tagRecord `json:"tags" yaml:"tags"`
+}
+
+// Load reads a profile, choosing the format from the file extension: .csv,
+// .json, .yaml or .yml. The profile is named after the file unless the file
+// gives a name.
+func Load(path string) (*Profile, error) {
+	var parse func(io.Reader) (*Profile, error)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		parse = ParseCSV
+	case ".json":
+		parse = ParseJSON
+	case ".yaml", ".yml":
+		parse = ParseYAML
+	default:
+		return nil, fmt.Errorf("unknown profile format %q: expected .csv, .json, .yaml or .yml", filepath.Ext(path))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open profile: %w", err)
+	}
+	defer f.Close()
+
+	p, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return p, nil
+}
+
+func ParseJSON(r io.Reader) (*Profile, error) {
+	doc := &document{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON profile: %w", err)
+	}
+	return doc.profile()
+}
+
+func ParseYAML(r io.Reader) (*Profile, error) {
+	doc := &document{}
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(doc); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid YAML profile: %w", err)
+	}
+	return doc.profile()
+}
+
+// ParseCSV reads a register map with a header row. Columns are matched by
+// name, case-insensitively: name, table, address, type and unit_id are
+// required; length, order, scale, offset and unit are optional. Other columns,
+// such as a vendor's description, are ignored.
+func ParseCSV(r io.Reader) (*Profile, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "table", "address", "type", "unit_id"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header has no %q column", required)
+		}
+	}
+
+	doc := &document{}
+	var errs []error
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+		if strings.Join(row, "") == "" {
+			continue
+		}
+
+		rec, err := csvRecord(field)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+			continue
+		}
+		doc.Tags = append(doc.Tags, rec)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return doc.profile()
+}
+
+func csvRecord(field func(string) string) (tagRecord, error) {
+	rec := tagRecord{
+		Name:  field("name"),
+		Table: field("table"),
+		Type:  field("type"),
+		Order: field("order"),
+		Unit:  field("unit"),
+	}
+
+	address, err := strconv.ParseInt(field("address"), 0, 32)
+	if err != nil {
+		return rec, fmt.Errorf("invalid address %q", field("address"))
+	}
+	a := int(address)
+	rec.Address = &a
+
+	if s := field("unit_id"); s != "" {
+		unitID, err := strconv.ParseInt(s, 0, 32)
+		if err != nil {
+			return rec, fmt.Errorf("invalid unit_id %q", s)
+		}
+		u := int(unitID)
+		rec.UnitID = &u
+	}
+	if s := field("length"); s != "" {
+		if rec.Length, err = strconv.Atoi(s); err != nil {
+			return rec, fmt.Errorf("invalid length %q", s)
+		}
+	}
+	if s := field("scale"); s != "" {
+		scale, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return rec, fmt.Errorf("invalid scale %q", s)
+		}
+		rec.Scale = &scale
+	}
+	if s := field("offset"); s != "" {
+		if rec.Offset, err = strconv.ParseFloat(s, 64); err != nil {
+			return rec, fmt.Errorf("invalid offset %q", s)
+		}
+	}
+	return rec, nil
+}
+
+// profile converts and validates every record, reporting all problems at
+// once so a vendor file can be fixed in one pass.
+func (doc *document) profile() (*Profile, error) {
+	p := &Profile{Name: doc.Name}
+	var errs []error
+
+	if doc.UnitID != nil && (*doc.UnitID < 0 || *doc.UnitID > 255) {
+		errs = append(errs, fmt.Errorf("invalid unit_id %d", *doc.UnitID))
+	}
+
+	names := make(map[string]bool)
+	for i, rec := range doc.Tags {
+		tag, err := rec.tag(doc.UnitID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tag %d (%q): %w", i+1, rec.Name, err))
+			continue
+		}
+		if names[tag.Name] {
+			errs = append(errs, fmt.Errorf("tag %d: duplicate name %q", i+1, tag.Name))
+			continue
+		}
+		names[tag.Name] = true
+		p.Tags = append(p.Tags, tag)
+	}
+
+	errs = append(errs, checkOverlaps(p.Tags)...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return p, nil
+}
+
+func (rec *tagRecord) tag(defaultUnitID *int) (modbus.Tag, error) {
+	tag := modbus.Tag{Name: rec.Name, Offset: rec.Offset, Unit: rec.Unit, Scale: 1}
+	if rec.Name == "" {
+		return tag, errors.New("missing name")
+	}
+
+	unitID := defaultUnitID
+	if rec.UnitID != nil {
+		unitID = rec.UnitID
+	}
+	if unitID == nil {
+		return tag, errors.New("missing unit_id")
+	}
+	if *unitID < 0 || *unitID > 255 {
+		return tag, fmt.Errorf("invalid unit_id %d", *unitID)
+	}
+	tag.UnitID = byte(*unitID)
+
+	var err error
+	if tag.Table, err = modbus.ParseTable(rec.Table); err != nil {
+		return tag, err
+	}
+	if rec.Address == nil {
+		return tag, errors.New("missing address")
+	}
+	if *rec.Address < 0 || *rec.Address > 0xFFFF {
+		return tag, fmt.Errorf("address %d out of range", *rec.Address)
+	}
+	tag.Address = uint16(*rec.Address)
+
+	if tag.Type, err = modbus.ParseDataType(rec.Type); err != nil {
+		return tag, err
+	}
+	if rec.Length != 0 && rec.Length != int(tag.Type.Length()) {
+		return tag, fmt.Errorf("length %d does not match type %v, which takes %d", rec.Length, tag.Type, tag.Type.Length())
+	}
+	if tag.Order, err = modbus.ParseWordByteOrder(rec.Order); err != nil {
+		return tag, err
+	}
+	if rec.Scale != nil {
+		if *rec.Scale == 0 {
+			return tag, errors.New("scale must not be zero")
+		}
+		tag.Scale = *rec.Scale
+	}
+
+	return tag, tag.Validate()
+}
+
+// checkOverlaps reports tags of the same unit and table that share an
+// address.
+func checkOverlaps(tags []modbus.Tag) []error {
+	sorted := make([]*modbus.Tag, len(tags))
+	for i := range tags {
+		sorted[i] = &tags[i]
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.UnitID != b.UnitID {
+			return a.UnitID < b.UnitID
+		}
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		return a.Address < b.Address
+	})
+
+	// reach is the tag of the current unit and table that extends furthest.
+	var errs []error
+	var reach *modbus.Tag
+	for _, cur := range sorted {
+		if reach == nil || reach.UnitID != cur.UnitID || reach.Table != cur.Table {
+			reach = cur
+			continue
+		}
+		if end(reach) > int(cur.Address) {
+			errs = append(errs, fmt.Errorf("tags %q and %q overlap at %v address %d of unit %d",
+				reach.Name, cur.Name, cur.Table, cur.Address, cur.UnitID))
+		}
+		if end(cur) > end(reach) {
+			reach = cur
+		}
+	}
+	return errs
+}
+
+func end(tag *modbus.Tag) int {
+	return int(tag.Address) + int(tag.Type.Length())
+}
@@ -0,0 +1,132 @@
+package profile
+
+import (
+	"encoding/binary"
+	"modbus_client/pkg/modbus"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad_AllFormatsAgree(t *testing.T) {
+	cdab := modbus.WordByteOrder{ByteOrder: binary.BigEndian, SwapWords: true}
+	abcd := modbus.WordByteOrder{ByteOrder: binary.BigEndian}
+	expected := []modbus.Tag{
+		{Name: "voltage_l1", UnitID: 1, Table: modbus.TableInputRegisters, Address: 0, Type: modbus.TypeFloat32, Order: cdab, Scale: 1, Unit: "V"},
+		{Name: "current_l1", UnitID: 1, Table: modbus.TableInputRegisters, Address: 2, Type: modbus.TypeUint16, Order: abcd, Scale: 0.01, Unit: "A"},
+		{Name: "energy", UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 100, Type: modbus.TypeUint64, Order: abcd, Scale: 0.1, Unit: "kWh"},
+		{Name: "relay", UnitID: 1, Table: modbus.TableCoils, Address: 0, Type: modbus.TypeBool, Order: abcd, Scale: 1},
+	}
+
+	for _, path := range []string{"testdata/meter.csv", "testdata/meter.json", "testdata/meter.yaml"} {
+		p, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s) error: %v", path, err)
+		}
+		if p.Name != "meter" {
+			t.Errorf("%s: expected name \"meter\", got %q", path, p.Name)
+		}
+		if !reflect.DeepEqual(p.Tags, expected) {
+			t.Errorf("%s: unexpected tags.\nExpected: %+v\nGot:      %+v", path, expected, p.Tags)
+		}
+	}
+}
+
+func TestParse_Validation(t *testing.T) {
+	tests := map[string]struct {
+		csv  string
+		want string
+	}{
+		"unknown type": {
+			"name,unit_id,table,address,type\nx,1,holding,0,decimal\n",
+			`unknown data type "decimal"`,
+		},
+		"length mismatch": {
+			"name,unit_id,table,address,length,type\nx,1,holding,0,1,float32\n",
+			"length 1 does not match type float32",
+		},
+		"overlap": {
+			"name,unit_id,table,address,type\nwide,1,holding,0,float64\na,1,holding,1,uint16\nb,1,holding,3,int16\nok,1,input,1,uint16\n",
+			`tags "wide" and "b" overlap`,
+		},
+		"duplicate name": {
+			"name,unit_id,table,address,type\nx,1,holding,0,uint16\nx,1,holding,1,uint16\n",
+			`duplicate name "x"`,
+		},
+		"bool in register": {
+			"name,unit_id,table,address,type\nx,1,holding,0,bool\n",
+			"cannot be stored in Holding Registers",
+		},
+		"bad address": {
+			"name,unit_id,table,address,type\nx,1,holding,forty,uint16\n",
+			`line 2: invalid address "forty"`,
+		},
+		"missing unit": {
+			"name,unit_id,table,address,type\nx,,holding,0,uint16\n",
+			`tag 1 ("x"): missing unit_id`,
+		},
+		"no unit column": {
+			"name,table,address,type\nx,holding,0,uint16\n",
+			`no "unit_id" column`,
+		},
+		"missing column": {
+			"name,table,type\nx,holding,uint16\n",
+			`no "address" column`,
+		},
+	}
+
+	for name, tt := range tests {
+		_, err := ParseCSV(strings.NewReader(tt.csv))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tt.want, err)
+		}
+	}
+}
+
+func TestParse_ReportsEveryProblem(t *testing.T) {
+	yaml := `
+unit_id: 1
+tags:
+  - {name: a, table: holding, address: 0, type: float16}
+  - {name: b, table: eeprom, address: 0, type: uint16}
+  - {name: c, table: holding, type: uint16}
+`
+	_, err := ParseYAML(strings.NewReader(yaml))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	for _, want := range []string{"float16", "eeprom", "missing address"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestParse_UnitID(t *testing.T) {
+	// Unit 0 is accepted when given explicitly, and a tag's own unit wins.
+	p, err := ParseCSV(strings.NewReader("name,unit_id,table,address,type\na,0,holding,0,uint16\nb,7,holding,1,uint16\n"))
+	if err != nil {
+		t.Fatalf("ParseCSV() error: %v", err)
+	}
+	if p.Tags[0].UnitID != 0 || p.Tags[1].UnitID != 7 {
+		t.Errorf("unexpected unit IDs: %d, %d", p.Tags[0].UnitID, p.Tags[1].UnitID)
+	}
+
+	_, err = ParseJSON(strings.NewReader(`{"tags": [{"name": "a", "table": "holding", "address": 0, "type": "uint16"}]}`))
+	if err == nil || !strings.Contains(err.Error(), "missing unit_id") {
+		t.Errorf("expected missing unit_id error, got %v", err)
+	}
+}
+
+func TestParseJSON_UnknownField(t *testing.T) {
+	_, err := ParseJSON(strings.NewReader(`{"tags": [{"name": "x", "table": "holding", "address": 0, "type": "uint16", "scaling": 2}]}`))
+	if err == nil {
+		t.Error("expected error for misspelt field, got nil")
+	}
+}
+
+func TestLoad_UnknownExtension(t *testing.T) {
+	if _, err := Load("testdata/meter.xlsx"); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -1,5 +1,29 @@
 package modbus
 
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Protocol limits on the quantity of coils/registers in a single request.
+const (
+	MaxReadCoils      = 2000
+	MaxReadRegisters  = 125
+	MaxWriteCoils     = 1968
+	MaxWriteRegisters = 123
+
+	// FC 23 limits: the write block is smaller since the request also
+	// carries the read address and quantity.
+	MaxReadWriteReadRegisters  = 125
+	MaxReadWriteWriteRegisters = 121
+)
+
+// ErrAddressOverflow is wrapped by the errors of requests whose address range
+// runs past the end of the address space. A slave answers those with Illegal
+// Data Address rather than Illegal Data Value.
+var ErrAddressOverflow = errors.New("address range runs past the end of the address space")
+
 type ReadingRequest struct {
 	Header   ModbusHeader
 	Quantity uint16
@@ -16,7 +40,31 @@ type MultipleWritingRequest struct {
 	Values2Write []byte
 }
 
+// ReadWriteRequest is FC 23: it writes WriteQuantity registers from
+// WriteAddress, then reads Quantity registers from the address in Header, in
+// one transaction. The device performs the write before the read.
+type ReadWriteRequest struct {
+	Header        ModbusHeader
+	Quantity      uint16
+	WriteAddress  uint16
+	WriteQuantity uint16
+	Values2Write  []byte
+}
+
+// MaskWriteRequest is FC 22: the device sets the holding register at the
+// address in Header to (current AND AndMask) OR (OrMask AND NOT AndMask), so
+// single bits can be changed without a read-modify-write race.
+type MaskWriteRequest struct {
+	Header  ModbusHeader
+	AndMask uint16
+	OrMask  uint16
+}
+
 func (r *ReadingRequest) Build() ([]byte, error) {
+	if err := r.checkQuantity(); err != nil {
+		return nil, err
+	}
+
 	// Frame layout (Modbus PDU):
 	//   [0] SlaveID
 	//   [1] FunctionCode
@@ -57,6 +105,9 @@ func (r *SingleWritingRequest) Build() ([]byte, error) {
 }
 
 func (r *MultipleWritingRequest) Build() ([]byte, error) {
+	if err := r.check(); err != nil {
+		return nil, err
+	}
 
 	// Build the frame (Modbus PDU for multiple write):
 	// Layout:
@@ -78,3 +129,221 @@ func (r *MultipleWritingRequest) Build() ([]byte, error) {
 
 	return frame, nil
 }
+
+// ExpectedByteCount returns the number of data bytes a device must return
+// for this request: one bit per coil/input, two bytes per register.
+func (r *ReadingRequest) ExpectedByteCount() (int, error) {
+	switch r.Header.FC {
+	case FCReadCoils, FCReadInputStatus:
+		return (int(r.Quantity) + 7) / 8, nil
+	case FCReadHoldingRegisters, FCReadInputRegisters:
+		return 2 * int(r.Quantity), nil
+	default:
+		return 0, fmt.Errorf("function code 0x%02X is not a reading function", byte(r.Header.FC))
+	}
+}
+
+// Parse decodes a reading request frame, as received by a slave, and checks
+// the quantity against the protocol limits.
+func (r *ReadingRequest) Parse(frame []byte) error {
+	if len(frame) != 6 {
+		return fmt.Errorf("reading request length mismatch: expected 6 bytes, got %d", len(frame))
+	}
+
+	r.Header = ModbusHeader{
+		SlaveID:     frame[0],
+		FC:          FunctionCode(frame[1]),
+		DataAddress: [2]byte{frame[2], frame[3]},
+	}
+	r.Quantity = binary.BigEndian.Uint16(frame[4:6])
+
+	return r.checkQuantity()
+}
+
+// checkQuantity enforces the protocol limit on the number of coils or
+// registers a single request may read.
+func (r *ReadingRequest) checkQuantity() error {
+	limit := MaxReadRegisters
+	if r.Header.FC == FCReadCoils || r.Header.FC == FCReadInputStatus {
+		limit = MaxReadCoils
+	}
+	if r.Quantity < 1 || int(r.Quantity) > limit {
+		return fmt.Errorf("Quantity out of range: %d (limit %d)", r.Quantity, limit)
+	}
+	if int(r.Header.Address())+int(r.Quantity) > 0x10000 {
+		return fmt.Errorf("read of %d from address %d: %w", r.Quantity, r.Header.Address(), ErrAddressOverflow)
+	}
+	return nil
+}
+
+// Parse decodes a single writing request frame, as received by a slave.
+func (r *SingleWritingRequest) Parse(frame []byte) error {
+	if len(frame) != 6 {
+		return fmt.Errorf("single writing request length mismatch: expected 6 bytes, got %d", len(frame))
+	}
+
+	r.Header = ModbusHeader{
+		SlaveID:     frame[0],
+		FC:          FunctionCode(frame[1]),
+		DataAddress: [2]byte{frame[2], frame[3]},
+	}
+	r.Value2Write = binary.BigEndian.Uint16(frame[4:6])
+
+	if r.Header.FC == FCForceSingleCoil && r.Value2Write != 0x0000 && r.Value2Write != 0xFF00 {
+		return fmt.Errorf("invalid coil value: 0x%04X", r.Value2Write)
+	}
+
+	return nil
+}
+
+// Parse decodes a multiple writing request frame, as received by a slave,
+// and checks that quantity, byte count and payload agree.
+func (r *MultipleWritingRequest) Parse(frame []byte) error {
+	if len(frame) < 7 {
+		return fmt.Errorf("multiple writing request too short: %d bytes", len(frame))
+	}
+
+	r.Header = ModbusHeader{
+		SlaveID:     frame[0],
+		FC:          FunctionCode(frame[1]),
+		DataAddress: [2]byte{frame[2], frame[3]},
+	}
+	r.Quantity = binary.BigEndian.Uint16(frame[4:6])
+	r.Values2Write = frame[7:]
+
+	if byteCount := int(frame[6]); byteCount != len(r.Values2Write) {
+		return fmt.Errorf("ByteCount mismatch: header says %d, got %d", byteCount, len(r.Values2Write))
+	}
+
+	return r.check()
+}
+
+// check enforces the protocol limit on the number of coils or registers a
+// single request may write, and that the payload holds exactly that many.
+func (r *MultipleWritingRequest) check() error {
+	limit, expected := MaxWriteRegisters, 2*int(r.Quantity)
+	if r.Header.FC == FCForceMultipleCoils {
+		limit, expected = MaxWriteCoils, (int(r.Quantity)+7)/8
+	}
+	if r.Quantity < 1 || int(r.Quantity) > limit {
+		return fmt.Errorf("Quantity out of range: %d (limit %d)", r.Quantity, limit)
+	}
+	if len(r.Values2Write) != expected {
+		return fmt.Errorf("ByteCount mismatch: expected %d for quantity %d, got %d", expected, r.Quantity, len(r.Values2Write))
+	}
+	if int(r.Header.Address())+int(r.Quantity) > 0x10000 {
+		return fmt.Errorf("write of %d from address %d: %w", r.Quantity, r.Header.Address(), ErrAddressOverflow)
+	}
+	return nil
+}
+
+func (r *ReadWriteRequest) Build() ([]byte, error) {
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+
+	// Frame layout (Modbus PDU):
+	//   [0]    SlaveID
+	//   [1]    FunctionCode
+	//   [2-3]  Read Address (high, low)
+	//   [4-5]  Quantity to Read
+	//   [6-7]  Write Address
+	//   [8-9]  Quantity to Write
+	//   [10]   Byte Count (number of data bytes to write)
+	//   [11...] Data payload
+	frame := make([]byte, 11+len(r.Values2Write))
+	frame[0] = r.Header.SlaveID
+	frame[1] = byte(r.Header.FC)
+	frame[2] = r.Header.DataAddress[0]
+	frame[3] = r.Header.DataAddress[1]
+	binary.BigEndian.PutUint16(frame[4:6], r.Quantity)
+	binary.BigEndian.PutUint16(frame[6:8], r.WriteAddress)
+	binary.BigEndian.PutUint16(frame[8:10], r.WriteQuantity)
+	frame[10] = byte(len(r.Values2Write))
+	copy(frame[11:], r.Values2Write)
+
+	return frame, nil
+}
+
+// Parse decodes a read/write request frame, as received by a slave, and
+// checks both quantities against the protocol limits.
+func (r *ReadWriteRequest) Parse(frame []byte) error {
+	if len(frame) < 11 {
+		return fmt.Errorf("read/write request too short: %d bytes", len(frame))
+	}
+
+	r.Header = ModbusHeader{
+		SlaveID:     frame[0],
+		FC:          FunctionCode(frame[1]),
+		DataAddress: [2]byte{frame[2], frame[3]},
+	}
+	r.Quantity = binary.BigEndian.Uint16(frame[4:6])
+	r.WriteAddress = binary.BigEndian.Uint16(frame[6:8])
+	r.WriteQuantity = binary.BigEndian.Uint16(frame[8:10])
+	r.Values2Write = frame[11:]
+
+	if byteCount := int(frame[10]); byteCount != len(r.Values2Write) {
+		return fmt.Errorf("ByteCount mismatch: header says %d, got %d", byteCount, len(r.Values2Write))
+	}
+	return r.check()
+}
+
+func (r *ReadWriteRequest) check() error {
+	if r.Quantity < 1 || r.Quantity > MaxReadWriteReadRegisters {
+		return fmt.Errorf("read Quantity out of range: %d (limit %d)", r.Quantity, MaxReadWriteReadRegisters)
+	}
+	if r.WriteQuantity < 1 || r.WriteQuantity > MaxReadWriteWriteRegisters {
+		return fmt.Errorf("write Quantity out of range: %d (limit %d)", r.WriteQuantity, MaxReadWriteWriteRegisters)
+	}
+	if len(r.Values2Write) != 2*int(r.WriteQuantity) {
+		return fmt.Errorf("ByteCount mismatch: expected %d for write quantity %d, got %d", 2*int(r.WriteQuantity), r.WriteQuantity, len(r.Values2Write))
+	}
+	if int(r.Header.Address())+int(r.Quantity) > 0x10000 {
+		return fmt.Errorf("read of %d from address %d: %w", r.Quantity, r.Header.Address(), ErrAddressOverflow)
+	}
+	if int(r.WriteAddress)+int(r.WriteQuantity) > 0x10000 {
+		return fmt.Errorf("write of %d from address %d: %w", r.WriteQuantity, r.WriteAddress, ErrAddressOverflow)
+	}
+	return nil
+}
+
+func (r *MaskWriteRequest) Build() ([]byte, error) {
+	// Frame layout (Modbus PDU):
+	//   [0]   SlaveID
+	//   [1]   FunctionCode
+	//   [2-3] Reference Address (high, low)
+	//   [4-5] AND Mask
+	//   [6-7] OR Mask
+	frame := make([]byte, 8)
+	frame[0] = r.Header.SlaveID
+	frame[1] = byte(r.Header.FC)
+	frame[2] = r.Header.DataAddress[0]
+	frame[3] = r.Header.DataAddress[1]
+	binary.BigEndian.PutUint16(frame[4:6], r.AndMask)
+	binary.BigEndian.PutUint16(frame[6:8], r.OrMask)
+
+	return frame, nil
+}
+
+// Parse decodes a mask write request frame, as received by a slave.
+func (r *MaskWriteRequest) Parse(frame []byte) error {
+	if len(frame) != 8 {
+		return fmt.Errorf("mask write request length mismatch: expected 8 bytes, got %d", len(frame))
+	}
+
+	r.Header = ModbusHeader{
+		SlaveID:     frame[0],
+		FC:          FunctionCode(frame[1]),
+		DataAddress: [2]byte{frame[2], frame[3]},
+	}
+	r.AndMask = binary.BigEndian.Uint16(frame[4:6])
+	r.OrMask = binary.BigEndian.Uint16(frame[6:8])
+
+	return nil
+}
+
+// Apply returns the value the register holds after the request is applied to
+// current.
+func (r *MaskWriteRequest) Apply(current uint16) uint16 {
+	return current&r.AndMask | r.OrMask&^r.AndMask
+}
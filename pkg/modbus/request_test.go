@@ -91,3 +91,199 @@ func TestMultipleWritingRequestBuild(t *testing.T) {
 		t.Errorf("MultipleWritingRequest Build() failed.\nExpected: %v\nGot:      %v", expected, frame)
 	}
 }
+
+func TestReadingRequestParse(t *testing.T) {
+	req := &ReadingRequest{}
+	if err := req.Parse([]byte{0x01, 0x03, 0x00, 0x10, 0x00, 0x02}); err != nil {
+		t.Fatalf("ReadingRequest Parse() error: %v", err)
+	}
+	if req.Header.SlaveID != 0x01 || req.Header.FC != FCReadHoldingRegisters || req.Header.Address() != 0x10 || req.Quantity != 2 {
+		t.Errorf("unexpected ReadingRequest: %+v", req)
+	}
+
+	// 126 registers exceeds the protocol limit; 2000 coils does not.
+	if err := req.Parse([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x7E}); err == nil {
+		t.Error("expected error for 126 registers, got nil")
+	}
+	if err := req.Parse([]byte{0x01, 0x01, 0x00, 0x00, 0x07, 0xD0}); err != nil {
+		t.Errorf("unexpected error for 2000 coils: %v", err)
+	}
+	if err := req.Parse([]byte{0x01, 0x01, 0x00, 0x00, 0x00, 0x00}); err == nil {
+		t.Error("expected error for zero quantity, got nil")
+	}
+}
+
+func TestSingleWritingRequestParse(t *testing.T) {
+	req := &SingleWritingRequest{}
+	if err := req.Parse([]byte{0x01, 0x05, 0x00, 0xAC, 0xFF, 0x00}); err != nil {
+		t.Fatalf("SingleWritingRequest Parse() error: %v", err)
+	}
+	if req.Header.Address() != 0xAC || req.Value2Write != 0xFF00 {
+		t.Errorf("unexpected SingleWritingRequest: %+v", req)
+	}
+
+	if err := req.Parse([]byte{0x01, 0x05, 0x00, 0xAC, 0x12, 0x34}); err == nil {
+		t.Error("expected error for invalid coil value, got nil")
+	}
+}
+
+func TestMultipleWritingRequestParse(t *testing.T) {
+	req := &MultipleWritingRequest{}
+	frame := []byte{0x01, 0x10, 0x00, 0x30, 0x00, 0x02, 0x04, 0x0A, 0x0B, 0x0C, 0x0D}
+	if err := req.Parse(frame); err != nil {
+		t.Fatalf("MultipleWritingRequest Parse() error: %v", err)
+	}
+	if req.Quantity != 2 || !reflect.DeepEqual(req.Values2Write, []byte{0x0A, 0x0B, 0x0C, 0x0D}) {
+		t.Errorf("unexpected MultipleWritingRequest: %+v", req)
+	}
+
+	// Ten coils need two bytes, not one.
+	if err := req.Parse([]byte{0x01, 0x0F, 0x00, 0x00, 0x00, 0x0A, 0x01, 0xFF}); err == nil {
+		t.Error("expected error for short coil payload, got nil")
+	}
+	if err := req.Parse([]byte{0x01, 0x10, 0x00, 0x00, 0x00, 0x01, 0x03, 0x00, 0x01}); err == nil {
+		t.Error("expected error for byte count mismatch, got nil")
+	}
+}
+
+func TestReadingRequestBuild_QuantityLimits(t *testing.T) {
+	tests := []struct {
+		fc       FunctionCode
+		address  uint16
+		quantity uint16
+		ok       bool
+	}{
+		{FCReadHoldingRegisters, 0, 125, true},
+		{FCReadHoldingRegisters, 0, 126, false},
+		{FCReadInputRegisters, 0, 0, false},
+		{FCReadCoils, 0, 2000, true},
+		{FCReadInputStatus, 0, 2001, false},
+		{FCReadHoldingRegisters, 0xFFFF, 1, true},
+		{FCReadHoldingRegisters, 0xFFFF, 2, false},
+	}
+	for _, tt := range tests {
+		req := &ReadingRequest{Header: NewModbusHeader(tt.fc, 1, tt.address), Quantity: tt.quantity}
+		_, err := req.Build()
+		if (err == nil) != tt.ok {
+			t.Errorf("%v of %d at %d: expected ok=%v, got error %v", tt.fc, tt.quantity, tt.address, tt.ok, err)
+		}
+	}
+}
+
+func TestMultipleWritingRequestBuild_QuantityLimits(t *testing.T) {
+	tests := []struct {
+		fc       FunctionCode
+		quantity uint16
+		ok       bool
+	}{
+		{FCPresetMultipleRegisters, 0, false},
+		{FCPresetMultipleRegisters, 123, true},
+		{FCPresetMultipleRegisters, 124, false},
+		{FCForceMultipleCoils, 0, false},
+		{FCForceMultipleCoils, 1968, true},
+		{FCForceMultipleCoils, 1969, false},
+	}
+	for _, tt := range tests {
+		size := 2 * int(tt.quantity)
+		if tt.fc == FCForceMultipleCoils {
+			size = (int(tt.quantity) + 7) / 8
+		}
+		req := &MultipleWritingRequest{Header: NewModbusHeader(tt.fc, 1, 0), Quantity: tt.quantity, Values2Write: make([]byte, size)}
+		if _, err := req.Build(); (err == nil) != tt.ok {
+			t.Errorf("%v of %d: expected ok=%v, got error %v", tt.fc, tt.quantity, tt.ok, err)
+		}
+	}
+
+	short := &MultipleWritingRequest{Header: NewModbusHeader(FCPresetMultipleRegisters, 1, 0), Quantity: 2, Values2Write: []byte{0, 1}}
+	if _, err := short.Build(); err == nil {
+		t.Error("expected error for payload shorter than quantity, got nil")
+	}
+	coils := &MultipleWritingRequest{Header: NewModbusHeader(FCForceMultipleCoils, 1, 0), Quantity: 10, Values2Write: []byte{0xFF}}
+	if _, err := coils.Build(); err == nil {
+		t.Error("expected error for 10 coils in one byte, got nil")
+	}
+}
+
+func TestReadWriteRequestBuild(t *testing.T) {
+	// The example from the specification: read 6 registers from 4, write 3
+	// registers from 15.
+	req := &ReadWriteRequest{
+		Header:        NewModbusHeader(FCReadWriteMultipleRegisters, 0x01, 0x0003),
+		Quantity:      6,
+		WriteAddress:  0x000E,
+		WriteQuantity: 3,
+		Values2Write:  []byte{0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF},
+	}
+
+	expected := []byte{0x01, 0x17, 0x00, 0x03, 0x00, 0x06, 0x00, 0x0E, 0x00, 0x03, 0x06, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF}
+	frame, err := req.Build()
+	if err != nil {
+		t.Fatalf("ReadWriteRequest Build() error: %v", err)
+	}
+	if !reflect.DeepEqual(frame, expected) {
+		t.Errorf("ReadWriteRequest Build() failed.\nExpected: %v\nGot:      %v", expected, frame)
+	}
+
+	parsed := &ReadWriteRequest{}
+	if err := parsed.Parse(frame); err != nil {
+		t.Fatalf("ReadWriteRequest Parse() error: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, req) {
+		t.Errorf("ReadWriteRequest Parse() = %+v, expected %+v", parsed, req)
+	}
+}
+
+func TestReadWriteRequestBuild_QuantityLimits(t *testing.T) {
+	tests := []struct {
+		read, write uint16
+		ok          bool
+	}{
+		{125, 121, true},
+		{126, 1, false},
+		{1, 122, false},
+		{0, 1, false},
+		{1, 0, false},
+	}
+	for _, tt := range tests {
+		req := &ReadWriteRequest{
+			Header:        NewModbusHeader(FCReadWriteMultipleRegisters, 1, 0),
+			Quantity:      tt.read,
+			WriteQuantity: tt.write,
+			Values2Write:  make([]byte, 2*int(tt.write)),
+		}
+		if _, err := req.Build(); (err == nil) != tt.ok {
+			t.Errorf("read %d, write %d: expected ok=%v, got error %v", tt.read, tt.write, tt.ok, err)
+		}
+	}
+
+	short := &ReadWriteRequest{Header: NewModbusHeader(FCReadWriteMultipleRegisters, 1, 0), Quantity: 1, WriteQuantity: 2, Values2Write: []byte{0, 1}}
+	if _, err := short.Build(); err == nil {
+		t.Error("expected error for payload shorter than write quantity, got nil")
+	}
+}
+
+func TestMaskWriteRequest(t *testing.T) {
+	// The example from the specification.
+	req := &MaskWriteRequest{Header: NewModbusHeader(FCMaskWriteRegister, 0x01, 0x0004), AndMask: 0x00F2, OrMask: 0x0025}
+
+	expected := []byte{0x01, 0x16, 0x00, 0x04, 0x00, 0xF2, 0x00, 0x25}
+	frame, err := req.Build()
+	if err != nil {
+		t.Fatalf("MaskWriteRequest Build() error: %v", err)
+	}
+	if !reflect.DeepEqual(frame, expected) {
+		t.Errorf("MaskWriteRequest Build() failed.\nExpected: %v\nGot:      %v", expected, frame)
+	}
+
+	parsed := &MaskWriteRequest{}
+	if err := parsed.Parse(frame); err != nil || !reflect.DeepEqual(parsed, req) {
+		t.Errorf("MaskWriteRequest Parse() = %+v, %v", parsed, err)
+	}
+	if err := parsed.Parse(frame[:6]); err == nil {
+		t.Error("expected error for short frame, got nil")
+	}
+
+	if v := req.Apply(0x0012); v != 0x0017 {
+		t.Errorf("Apply(0x0012) = 0x%04X, expected 0x0017", v)
+	}
+}
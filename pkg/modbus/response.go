@@ -1,6 +1,7 @@
 package modbus
 
 import (
+	"encoding/binary"
 	"fmt"
 )
 
@@ -10,6 +11,21 @@ type ReadingResponse struct {
 	Response  []byte
 }
 
+// ReadWriteResponse is the reply to a ReadWriteRequest: the registers read,
+// laid out as in a ReadingResponse.
+type ReadWriteResponse struct {
+	Header    ModbusHeader
+	ByteCount uint16
+	Response  []byte
+}
+
+// MaskWriteResponse is the reply to a MaskWriteRequest, which echoes it.
+type MaskWriteResponse struct {
+	Header  ModbusHeader
+	AndMask uint16
+	OrMask  uint16
+}
+
 type SingleWritingResponse struct {
 	Header       ModbusHeader
 	ValueWritten []byte
@@ -84,3 +100,176 @@ func (r *MultipleWritingResponse) Build() ([]byte, error) {
 
 	return frame, nil
 }
+
+// checkResponseHeader verifies that a reply frame comes from the unit that was
+// addressed and echoes the function code of the request. Exception replies are
+// returned as *ModbusException.
+func checkResponseHeader(frame []byte, req ModbusHeader) error {
+	if len(frame) < 2 {
+		return fmt.Errorf("response frame too short: %d bytes", len(frame))
+	}
+	if frame[0] != req.SlaveID {
+		return fmt.Errorf("SlaveID mismatch: expected 0x%02X, got 0x%02X", req.SlaveID, frame[0])
+	}
+	if FunctionCode(frame[1]) == req.FC|0x80 {
+		return CheckException(frame)
+	}
+	if FunctionCode(frame[1]) != req.FC {
+		return fmt.Errorf("FunctionCode mismatch: expected 0x%02X, got 0x%02X", byte(req.FC), frame[1])
+	}
+	return nil
+}
+
+func (r *ReadingResponse) Parse(frame []byte, req *ReadingRequest) error {
+	if err := checkResponseHeader(frame, req.Header); err != nil {
+		return err
+	}
+
+	// Frame layout
+	// [0] SlaveID
+	// [1] FunctionCode
+	// [2] Qty of data bytes to follow
+	// [n] values
+	if len(frame) < 3 {
+		return fmt.Errorf("response frame too short: %d bytes", len(frame))
+	}
+
+	byteCount := int(frame[2])
+	if byteCount != len(frame)-3 {
+		return fmt.Errorf("ByteCount mismatch: header says %d, got %d", byteCount, len(frame)-3)
+	}
+
+	expected, err := req.ExpectedByteCount()
+	if err != nil {
+		return err
+	}
+	if byteCount != expected {
+		return fmt.Errorf("ByteCount mismatch: expected %d for quantity %d, got %d", expected, req.Quantity, byteCount)
+	}
+
+	r.Header = req.Header
+	r.ByteCount = uint16(byteCount)
+	r.Response = frame[3:]
+
+	return nil
+}
+
+func (r *SingleWritingResponse) Parse(frame []byte, req *SingleWritingRequest) error {
+	if err := checkResponseHeader(frame, req.Header); err != nil {
+		return err
+	}
+
+	// Frame layout
+	// [0] SlaveID
+	// [1] FunctionCode
+	// [2-3] DataAddress
+	// [4-5] written value
+	if len(frame) != 6 {
+		return fmt.Errorf("response frame length mismatch: expected 6 bytes, got %d", len(frame))
+	}
+	if frame[2] != req.Header.DataAddress[0] || frame[3] != req.Header.DataAddress[1] {
+		return fmt.Errorf("DataAddress mismatch: expected %d, got %d", req.Header.Address(), binary.BigEndian.Uint16(frame[2:4]))
+	}
+	if value := binary.BigEndian.Uint16(frame[4:6]); value != req.Value2Write {
+		return fmt.Errorf("written value mismatch: expected 0x%04X, got 0x%04X", req.Value2Write, value)
+	}
+
+	r.Header = req.Header
+	r.ValueWritten = frame[4:6]
+
+	return nil
+}
+
+func (r *MultipleWritingResponse) Parse(frame []byte, req *MultipleWritingRequest) error {
+	if err := checkResponseHeader(frame, req.Header); err != nil {
+		return err
+	}
+
+	// Frame layout
+	// [0] SlaveID
+	// [1] FunctionCode
+	// [2-3] DataAddress
+	// [4-5] qty of written coil/reg
+	if len(frame) != 6 {
+		return fmt.Errorf("response frame length mismatch: expected 6 bytes, got %d", len(frame))
+	}
+	if frame[2] != req.Header.DataAddress[0] || frame[3] != req.Header.DataAddress[1] {
+		return fmt.Errorf("DataAddress mismatch: expected %d, got %d", req.Header.Address(), binary.BigEndian.Uint16(frame[2:4]))
+	}
+	if quantity := binary.BigEndian.Uint16(frame[4:6]); quantity != req.Quantity {
+		return fmt.Errorf("Quantity mismatch: expected %d, got %d", req.Quantity, quantity)
+	}
+
+	r.Header = req.Header
+	r.QuantityWritten = frame[4:6]
+
+	return nil
+}
+
+func (r *ReadWriteResponse) Build() ([]byte, error) {
+	resp := &ReadingResponse{Header: r.Header, ByteCount: r.ByteCount, Response: r.Response}
+	return resp.Build()
+}
+
+func (r *ReadWriteResponse) Parse(frame []byte, req *ReadWriteRequest) error {
+	if err := checkResponseHeader(frame, req.Header); err != nil {
+		return err
+	}
+
+	// Frame layout
+	// [0] SlaveID
+	// [1] FunctionCode
+	// [2] Qty of data bytes to follow
+	// [n] registers read
+	if len(frame) < 3 {
+		return fmt.Errorf("response frame too short: %d bytes", len(frame))
+	}
+
+	byteCount := int(frame[2])
+	if byteCount != len(frame)-3 {
+		return fmt.Errorf("ByteCount mismatch: header says %d, got %d", byteCount, len(frame)-3)
+	}
+	if expected := 2 * int(req.Quantity); byteCount != expected {
+		return fmt.Errorf("ByteCount mismatch: expected %d for quantity %d, got %d", expected, req.Quantity, byteCount)
+	}
+
+	r.Header = req.Header
+	r.ByteCount = uint16(byteCount)
+	r.Response = frame[3:]
+
+	return nil
+}
+
+func (r *MaskWriteResponse) Build() ([]byte, error) {
+	req := &MaskWriteRequest{Header: r.Header, AndMask: r.AndMask, OrMask: r.OrMask}
+	return req.Build()
+}
+
+func (r *MaskWriteResponse) Parse(frame []byte, req *MaskWriteRequest) error {
+	if err := checkResponseHeader(frame, req.Header); err != nil {
+		return err
+	}
+
+	// Frame layout
+	// [0] SlaveID
+	// [1] FunctionCode
+	// [2-3] DataAddress
+	// [4-5] AND Mask
+	// [6-7] OR Mask
+	if len(frame) != 8 {
+		return fmt.Errorf("response frame length mismatch: expected 8 bytes, got %d", len(frame))
+	}
+	if frame[2] != req.Header.DataAddress[0] || frame[3] != req.Header.DataAddress[1] {
+		return fmt.Errorf("DataAddress mismatch: expected %d, got %d", req.Header.Address(), binary.BigEndian.Uint16(frame[2:4]))
+	}
+	andMask, orMask := binary.BigEndian.Uint16(frame[4:6]), binary.BigEndian.Uint16(frame[6:8])
+	if andMask != req.AndMask || orMask != req.OrMask {
+		return fmt.Errorf("mask mismatch: expected AND 0x%04X OR 0x%04X, got AND 0x%04X OR 0x%04X", req.AndMask, req.OrMask, andMask, orMask)
+	}
+
+	r.Header = req.Header
+	r.AndMask = andMask
+	r.OrMask = orMask
+
+	return nil
+}
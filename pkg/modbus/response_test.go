@@ -1,6 +1,7 @@
 package modbus
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -107,3 +108,141 @@ func TestMultipleWritingResponseBuild(t *testing.T) {
 		t.Errorf("MultipleWritingResponse Build() failed.\nExpected: %v\nGot:      %v", expected, frame)
 	}
 }
+
+func TestReadingResponseParse(t *testing.T) {
+	req := &ReadingRequest{
+		Header:   NewModbusHeader(FCReadHoldingRegisters, 0x01, 0x0010),
+		Quantity: 2,
+	}
+
+	rr := &ReadingResponse{}
+	err := rr.Parse([]byte{0x01, 0x03, 0x04, 0x12, 0x34, 0x56, 0x78}, req)
+	if err != nil {
+		t.Fatalf("ReadingResponse Parse() returned error: %v", err)
+	}
+	if rr.ByteCount != 4 {
+		t.Errorf("expected ByteCount 4, got %d", rr.ByteCount)
+	}
+	if !reflect.DeepEqual(rr.Response, []byte{0x12, 0x34, 0x56, 0x78}) {
+		t.Errorf("unexpected Response payload: %v", rr.Response)
+	}
+	if rr.Header != req.Header {
+		t.Errorf("expected Header %v, got %v", req.Header, rr.Header)
+	}
+}
+
+func TestReadingResponseParse_Mismatch(t *testing.T) {
+	req := &ReadingRequest{
+		Header:   NewModbusHeader(FCReadCoils, 0x01, 0x0000),
+		Quantity: 10,
+	}
+
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{name: "short frame", frame: []byte{0x01}},
+		{name: "wrong slave", frame: []byte{0x02, 0x01, 0x02, 0xFF, 0x03}},
+		{name: "wrong function code", frame: []byte{0x01, 0x02, 0x02, 0xFF, 0x03}},
+		{name: "byte count disagrees with payload", frame: []byte{0x01, 0x01, 0x03, 0xFF, 0x03}},
+		{name: "byte count disagrees with quantity", frame: []byte{0x01, 0x01, 0x01, 0xFF}},
+	}
+
+	for _, tc := range tests {
+		rr := &ReadingResponse{}
+		if err := rr.Parse(tc.frame, req); err == nil {
+			t.Errorf("%s: expected error, got nil", tc.name)
+		}
+	}
+}
+
+func TestSingleWritingResponseParse(t *testing.T) {
+	req := &SingleWritingRequest{
+		Header:      NewModbusHeader(FCPresetSingleRegister, 0x02, 0x0020),
+		Value2Write: 0xABCD,
+	}
+
+	swr := &SingleWritingResponse{}
+	if err := swr.Parse([]byte{0x02, 0x06, 0x00, 0x20, 0xAB, 0xCD}, req); err != nil {
+		t.Fatalf("SingleWritingResponse Parse() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(swr.ValueWritten, []byte{0xAB, 0xCD}) {
+		t.Errorf("unexpected ValueWritten: %v", swr.ValueWritten)
+	}
+
+	if err := swr.Parse([]byte{0x02, 0x06, 0x00, 0x21, 0xAB, 0xCD}, req); err == nil {
+		t.Error("expected error for address mismatch, got nil")
+	}
+	if err := swr.Parse([]byte{0x02, 0x06, 0x00, 0x20, 0xAB, 0xCE}, req); err == nil {
+		t.Error("expected error for value mismatch, got nil")
+	}
+}
+
+func TestMultipleWritingResponseParse(t *testing.T) {
+	req := &MultipleWritingRequest{
+		Header:       NewModbusHeader(FCPresetMultipleRegisters, 0x03, 0x0040),
+		Quantity:     2,
+		Values2Write: []byte{0x00, 0x01, 0x00, 0x02},
+	}
+
+	mwr := &MultipleWritingResponse{}
+	if err := mwr.Parse([]byte{0x03, 0x10, 0x00, 0x40, 0x00, 0x02}, req); err != nil {
+		t.Fatalf("MultipleWritingResponse Parse() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(mwr.QuantityWritten, []byte{0x00, 0x02}) {
+		t.Errorf("unexpected QuantityWritten: %v", mwr.QuantityWritten)
+	}
+
+	if err := mwr.Parse([]byte{0x03, 0x10, 0x00, 0x40, 0x00, 0x03}, req); err == nil {
+		t.Error("expected error for quantity mismatch, got nil")
+	}
+}
+
+func TestReadWriteResponseParse(t *testing.T) {
+	req := &ReadWriteRequest{
+		Header:        NewModbusHeader(FCReadWriteMultipleRegisters, 0x01, 0x0003),
+		Quantity:      2,
+		WriteAddress:  0x000E,
+		WriteQuantity: 1,
+		Values2Write:  []byte{0x00, 0xFF},
+	}
+
+	rr := &ReadWriteResponse{}
+	if err := rr.Parse([]byte{0x01, 0x17, 0x04, 0x00, 0xFE, 0x0A, 0xCD}, req); err != nil {
+		t.Fatalf("ReadWriteResponse Parse() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(rr.Response, []byte{0x00, 0xFE, 0x0A, 0xCD}) {
+		t.Errorf("unexpected Response payload: %v", rr.Response)
+	}
+
+	frame, err := rr.Build()
+	if err != nil || !reflect.DeepEqual(frame, []byte{0x01, 0x17, 0x04, 0x00, 0xFE, 0x0A, 0xCD}) {
+		t.Errorf("ReadWriteResponse Build() = %v, %v", frame, err)
+	}
+
+	if err := rr.Parse([]byte{0x01, 0x17, 0x02, 0x00, 0xFE}, req); err == nil {
+		t.Error("expected error for short read, got nil")
+	}
+	if err := rr.Parse([]byte{0x01, 0x97, 0x02}, req); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Errorf("expected ErrIllegalDataAddress, got %v", err)
+	}
+}
+
+func TestMaskWriteResponseParse(t *testing.T) {
+	req := &MaskWriteRequest{Header: NewModbusHeader(FCMaskWriteRegister, 0x01, 0x0004), AndMask: 0x00F2, OrMask: 0x0025}
+
+	rr := &MaskWriteResponse{}
+	if err := rr.Parse([]byte{0x01, 0x16, 0x00, 0x04, 0x00, 0xF2, 0x00, 0x25}, req); err != nil {
+		t.Fatalf("MaskWriteResponse Parse() returned error: %v", err)
+	}
+	if rr.AndMask != 0x00F2 || rr.OrMask != 0x0025 {
+		t.Errorf("unexpected masks: %+v", rr)
+	}
+
+	if err := rr.Parse([]byte{0x01, 0x16, 0x00, 0x04, 0x00, 0xF2, 0x00, 0x24}, req); err == nil {
+		t.Error("expected error for mask mismatch, got nil")
+	}
+	if err := rr.Parse([]byte{0x01, 0x96, 0x01}, req); !errors.Is(err, ErrIllegalFunction) {
+		t.Errorf("expected ErrIllegalFunction, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+type RTURequestWrapper struct {
+	ModbusFrame []byte
+	CRC         uint16
+}
+
+type RTUResponseWrapper struct {
+	ModbusFrame []byte
+	CRC         uint16
+}
+
+// CRC16 computes the Modbus RTU CRC (polynomial 0xA001, initial value 0xFFFF).
+func CRC16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&0x0001 != 0 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+func (wrap *RTURequestWrapper) Build() ([]byte, error) {
+	// modbus rtu frame:
+	// modbus frame (slave ID onwards)
+	// CRC low
+	// CRC high
+	wrap.CRC = CRC16(wrap.ModbusFrame)
+
+	frame := make([]byte, len(wrap.ModbusFrame)+2)
+	copy(frame, wrap.ModbusFrame)
+	binary.LittleEndian.PutUint16(frame[len(wrap.ModbusFrame):], wrap.CRC)
+
+	return frame, nil
+}
+
+// Parse checks the CRC of a Modbus RTU frame and strips it off.
+func (wrap *RTUResponseWrapper) Parse(adu []byte) error {
+	if len(adu) < 4 {
+		return fmt.Errorf("Modbus RTU frame too short: expected at least 4 bytes, got %d", len(adu))
+	}
+
+	n := len(adu) - 2
+	wrap.CRC = binary.LittleEndian.Uint16(adu[n:])
+	if crc := CRC16(adu[:n]); crc != wrap.CRC {
+		return fmt.Errorf("CRC mismatch: computed 0x%04X, frame carries 0x%04X", crc, wrap.CRC)
+	}
+
+	wrap.ModbusFrame = adu[:n]
+	return nil
+}
+
+// RTUResponseLength returns the total length, CRC included, of an RTU reply
+// given its first three bytes (slave ID, function code and the byte after it).
+// RTU has no length field, so the size is derived from the function code and,
+// for reads, the byte count.
+func RTUResponseLength(head []byte) (int, error) {
+	if len(head) < 3 {
+		return 0, fmt.Errorf("need 3 bytes to size an RTU frame, got %d", len(head))
+	}
+
+	fc := FunctionCode(head[1])
+	if fc&0x80 != 0 {
+		// slave ID, function code, exception code, CRC
+		return 5, nil
+	}
+
+	switch fc {
+	case FCReadCoils, FCReadInputStatus, FCReadHoldingRegisters, FCReadInputRegisters, FCReadWriteMultipleRegisters,
+		FCGetCommEventLog, FCReportSlaveID, FCReadFileRecord, FCWriteFileRecord:
+		return 3 + int(head[2]) + 2, nil
+	case FCReadExceptionStatus:
+		return 3 + 2, nil
+	case FCForceSingleCoil, FCPresetSingleRegister, FCForceMultipleCoils, FCPresetMultipleRegisters, FCGetCommEventCounter:
+		return 6 + 2, nil
+	case FCDiagnostics:
+		// Sub-function and one register of data. Return Query Data may echo
+		// more, which only the request tells.
+		return 6 + 2, nil
+	case FCMaskWriteRegister:
+		return 8 + 2, nil
+	default:
+		return 0, fmt.Errorf("cannot determine RTU frame length for function code 0x%02X", byte(fc))
+	}
+}
+
+// RTUResponseRemaining returns how many more bytes, CRC included, are needed
+// to complete the RTU reply that starts with head (at least 3 bytes). Unlike
+// RTUResponseLength it also sizes Read Device Identification replies, whose
+// length is only known once their object list has been walked: the caller
+// reads what is asked for and calls again until 0 is returned.
+func RTUResponseRemaining(head []byte) (int, error) {
+	if len(head) >= 3 && FunctionCode(head[1]) == FCEncapsulatedInterface && head[2] == MEIReadDeviceIdentification {
+		// The CRC follows every prefix asked for, so asking for it early
+		// never reads past the end of the frame.
+		return deviceIDResponseLength(head) + 2 - len(head), nil
+	}
+
+	length, err := RTUResponseLength(head)
+	if err != nil {
+		return 0, err
+	}
+	return length - len(head), nil
+}
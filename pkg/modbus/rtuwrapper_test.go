@@ -0,0 +1,86 @@
+package modbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCRC16(t *testing.T) {
+	// Read Holding Registers, slave 1, address 0, quantity 10.
+	frame := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A}
+	if crc := CRC16(frame); crc != 0xCDC5 {
+		t.Errorf("Expected CRC 0xCDC5, got 0x%04X", crc)
+	}
+}
+
+func TestRTURequestWrapper_Build(t *testing.T) {
+	wrap := &RTURequestWrapper{
+		ModbusFrame: []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A},
+	}
+
+	frame, err := wrap.Build()
+	if err != nil {
+		t.Fatalf("Build() returned error: %v", err)
+	}
+
+	// The CRC is appended low byte first.
+	expected := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A, 0xC5, 0xCD}
+	if !reflect.DeepEqual(frame, expected) {
+		t.Errorf("Expected frame %v, got %v", expected, frame)
+	}
+}
+
+func TestRTUResponseWrapper_Parse(t *testing.T) {
+	adu := []byte{0x01, 0x03, 0x02, 0x00, 0x2A}
+	adu = append(adu, byte(CRC16(adu)), byte(CRC16(adu)>>8))
+
+	wrap := &RTUResponseWrapper{}
+	if err := wrap.Parse(adu); err != nil {
+		t.Fatalf("Parse() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(wrap.ModbusFrame, adu[:5]) {
+		t.Errorf("Expected frame %v, got %v", adu[:5], wrap.ModbusFrame)
+	}
+
+	adu[3] ^= 0xFF
+	if err := wrap.Parse(adu); err == nil {
+		t.Error("expected CRC error for corrupted frame, got nil")
+	}
+}
+
+func TestRTUResponseLength(t *testing.T) {
+	tests := []struct {
+		head     []byte
+		expected int
+	}{
+		{head: []byte{0x01, 0x03, 0x04}, expected: 9},
+		{head: []byte{0x01, 0x01, 0x01}, expected: 6},
+		{head: []byte{0x01, 0x06, 0x00}, expected: 8},
+		{head: []byte{0x01, 0x10, 0x00}, expected: 8},
+		{head: []byte{0x01, 0x07, 0x6D}, expected: 5},
+		{head: []byte{0x01, 0x08, 0x00}, expected: 8},
+		{head: []byte{0x01, 0x0B, 0x00}, expected: 8},
+		{head: []byte{0x01, 0x0C, 0x08}, expected: 13},
+		{head: []byte{0x01, 0x11, 0x05}, expected: 10},
+		{head: []byte{0x01, 0x14, 0x0C}, expected: 17},
+		{head: []byte{0x01, 0x15, 0x0D}, expected: 18},
+		{head: []byte{0x01, 0x16, 0x00}, expected: 10},
+		{head: []byte{0x01, 0x17, 0x0C}, expected: 17},
+		{head: []byte{0x01, 0x83, 0x02}, expected: 5},
+	}
+
+	for _, tc := range tests {
+		n, err := RTUResponseLength(tc.head)
+		if err != nil {
+			t.Errorf("RTUResponseLength(%v) error: %v", tc.head, err)
+			continue
+		}
+		if n != tc.expected {
+			t.Errorf("RTUResponseLength(%v): expected %d, got %d", tc.head, tc.expected, n)
+		}
+	}
+
+	if _, err := RTUResponseLength([]byte{0x01, 0x63, 0x00}); err == nil {
+		t.Error("expected error for unknown function code, got nil")
+	}
+}
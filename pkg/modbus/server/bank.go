@@ -0,0 +1,316 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"modbus_client/pkg/modbus"
+	"sort"
+	"sync"
+)
+
+// Change describes a write made by a Modbus master. Values holds the new
+// contents of Quantity consecutive coils (0 or 1) or registers starting at
+// Address.
+type Change struct {
+	UnitID  byte
+	Table   modbus.Table
+	Address uint16
+	Values  []uint16
+}
+
+// ChangeHook is called after a master has written to the bank. Hooks run on
+// the connection's goroutine, outside the bank lock, so they may read or set
+// other values.
+type ChangeHook func(Change)
+
+// block is a contiguous range of addresses in one table.
+type block struct {
+	start  uint16
+	values []uint16
+}
+
+func (b *block) contains(address uint16) bool {
+	return address >= b.start && int(address-b.start) < len(b.values)
+}
+
+// RegisterBank is a thread-safe in-memory store for the four Modbus tables of
+// any number of unit IDs. Only the address ranges added with AddRange exist;
+// any access outside them fails with modbus.ErrIllegalDataAddress. Coils and
+// discrete inputs are stored as 0 or 1.
+type RegisterBank struct {
+	mu     sync.RWMutex
+	tables map[byte]*[4][]*block
+	hooks  []ChangeHook
+}
+
+func NewRegisterBank() *RegisterBank {
+	return &RegisterBank{
+		tables: make(map[byte]*[4][]*block),
+	}
+}
+
+// AddRange makes count addresses starting at start available in table for
+// unitID, initialised to zero. Ranges of the same table may not overlap.
+func (b *RegisterBank) AddRange(unitID byte, table modbus.Table, start, count uint16) error {
+	if table > modbus.TableInputRegisters {
+		return fmt.Errorf("unknown Modbus table: %d", table)
+	}
+	if count == 0 || int(start)+int(count) > 0x10000 {
+		return fmt.Errorf("invalid address range: start %d, count %d", start, count)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	unit, ok := b.tables[unitID]
+	if !ok {
+		unit = &[4][]*block{}
+		b.tables[unitID] = unit
+	}
+
+	end := int(start) + int(count)
+	for _, existing := range unit[table] {
+		if int(start) < int(existing.start)+len(existing.values) && end > int(existing.start) {
+			return fmt.Errorf("range %d-%d overlaps existing %v range of unit %d starting at %d",
+				start, end-1, table, unitID, existing.start)
+		}
+	}
+
+	blocks := append(unit[table], &block{start: start, values: make([]uint16, count)})
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].start < blocks[j].start })
+	unit[table] = blocks
+	return nil
+}
+
+// OnChange registers hook to be called after every write by a master.
+func (b *RegisterBank) OnChange(hook ChangeHook) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hooks = append(b.hooks, hook)
+}
+
+// Get returns quantity values of table starting at address.
+func (b *RegisterBank) Get(unitID byte, table modbus.Table, address, quantity uint16) ([]uint16, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	values := make([]uint16, quantity)
+	err := b.each(unitID, table, address, quantity, func(i int, blk *block, offset int) {
+		values[i] = blk.values[offset]
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Set stores values in table starting at address. It is meant for the
+// application side of the device (sensors, simulators) and does not fire
+// change hooks; any table may be set, including the read-only ones.
+func (b *RegisterBank) Set(unitID byte, table modbus.Table, address uint16, values ...uint16) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.set(unitID, table, address, values)
+}
+
+func (b *RegisterBank) set(unitID byte, table modbus.Table, address uint16, values []uint16) error {
+	if table.IsBit() {
+		for _, v := range values {
+			if v > 1 {
+				return fmt.Errorf("invalid %v value: %d", table, v)
+			}
+		}
+	}
+
+	// Check the whole range before writing so a failed write changes nothing.
+	if err := b.each(unitID, table, address, uint16(len(values)), func(int, *block, int) {}); err != nil {
+		return err
+	}
+	return b.each(unitID, table, address, uint16(len(values)), func(i int, blk *block, offset int) {
+		blk.values[offset] = values[i]
+	})
+}
+
+// each calls fn for every address in [address, address+quantity), failing
+// with modbus.ErrIllegalDataAddress if any of them does not exist.
+func (b *RegisterBank) each(unitID byte, table modbus.Table, address, quantity uint16, fn func(i int, blk *block, offset int)) error {
+	unit, ok := b.tables[unitID]
+	if !ok || table > modbus.TableInputRegisters || int(address)+int(quantity) > 0x10000 {
+		return modbus.ErrIllegalDataAddress
+	}
+
+	var blk *block
+	for i := 0; i < int(quantity); i++ {
+		a := address + uint16(i)
+		if blk == nil || !blk.contains(a) {
+			blk = findBlock(unit[table], a)
+			if blk == nil {
+				return modbus.ErrIllegalDataAddress
+			}
+		}
+		fn(i, blk, int(a-blk.start))
+	}
+	return nil
+}
+
+func findBlock(blocks []*block, address uint16) *block {
+	i := sort.Search(len(blocks), func(i int) bool {
+		return int(blocks[i].start)+len(blocks[i].values) > int(address)
+	})
+	if i < len(blocks) && blocks[i].contains(address) {
+		return blocks[i]
+	}
+	return nil
+}
+
+// write stores values written by a master and fires the change hooks.
+func (b *RegisterBank) write(unitID byte, table modbus.Table, address uint16, values []uint16) error {
+	b.mu.Lock()
+	err := b.set(unitID, table, address, values)
+	hooks := b.hooks
+	b.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	b.notify(hooks, Change{UnitID: unitID, Table: table, Address: address, Values: values})
+	return nil
+}
+
+// notify calls hooks with a change made by a master. The caller must not hold
+// the bank lock.
+func (b *RegisterBank) notify(hooks []ChangeHook, change Change) {
+	for _, hook := range hooks {
+		hook(change)
+	}
+}
+
+// Register installs the bank as the handler of every data access function
+// code on s.
+func (b *RegisterBank) Register(s *Server) {
+	for _, fc := range []modbus.FunctionCode{
+		modbus.FCReadCoils, modbus.FCReadInputStatus,
+		modbus.FCReadHoldingRegisters, modbus.FCReadInputRegisters,
+	} {
+		s.HandleRead(fc, b)
+	}
+	s.HandleSingleWrite(modbus.FCForceSingleCoil, b)
+	s.HandleSingleWrite(modbus.FCPresetSingleRegister, b)
+	s.HandleMultipleWrite(modbus.FCForceMultipleCoils, b)
+	s.HandleMultipleWrite(modbus.FCPresetMultipleRegisters, b)
+	s.HandleMaskWrite(modbus.FCMaskWriteRegister, b)
+	s.HandleReadWrite(modbus.FCReadWriteMultipleRegisters, b)
+}
+
+func (b *RegisterBank) HandleRead(req *modbus.ReadingRequest) ([]byte, error) {
+	table, err := modbus.TableOf(req.Header.FC)
+	if err != nil {
+		return nil, modbus.ErrIllegalFunction
+	}
+
+	values, err := b.Get(req.Header.SlaveID, table, req.Header.Address(), req.Quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	if table.IsBit() {
+		bits := make([]bool, len(values))
+		for i, v := range values {
+			bits[i] = v != 0
+		}
+		return modbus.BoolsToCoilBytes(bits), nil
+	}
+	return modbus.Uint16sToBytes(values, binary.BigEndian), nil
+}
+
+func (b *RegisterBank) HandleSingleWrite(req *modbus.SingleWritingRequest) error {
+	table, err := modbus.TableOf(req.Header.FC)
+	if err != nil {
+		return modbus.ErrIllegalFunction
+	}
+
+	value := req.Value2Write
+	if table.IsBit() && value == 0xFF00 {
+		value = 1
+	}
+	return b.write(req.Header.SlaveID, table, req.Header.Address(), []uint16{value})
+}
+
+func (b *RegisterBank) HandleMultipleWrite(req *modbus.MultipleWritingRequest) error {
+	table, err := modbus.TableOf(req.Header.FC)
+	if err != nil {
+		return modbus.ErrIllegalFunction
+	}
+
+	values := make([]uint16, req.Quantity)
+	if table.IsBit() {
+		bits, err := modbus.CoilBytesToBools(req.Values2Write, int(req.Quantity))
+		if err != nil {
+			return modbus.ErrIllegalDataValue
+		}
+		for i, bit := range bits {
+			if bit {
+				values[i] = 1
+			}
+		}
+	} else {
+		values, err = modbus.BytesToUint16s(req.Values2Write, binary.BigEndian)
+		if err != nil {
+			return modbus.ErrIllegalDataValue
+		}
+	}
+	return b.write(req.Header.SlaveID, table, req.Header.Address(), values)
+}
+
+// HandleMaskWrite updates a holding register under the bank lock, so no other
+// write can slip in between reading and storing it.
+func (b *RegisterBank) HandleMaskWrite(req *modbus.MaskWriteRequest) error {
+	unitID, table, address := req.Header.SlaveID, modbus.TableHoldingRegisters, req.Header.Address()
+
+	b.mu.Lock()
+	var value uint16
+	err := b.each(unitID, table, address, 1, func(_ int, blk *block, offset int) {
+		value = req.Apply(blk.values[offset])
+		blk.values[offset] = value
+	})
+	hooks := b.hooks
+	b.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	b.notify(hooks, Change{UnitID: unitID, Table: table, Address: address, Values: []uint16{value}})
+	return nil
+}
+
+// HandleReadWrite writes and then reads holding registers under one lock, so
+// no other request sees the registers between the two.
+func (b *RegisterBank) HandleReadWrite(req *modbus.ReadWriteRequest) ([]byte, error) {
+	unitID, table := req.Header.SlaveID, modbus.TableHoldingRegisters
+	written, err := modbus.BytesToUint16s(req.Values2Write, binary.BigEndian)
+	if err != nil {
+		return nil, modbus.ErrIllegalDataValue
+	}
+
+	b.mu.Lock()
+	values := make([]uint16, req.Quantity)
+	// Check the read range first so a failed request changes nothing.
+	err = b.each(unitID, table, req.Header.Address(), req.Quantity, func(int, *block, int) {})
+	if err == nil {
+		err = b.set(unitID, table, req.WriteAddress, written)
+	}
+	if err == nil {
+		err = b.each(unitID, table, req.Header.Address(), req.Quantity, func(i int, blk *block, offset int) {
+			values[i] = blk.values[offset]
+		})
+	}
+	hooks := b.hooks
+	b.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	b.notify(hooks, Change{UnitID: unitID, Table: table, Address: req.WriteAddress, Values: written})
+	return modbus.Uint16sToBytes(values, binary.BigEndian), nil
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"errors"
+	"modbus_client/pkg/modbus"
+	"reflect"
+	"testing"
+)
+
+func TestRegisterBank_AddRange(t *testing.T) {
+	b := NewRegisterBank()
+	if err := b.AddRange(1, modbus.TableHoldingRegisters, 100, 10); err != nil {
+		t.Fatalf("AddRange() error: %v", err)
+	}
+	if err := b.AddRange(1, modbus.TableHoldingRegisters, 105, 10); err == nil {
+		t.Error("expected error for overlapping range, got nil")
+	}
+	if err := b.AddRange(1, modbus.TableHoldingRegisters, 110, 10); err != nil {
+		t.Errorf("unexpected error for adjacent range: %v", err)
+	}
+	if err := b.AddRange(1, modbus.TableInputRegisters, 100, 10); err != nil {
+		t.Errorf("unexpected error for same range in another table: %v", err)
+	}
+	if err := b.AddRange(1, modbus.TableCoils, 0xFFFF, 2); err == nil {
+		t.Error("expected error for range past the end of the address space, got nil")
+	}
+}
+
+func TestRegisterBank_GetSet(t *testing.T) {
+	b := NewRegisterBank()
+	b.AddRange(1, modbus.TableHoldingRegisters, 0, 4)
+	b.AddRange(1, modbus.TableHoldingRegisters, 4, 4)
+	b.AddRange(1, modbus.TableHoldingRegisters, 20, 4)
+
+	// Adjacent ranges read as one.
+	if err := b.Set(1, modbus.TableHoldingRegisters, 2, 0x0A, 0x0B, 0x0C, 0x0D); err != nil {
+		t.Fatalf("Set() error: %v", err)
+	}
+	values, err := b.Get(1, modbus.TableHoldingRegisters, 2, 4)
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	if !reflect.DeepEqual(values, []uint16{0x0A, 0x0B, 0x0C, 0x0D}) {
+		t.Errorf("unexpected values: %v", values)
+	}
+
+	// A gap between ranges is an illegal address, and nothing is written.
+	if err := b.Set(1, modbus.TableHoldingRegisters, 6, 1, 2, 3); !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Errorf("expected ErrIllegalDataAddress, got %v", err)
+	}
+	if values, _ := b.Get(1, modbus.TableHoldingRegisters, 6, 2); !reflect.DeepEqual(values, []uint16{0, 0}) {
+		t.Errorf("failed Set() modified values: %v", values)
+	}
+
+	if _, err := b.Get(2, modbus.TableHoldingRegisters, 0, 1); !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Errorf("expected ErrIllegalDataAddress for unknown unit, got %v", err)
+	}
+
+	b.AddRange(1, modbus.TableCoils, 0, 8)
+	if err := b.Set(1, modbus.TableCoils, 0, 2); err == nil {
+		t.Error("expected error for coil value 2, got nil")
+	}
+}
+
+func TestRegisterBank_Server(t *testing.T) {
+	b := NewRegisterBank()
+	b.AddRange(1, modbus.TableCoils, 0, 16)
+	b.AddRange(1, modbus.TableDiscreteInputs, 0, 16)
+	b.AddRange(1, modbus.TableHoldingRegisters, 100, 10)
+	b.AddRange(1, modbus.TableInputRegisters, 0, 2)
+	b.AddRange(2, modbus.TableHoldingRegisters, 0, 1)
+	b.Set(1, modbus.TableDiscreteInputs, 0, 1, 0, 1)
+	b.Set(1, modbus.TableInputRegisters, 0, 0x1234, 0x5678)
+
+	var changes []Change
+	b.OnChange(func(c Change) { changes = append(changes, c) })
+
+	s := NewServer()
+	b.Register(s)
+	c, stop := startServer(t, s)
+	defer stop()
+
+	inputs, err := c.ReadDiscreteInputs(1, 0, 3)
+	if err != nil {
+		t.Fatalf("ReadDiscreteInputs() error: %v", err)
+	}
+	if !reflect.DeepEqual(inputs, []bool{true, false, true}) {
+		t.Errorf("unexpected discrete inputs: %v", inputs)
+	}
+
+	registers, err := c.ReadInputRegisters(1, 0, 2)
+	if err != nil {
+		t.Fatalf("ReadInputRegisters() error: %v", err)
+	}
+	if !reflect.DeepEqual(registers, []uint16{0x1234, 0x5678}) {
+		t.Errorf("unexpected input registers: %v", registers)
+	}
+
+	if err := c.WriteMultipleCoils(1, 3, []bool{true, true, false, true}); err != nil {
+		t.Fatalf("WriteMultipleCoils() error: %v", err)
+	}
+	coils, _ := c.ReadCoils(1, 0, 8)
+	if !reflect.DeepEqual(coils, []bool{false, false, false, true, true, false, true, false}) {
+		t.Errorf("unexpected coils after write: %v", coils)
+	}
+
+	if err := c.WriteSingleRegister(1, 105, 0xBEEF); err != nil {
+		t.Fatalf("WriteSingleRegister() error: %v", err)
+	}
+	if err := c.WriteSingleCoil(1, 0, true); err != nil {
+		t.Fatalf("WriteSingleCoil() error: %v", err)
+	}
+	if values, _ := b.Get(1, modbus.TableHoldingRegisters, 105, 1); values[0] != 0xBEEF {
+		t.Errorf("expected register 105 to be 0xBEEF, got 0x%04X", values[0])
+	}
+
+	expected := []Change{
+		{UnitID: 1, Table: modbus.TableCoils, Address: 3, Values: []uint16{1, 1, 0, 1}},
+		{UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 105, Values: []uint16{0xBEEF}},
+		{UnitID: 1, Table: modbus.TableCoils, Address: 0, Values: []uint16{1}},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes.\nExpected: %+v\nGot:      %+v", expected, changes)
+	}
+
+	if _, err := c.ReadHoldingRegisters(1, 108, 3); !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Errorf("expected ErrIllegalDataAddress reading past the range, got %v", err)
+	}
+	if err := c.WriteSingleRegister(2, 1, 0); !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Errorf("expected ErrIllegalDataAddress writing outside unit 2's range, got %v", err)
+	}
+	if len(changes) != 3 {
+		t.Errorf("failed writes must not fire hooks, got %d changes", len(changes))
+	}
+}
+
+func TestRegisterBank_ReadWriteMultipleRegisters(t *testing.T) {
+	b := NewRegisterBank()
+	b.AddRange(1, modbus.TableHoldingRegisters, 0, 10)
+	b.Set(1, modbus.TableHoldingRegisters, 0, 1, 2, 3, 4)
+
+	var changes []Change
+	b.OnChange(func(c Change) { changes = append(changes, c) })
+
+	s := NewServer()
+	b.Register(s)
+	c, stop := startServer(t, s)
+	defer stop()
+
+	// The write lands before the read, so the overlap reads back new values.
+	values, err := c.ReadWriteMultipleRegisters(1, 0, 4, 2, []uint16{0xAAAA, 0xBBBB, 0xCCCC})
+	if err != nil {
+		t.Fatalf("ReadWriteMultipleRegisters() error: %v", err)
+	}
+	if !reflect.DeepEqual(values, []uint16{1, 2, 0xAAAA, 0xBBBB}) {
+		t.Errorf("unexpected values read: %04X", values)
+	}
+	if values, _ := b.Get(1, modbus.TableHoldingRegisters, 4, 1); values[0] != 0xCCCC {
+		t.Errorf("expected register 4 to be 0xCCCC, got 0x%04X", values[0])
+	}
+
+	// A read range that does not exist fails the whole request.
+	if _, err := c.ReadWriteMultipleRegisters(1, 8, 4, 0, []uint16{0xDEAD}); !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Errorf("expected ErrIllegalDataAddress reading past the range, got %v", err)
+	}
+	if values, _ := b.Get(1, modbus.TableHoldingRegisters, 0, 1); values[0] != 1 {
+		t.Errorf("failed request modified register 0: 0x%04X", values[0])
+	}
+
+	expected := []Change{{UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 2, Values: []uint16{0xAAAA, 0xBBBB, 0xCCCC}}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes.\nExpected: %+v\nGot:      %+v", expected, changes)
+	}
+}
+
+func TestRegisterBank_MaskWriteRegister(t *testing.T) {
+	b := NewRegisterBank()
+	b.AddRange(1, modbus.TableHoldingRegisters, 0, 2)
+	b.Set(1, modbus.TableHoldingRegisters, 0, 0x0012)
+
+	var changes []Change
+	b.OnChange(func(c Change) { changes = append(changes, c) })
+
+	s := NewServer()
+	b.Register(s)
+	c, stop := startServer(t, s)
+	defer stop()
+
+	// The example from the specification: 0x12 AND 0xF2 OR (0x25 AND NOT 0xF2).
+	if err := c.MaskWriteRegister(1, 0, 0x00F2, 0x0025); err != nil {
+		t.Fatalf("MaskWriteRegister() error: %v", err)
+	}
+	if err := c.SetRegisterBit(1, 1, 15); err != nil {
+		t.Fatalf("SetRegisterBit() error: %v", err)
+	}
+	if err := c.SetRegisterBit(1, 1, 0); err != nil {
+		t.Fatalf("SetRegisterBit() error: %v", err)
+	}
+	if err := c.ClearRegisterBit(1, 1, 15); err != nil {
+		t.Fatalf("ClearRegisterBit() error: %v", err)
+	}
+
+	values, _ := b.Get(1, modbus.TableHoldingRegisters, 0, 2)
+	if !reflect.DeepEqual(values, []uint16{0x0017, 0x0001}) {
+		t.Errorf("unexpected registers: %04X", values)
+	}
+
+	if err := c.SetRegisterBit(1, 2, 0); !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Errorf("expected ErrIllegalDataAddress outside the range, got %v", err)
+	}
+
+	expected := []Change{
+		{UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 0, Values: []uint16{0x0017}},
+		{UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 1, Values: []uint16{0x8000}},
+		{UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 1, Values: []uint16{0x8001}},
+		{UnitID: 1, Table: modbus.TableHoldingRegisters, Address: 1, Values: []uint16{0x0001}},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes.\nExpected: %+v\nGot:      %+v", expected, changes)
+	}
+}
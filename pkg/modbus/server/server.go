@@ -0,0 +1,414 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"modbus_client/pkg/modbus"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close.
+var ErrServerClosed = errors.New("modbus server closed")
+
+// FrameHandler serves one request frame (unit ID, function code and data) and
+// returns the reply frame. An error is answered with an exception reply: a
+// *modbus.ModbusException (e.g. modbus.ErrIllegalDataAddress) with its own
+// code, anything else with Slave Device Failure.
+type FrameHandler interface {
+	HandleFrame(frame []byte) ([]byte, error)
+}
+
+type FrameHandlerFunc func(frame []byte) ([]byte, error)
+
+func (f FrameHandlerFunc) HandleFrame(frame []byte) ([]byte, error) {
+	return f(frame)
+}
+
+// ReadHandler serves reading requests (FC 1-4). It returns the data bytes of
+// the reply: coils packed LSB first, registers big-endian.
+type ReadHandler interface {
+	HandleRead(req *modbus.ReadingRequest) ([]byte, error)
+}
+
+type ReadHandlerFunc func(req *modbus.ReadingRequest) ([]byte, error)
+
+func (f ReadHandlerFunc) HandleRead(req *modbus.ReadingRequest) ([]byte, error) {
+	return f(req)
+}
+
+// SingleWriteHandler serves single writing requests (FC 5 and 6).
+type SingleWriteHandler interface {
+	HandleSingleWrite(req *modbus.SingleWritingRequest) error
+}
+
+type SingleWriteHandlerFunc func(req *modbus.SingleWritingRequest) error
+
+func (f SingleWriteHandlerFunc) HandleSingleWrite(req *modbus.SingleWritingRequest) error {
+	return f(req)
+}
+
+// MultipleWriteHandler serves multiple writing requests (FC 15 and 16).
+type MultipleWriteHandler interface {
+	HandleMultipleWrite(req *modbus.MultipleWritingRequest) error
+}
+
+type MultipleWriteHandlerFunc func(req *modbus.MultipleWritingRequest) error
+
+func (f MultipleWriteHandlerFunc) HandleMultipleWrite(req *modbus.MultipleWritingRequest) error {
+	return f(req)
+}
+
+// ReadWriteHandler serves read/write requests (FC 23). It must perform the
+// write before the read, as one operation, and return the registers read,
+// big-endian.
+type ReadWriteHandler interface {
+	HandleReadWrite(req *modbus.ReadWriteRequest) ([]byte, error)
+}
+
+type ReadWriteHandlerFunc func(req *modbus.ReadWriteRequest) ([]byte, error)
+
+func (f ReadWriteHandlerFunc) HandleReadWrite(req *modbus.ReadWriteRequest) ([]byte, error) {
+	return f(req)
+}
+
+// MaskWriteHandler serves mask write requests (FC 22). It must read and update
+// the register as one operation.
+type MaskWriteHandler interface {
+	HandleMaskWrite(req *modbus.MaskWriteRequest) error
+}
+
+type MaskWriteHandlerFunc func(req *modbus.MaskWriteRequest) error
+
+func (f MaskWriteHandlerFunc) HandleMaskWrite(req *modbus.MaskWriteRequest) error {
+	return f(req)
+}
+
+// Server is a Modbus TCP slave. Requests are dispatched by function code to
+// the handler registered for it; function codes without a handler are
+// answered with Illegal Function.
+type Server struct {
+	// IdleTimeout closes connections that send no request for this long.
+	// Zero means connections are kept open until the client closes them.
+	IdleTimeout time.Duration
+
+	mu        sync.Mutex
+	handlers  map[modbus.FunctionCode]FrameHandler
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func NewServer() *Server {
+	return &Server{
+		handlers:  make(map[modbus.FunctionCode]FrameHandler),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// HandleFrame registers h for fc, replacing any previous handler. It is the
+// hook for function codes that have no typed handler.
+func (s *Server) HandleFrame(fc modbus.FunctionCode, h FrameHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[fc] = h
+}
+
+func (s *Server) HandleRead(fc modbus.FunctionCode, h ReadHandler) {
+	s.HandleFrame(fc, FrameHandlerFunc(func(frame []byte) ([]byte, error) {
+		req := &modbus.ReadingRequest{}
+		if err := req.Parse(frame); err != nil {
+			return nil, requestException(err)
+		}
+
+		data, err := h.HandleRead(req)
+		if err != nil {
+			return nil, err
+		}
+
+		expected, err := req.ExpectedByteCount()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != expected {
+			return nil, fmt.Errorf("read handler returned %d bytes, expected %d", len(data), expected)
+		}
+
+		resp := &modbus.ReadingResponse{
+			Header:    req.Header,
+			ByteCount: uint16(len(data)),
+			Response:  data,
+		}
+		return resp.Build()
+	}))
+}
+
+func (s *Server) HandleSingleWrite(fc modbus.FunctionCode, h SingleWriteHandler) {
+	s.HandleFrame(fc, FrameHandlerFunc(func(frame []byte) ([]byte, error) {
+		req := &modbus.SingleWritingRequest{}
+		if err := req.Parse(frame); err != nil {
+			return nil, requestException(err)
+		}
+
+		if err := h.HandleSingleWrite(req); err != nil {
+			return nil, err
+		}
+
+		resp := &modbus.SingleWritingResponse{
+			Header:       req.Header,
+			ValueWritten: frame[4:6],
+		}
+		return resp.Build()
+	}))
+}
+
+func (s *Server) HandleMultipleWrite(fc modbus.FunctionCode, h MultipleWriteHandler) {
+	s.HandleFrame(fc, FrameHandlerFunc(func(frame []byte) ([]byte, error) {
+		req := &modbus.MultipleWritingRequest{}
+		if err := req.Parse(frame); err != nil {
+			return nil, requestException(err)
+		}
+
+		if err := h.HandleMultipleWrite(req); err != nil {
+			return nil, err
+		}
+
+		resp := &modbus.MultipleWritingResponse{
+			Header:          req.Header,
+			QuantityWritten: frame[4:6],
+		}
+		return resp.Build()
+	}))
+}
+
+func (s *Server) HandleReadWrite(fc modbus.FunctionCode, h ReadWriteHandler) {
+	s.HandleFrame(fc, FrameHandlerFunc(func(frame []byte) ([]byte, error) {
+		req := &modbus.ReadWriteRequest{}
+		if err := req.Parse(frame); err != nil {
+			return nil, requestException(err)
+		}
+
+		data, err := h.HandleReadWrite(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != 2*int(req.Quantity) {
+			return nil, fmt.Errorf("read/write handler returned %d bytes, expected %d", len(data), 2*int(req.Quantity))
+		}
+
+		resp := &modbus.ReadWriteResponse{
+			Header:    req.Header,
+			ByteCount: uint16(len(data)),
+			Response:  data,
+		}
+		return resp.Build()
+	}))
+}
+
+func (s *Server) HandleMaskWrite(fc modbus.FunctionCode, h MaskWriteHandler) {
+	s.HandleFrame(fc, FrameHandlerFunc(func(frame []byte) ([]byte, error) {
+		req := &modbus.MaskWriteRequest{}
+		if err := req.Parse(frame); err != nil {
+			return nil, requestException(err)
+		}
+
+		if err := h.HandleMaskWrite(req); err != nil {
+			return nil, err
+		}
+
+		resp := &modbus.MaskWriteResponse{
+			Header:  req.Header,
+			AndMask: req.AndMask,
+			OrMask:  req.OrMask,
+		}
+		return resp.Build()
+	}))
+}
+
+// ListenAndServe listens on the TCP address addr and serves requests until
+// Close is called.
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on ln, serving each one in its own goroutine.
+// It closes ln when it returns.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ln.Close()
+		return ErrServerClosed
+	}
+	s.listeners[ln] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, ln)
+		s.mu.Unlock()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops all listeners, closes open connections and waits for their
+// goroutines to finish.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for ln := range s.listeners {
+		ln.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		if s.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		}
+
+		request, err := readRequest(conn)
+		if err != nil {
+			return
+		}
+
+		reply := &modbus.TCPRequestWrapper{
+			TransactionID: request.TransactionID,
+			ProtocolID:    request.ProtocolID,
+			ModbusFrame:   s.serveFrame(request.ModbusFrame),
+		}
+		adu, err := reply.Build()
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(adu); err != nil {
+			return
+		}
+	}
+}
+
+// serveFrame dispatches frame to its handler and returns the reply frame,
+// an exception reply if the request could not be served.
+func (s *Server) serveFrame(frame []byte) []byte {
+	header := modbus.ModbusHeader{
+		SlaveID: frame[0],
+		FC:      modbus.FunctionCode(frame[1]),
+	}
+
+	s.mu.Lock()
+	h, ok := s.handlers[header.FC]
+	s.mu.Unlock()
+
+	if !ok {
+		return exceptionFrame(header, modbus.ExceptionIllegalFunction)
+	}
+
+	reply, err := h.HandleFrame(frame)
+	if err != nil {
+		var exc *modbus.ModbusException
+		if errors.As(err, &exc) {
+			return exceptionFrame(header, exc.Code)
+		}
+		return exceptionFrame(header, modbus.ExceptionSlaveDeviceFailure)
+	}
+	return reply
+}
+
+// requestException returns the exception for a request that failed to parse:
+// Illegal Data Address if its range runs past the end of the address space,
+// Illegal Data Value for a bad quantity, byte count or value.
+func requestException(err error) error {
+	if errors.Is(err, modbus.ErrAddressOverflow) {
+		return modbus.ErrIllegalDataAddress
+	}
+	return modbus.ErrIllegalDataValue
+}
+
+func exceptionFrame(header modbus.ModbusHeader, code modbus.ModbusExceptionCode) []byte {
+	resp := &modbus.ExceptionResponse{Header: header, Code: code}
+	frame, _ := resp.Build()
+	return frame
+}
+
+// readRequest reads one Modbus TCP request. A malformed MBAP header makes the
+// stream impossible to resynchronise, so it is returned as an error and the
+// connection dropped.
+func readRequest(r io.Reader) (*modbus.TCPResponseWrapper, error) {
+	header := make([]byte, 6)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint16(header[4:6])
+	if length < 2 || length > 254 {
+		return nil, fmt.Errorf("invalid Modbus header length: %d", length)
+	}
+
+	adu := make([]byte, 6+int(length))
+	copy(adu, header)
+	if _, err := io.ReadFull(r, adu[6:]); err != nil {
+		return nil, err
+	}
+
+	request := &modbus.TCPResponseWrapper{}
+	if err := request.Parse(adu); err != nil {
+		return nil, err
+	}
+	if request.ProtocolID != 0 {
+		return nil, fmt.Errorf("unsupported protocol ID: 0x%04X", request.ProtocolID)
+	}
+	return request, nil
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
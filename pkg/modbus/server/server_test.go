@@ -0,0 +1,199 @@
+package server
+
+import (
+	"errors"
+	"modbus_client/pkg/modbus"
+	"modbus_client/pkg/modbus/modbus_client"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// startServer serves s on a loopback port and returns a client connected to
+// it, plus a function that stops both.
+func startServer(t *testing.T, s *Server) (*modbus_client.ModbusClient, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.Serve(ln)
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	c := modbus_client.NewModbusClient("127.0.0.1", port, 2*time.Second, nil)
+	return c, func() {
+		c.Close()
+		s.Close()
+	}
+}
+
+func TestServer_ReadHoldingRegisters(t *testing.T) {
+	s := NewServer()
+	var got *modbus.ReadingRequest
+	s.HandleRead(modbus.FCReadHoldingRegisters, ReadHandlerFunc(func(req *modbus.ReadingRequest) ([]byte, error) {
+		got = req
+		return []byte{0x12, 0x34, 0xAB, 0xCD}, nil
+	}))
+	c, stop := startServer(t, s)
+	defer stop()
+
+	values, err := c.ReadHoldingRegisters(0x11, 0x006B, 2)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters() error: %v", err)
+	}
+	if !reflect.DeepEqual(values, []uint16{0x1234, 0xABCD}) {
+		t.Errorf("unexpected register values: %v", values)
+	}
+	if got.Header.SlaveID != 0x11 || got.Header.Address() != 0x006B || got.Quantity != 2 {
+		t.Errorf("unexpected request passed to handler: %+v", got)
+	}
+}
+
+func TestServer_Writes(t *testing.T) {
+	s := NewServer()
+	var single *modbus.SingleWritingRequest
+	var multiple *modbus.MultipleWritingRequest
+	s.HandleSingleWrite(modbus.FCForceSingleCoil, SingleWriteHandlerFunc(func(req *modbus.SingleWritingRequest) error {
+		single = req
+		return nil
+	}))
+	s.HandleMultipleWrite(modbus.FCPresetMultipleRegisters, MultipleWriteHandlerFunc(func(req *modbus.MultipleWritingRequest) error {
+		multiple = req
+		return nil
+	}))
+	c, stop := startServer(t, s)
+	defer stop()
+
+	if err := c.WriteSingleCoil(0x01, 0x00AC, true); err != nil {
+		t.Fatalf("WriteSingleCoil() error: %v", err)
+	}
+	if single.Header.Address() != 0x00AC || single.Value2Write != 0xFF00 {
+		t.Errorf("unexpected single write request: %+v", single)
+	}
+
+	if err := c.WriteMultipleRegisters(0x01, 0x0001, []uint16{0x000A, 0x0102}); err != nil {
+		t.Fatalf("WriteMultipleRegisters() error: %v", err)
+	}
+	if multiple.Quantity != 2 || !reflect.DeepEqual(multiple.Values2Write, []byte{0x00, 0x0A, 0x01, 0x02}) {
+		t.Errorf("unexpected multiple write request: %+v", multiple)
+	}
+}
+
+func TestServer_Exceptions(t *testing.T) {
+	s := NewServer()
+	s.HandleRead(modbus.FCReadHoldingRegisters, ReadHandlerFunc(func(req *modbus.ReadingRequest) ([]byte, error) {
+		return nil, modbus.ErrIllegalDataAddress
+	}))
+	s.HandleRead(modbus.FCReadInputRegisters, ReadHandlerFunc(func(req *modbus.ReadingRequest) ([]byte, error) {
+		return nil, errors.New("sensor offline")
+	}))
+	s.HandleRead(modbus.FCReadCoils, ReadHandlerFunc(func(req *modbus.ReadingRequest) ([]byte, error) {
+		// One byte short for 9 coils.
+		return []byte{0xFF}, nil
+	}))
+	c, stop := startServer(t, s)
+	defer stop()
+
+	if _, err := c.ReadHoldingRegisters(0x01, 0x0000, 1); !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Errorf("expected ErrIllegalDataAddress, got %v", err)
+	}
+	if _, err := c.ReadInputRegisters(0x01, 0x0000, 1); !errors.Is(err, modbus.ErrSlaveDeviceFailure) {
+		t.Errorf("expected ErrSlaveDeviceFailure, got %v", err)
+	}
+	if _, err := c.ReadCoils(0x01, 0x0000, 9); !errors.Is(err, modbus.ErrSlaveDeviceFailure) {
+		t.Errorf("expected ErrSlaveDeviceFailure for short handler data, got %v", err)
+	}
+	if _, err := c.ReadDiscreteInputs(0x01, 0x0000, 1); !errors.Is(err, modbus.ErrIllegalFunction) {
+		t.Errorf("expected ErrIllegalFunction for unhandled function code, got %v", err)
+	}
+}
+
+func TestServer_MalformedRequests(t *testing.T) {
+	s := NewServer()
+	s.HandleRead(modbus.FCReadHoldingRegisters, ReadHandlerFunc(func(req *modbus.ReadingRequest) ([]byte, error) {
+		t.Error("read handler called for a request that should have been rejected")
+		return nil, nil
+	}))
+	s.HandleMultipleWrite(modbus.FCPresetMultipleRegisters, MultipleWriteHandlerFunc(func(req *modbus.MultipleWritingRequest) error {
+		t.Error("write handler called for a request that should have been rejected")
+		return nil
+	}))
+	s.HandleReadWrite(modbus.FCReadWriteMultipleRegisters, ReadWriteHandlerFunc(func(req *modbus.ReadWriteRequest) ([]byte, error) {
+		t.Error("read/write handler called for a request that should have been rejected")
+		return nil, nil
+	}))
+	c, stop := startServer(t, s)
+	defer stop()
+
+	tests := []struct {
+		name    string
+		request []byte
+		want    error
+	}{
+		{"read quantity over limit", []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x7E}, modbus.ErrIllegalDataValue},
+		{"read quantity zero", []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x00}, modbus.ErrIllegalDataValue},
+		{"read past 0xFFFF", []byte{0x01, 0x03, 0xFF, 0xFF, 0x00, 0x02}, modbus.ErrIllegalDataAddress},
+		{"write byte count mismatch", []byte{0x01, 0x10, 0x00, 0x00, 0x00, 0x02, 0x02, 0x00, 0x01}, modbus.ErrIllegalDataValue},
+		{"write quantity over limit", []byte{0x01, 0x10, 0x00, 0x00, 0x00, 0x7C, 0x00}, modbus.ErrIllegalDataValue},
+		{"write past 0xFFFF", []byte{0x01, 0x10, 0xFF, 0xFF, 0x00, 0x02, 0x04, 0x00, 0x01, 0x00, 0x02}, modbus.ErrIllegalDataAddress},
+		{"read/write quantity over limit", []byte{0x01, 0x17, 0x00, 0x00, 0x00, 0x7E, 0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0x01}, modbus.ErrIllegalDataValue},
+		{"read/write read past 0xFFFF", []byte{0x01, 0x17, 0xFF, 0xFE, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0x01}, modbus.ErrIllegalDataAddress},
+		{"read/write write past 0xFFFF", []byte{0x01, 0x17, 0x00, 0x00, 0x00, 0x01, 0xFF, 0xFF, 0x00, 0x02, 0x04, 0x00, 0x01, 0x00, 0x02}, modbus.ErrIllegalDataAddress},
+	}
+	for _, tt := range tests {
+		if _, err := c.Sender.Send(tt.request); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestServer_HandleFrame(t *testing.T) {
+	s := NewServer()
+	s.HandleFrame(0x41, FrameHandlerFunc(func(frame []byte) ([]byte, error) {
+		return append([]byte{}, frame...), nil
+	}))
+	c, stop := startServer(t, s)
+	defer stop()
+
+	request := []byte{0x07, 0x41, 0xDE, 0xAD}
+	reply, err := c.Sender.Send(request)
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	if !reflect.DeepEqual(reply, request) {
+		t.Errorf("reply mismatch.\nExpected: %v\nGot:      %v", request, reply)
+	}
+}
+
+func TestServer_Close(t *testing.T) {
+	s := NewServer()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ln) }()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	s.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrServerClosed) {
+			t.Errorf("expected ErrServerClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after Close")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("expected open connection to be closed by server")
+	}
+}
@@ -0,0 +1,98 @@
+package modbus
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Table identifies one of the four Modbus data tables.
+type Table byte
+
+const (
+	TableCoils Table = iota
+	TableDiscreteInputs
+	TableHoldingRegisters
+	TableInputRegisters
+)
+
+func (t Table) String() string {
+	switch t {
+	case TableCoils:
+		return "Coils"
+	case TableDiscreteInputs:
+		return "Discrete Inputs"
+	case TableHoldingRegisters:
+		return "Holding Registers"
+	case TableInputRegisters:
+		return "Input Registers"
+	default:
+		return "Unknown Table"
+	}
+}
+
+// ParseTable accepts the short names used in configuration files and on the
+// command line: "coil", "discrete", "holding" and "input" (plurals and the
+// full names returned by String are accepted too).
+func ParseTable(name string) (Table, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "coil", "coils":
+		return TableCoils, nil
+	case "discrete", "discrete input", "discrete inputs", "discrete_input", "discrete_inputs":
+		return TableDiscreteInputs, nil
+	case "holding", "holding register", "holding registers", "holding_register", "holding_registers":
+		return TableHoldingRegisters, nil
+	case "input", "input register", "input registers", "input_register", "input_registers":
+		return TableInputRegisters, nil
+	default:
+		return 0, fmt.Errorf("unknown Modbus table: %q", name)
+	}
+}
+
+// IsBit reports whether the table holds single-bit values.
+func (t Table) IsBit() bool {
+	return t == TableCoils || t == TableDiscreteInputs
+}
+
+// Writable reports whether a master can write to the table.
+func (t Table) Writable() bool {
+	return t == TableCoils || t == TableHoldingRegisters
+}
+
+// ReadFunctionCode returns the function code that reads the table.
+func (t Table) ReadFunctionCode() FunctionCode {
+	switch t {
+	case TableCoils:
+		return FCReadCoils
+	case TableDiscreteInputs:
+		return FCReadInputStatus
+	case TableHoldingRegisters:
+		return FCReadHoldingRegisters
+	default:
+		return FCReadInputRegisters
+	}
+}
+
+// MaxReadQuantity returns the most values a single read of the table may
+// request.
+func (t Table) MaxReadQuantity() int {
+	if t.IsBit() {
+		return MaxReadCoils
+	}
+	return MaxReadRegisters
+}
+
+// TableOf returns the table a function code reads or writes.
+func TableOf(fc FunctionCode) (Table, error) {
+	switch fc {
+	case FCReadCoils, FCForceSingleCoil, FCForceMultipleCoils:
+		return TableCoils, nil
+	case FCReadInputStatus:
+		return TableDiscreteInputs, nil
+	case FCReadHoldingRegisters, FCPresetSingleRegister, FCPresetMultipleRegisters, FCMaskWriteRegister, FCReadWriteMultipleRegisters:
+		return TableHoldingRegisters, nil
+	case FCReadInputRegisters:
+		return TableInputRegisters, nil
+	default:
+		return 0, fmt.Errorf("function code 0x%02X does not address a data table", byte(fc))
+	}
+}
@@ -0,0 +1,47 @@
+package modbus
+
+import "testing"
+
+func TestParseTable(t *testing.T) {
+	tests := map[string]Table{
+		"coil":               TableCoils,
+		"Coils":              TableCoils,
+		"discrete":           TableDiscreteInputs,
+		"holding":            TableHoldingRegisters,
+		" Holding Registers": TableHoldingRegisters,
+		"input_registers":    TableInputRegisters,
+	}
+	for name, expected := range tests {
+		table, err := ParseTable(name)
+		if err != nil {
+			t.Errorf("ParseTable(%q) error: %v", name, err)
+			continue
+		}
+		if table != expected {
+			t.Errorf("ParseTable(%q) = %v, expected %v", name, table, expected)
+		}
+	}
+
+	if _, err := ParseTable("eeprom"); err == nil {
+		t.Error("expected error for unknown table, got nil")
+	}
+}
+
+func TestTableOf(t *testing.T) {
+	for _, table := range []Table{TableCoils, TableDiscreteInputs, TableHoldingRegisters, TableInputRegisters} {
+		got, err := TableOf(table.ReadFunctionCode())
+		if err != nil {
+			t.Fatalf("TableOf(%v) error: %v", table.ReadFunctionCode(), err)
+		}
+		if got != table {
+			t.Errorf("TableOf(%v) = %v, expected %v", table.ReadFunctionCode(), got, table)
+		}
+	}
+
+	if table, _ := TableOf(FCPresetMultipleRegisters); table != TableHoldingRegisters {
+		t.Errorf("expected FC16 to address holding registers, got %v", table)
+	}
+	if _, err := TableOf(0x41); err == nil {
+		t.Error("expected error for unknown function code, got nil")
+	}
+}
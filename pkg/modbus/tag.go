@@ -0,0 +1,263 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DataType is how the coils or registers of a Tag are interpreted.
+type DataType byte
+
+const (
+	TypeBool DataType = iota
+	TypeInt16
+	TypeUint16
+	TypeInt32
+	TypeUint32
+	TypeFloat32
+	TypeInt64
+	TypeUint64
+	TypeFloat64
+)
+
+var dataTypeNames = map[DataType]string{
+	TypeBool:    "bool",
+	TypeInt16:   "int16",
+	TypeUint16:  "uint16",
+	TypeInt32:   "int32",
+	TypeUint32:  "uint32",
+	TypeFloat32: "float32",
+	TypeInt64:   "int64",
+	TypeUint64:  "uint64",
+	TypeFloat64: "float64",
+}
+
+func (d DataType) String() string {
+	if name, ok := dataTypeNames[d]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseDataType accepts the names returned by String, plus "float" and
+// "double" for float32 and float64.
+func ParseDataType(name string) (DataType, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "float":
+		return TypeFloat32, nil
+	case "double":
+		return TypeFloat64, nil
+	}
+	for d, n := range dataTypeNames {
+		if n == name {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown data type %q", name)
+}
+
+// Length returns the number of coils or registers a value of the type takes.
+func (d DataType) Length() uint16 {
+	switch d {
+	case TypeInt32, TypeUint32, TypeFloat32:
+		return 2
+	case TypeInt64, TypeUint64, TypeFloat64:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// Tag gives a meaning to a coil or to a group of registers. The engineering
+// value is raw*Scale + Offset, where raw is the value decoded according to
+// Type and Order; a zero Scale means 1. Order defaults to big-endian without
+// word swapping ("ABCD").
+type Tag struct {
+	Name    string
+	UnitID  byte
+	Table   Table
+	Address uint16
+	Type    DataType
+	Order   WordByteOrder
+	Scale   float64
+	Offset  float64
+	Unit    string
+}
+
+// Validate checks that the tag can be read: bool tags must live in a coil or
+// discrete input table and other types in a register table.
+func (t *Tag) Validate() error {
+	if t.Table > TableInputRegisters {
+		return fmt.Errorf("tag %q: unknown Modbus table %d", t.Name, t.Table)
+	}
+	if _, ok := dataTypeNames[t.Type]; !ok {
+		return fmt.Errorf("tag %q: unknown data type %d", t.Name, t.Type)
+	}
+	if t.Table.IsBit() != (t.Type == TypeBool) {
+		return fmt.Errorf("tag %q: type %v cannot be stored in %v", t.Name, t.Type, t.Table)
+	}
+	if int(t.Address)+int(t.Type.Length()) > 0x10000 {
+		return fmt.Errorf("tag %q: %v at address %d runs past the end of the address space", t.Name, t.Type, t.Address)
+	}
+	if math.IsNaN(t.Scale) || math.IsInf(t.Scale, 0) || math.IsNaN(t.Offset) || math.IsInf(t.Offset, 0) {
+		return fmt.Errorf("tag %q: scale and offset must be finite", t.Name)
+	}
+	return nil
+}
+
+// Point returns the addresses to read for the tag.
+func (t *Tag) Point() Point {
+	return Point{
+		UnitID:  t.UnitID,
+		Table:   t.Table,
+		Address: t.Address,
+		Length:  t.Type.Length(),
+	}
+}
+
+// Decode converts the tag's coils (0 or 1) or registers, as read, into its
+// engineering value.
+func (t *Tag) Decode(values []uint16) (float64, error) {
+	if len(values) != int(t.Type.Length()) {
+		return 0, fmt.Errorf("tag %q: %v needs %d values, got %d", t.Name, t.Type, t.Type.Length(), len(values))
+	}
+
+	if t.Type == TypeBool {
+		if values[0] != 0 {
+			return t.scale(1), nil
+		}
+		return t.scale(0), nil
+	}
+
+	data := Uint16sToBytes(values, binary.BigEndian)
+	order := t.order()
+
+	var raw float64
+	var err error
+	switch t.Type {
+	case TypeInt16:
+		var v int16
+		v, err = BytesToInt16(data, order.ByteOrder)
+		raw = float64(v)
+	case TypeUint16:
+		var v uint16
+		v, err = BytesToUint16(data, order.ByteOrder)
+		raw = float64(v)
+	case TypeInt32:
+		var v int32
+		v, err = BytesToInt32(data, order)
+		raw = float64(v)
+	case TypeUint32:
+		var v uint32
+		v, err = BytesToUint32(data, order)
+		raw = float64(v)
+	case TypeFloat32:
+		var v float32
+		v, err = BytesToFloat32(data, order)
+		raw = float64(v)
+	case TypeInt64:
+		var v int64
+		v, err = BytesToInt64(data, order)
+		raw = float64(v)
+	case TypeUint64:
+		var v uint64
+		v, err = BytesToUint64(data, order)
+		raw = float64(v)
+	case TypeFloat64:
+		raw, err = BytesToFloat64(data, order)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("tag %q: %w", t.Name, err)
+	}
+	return t.scale(raw), nil
+}
+
+// Encode converts an engineering value into the coils (0 or 1) or registers
+// to write. Integer types are rounded to the nearest raw value and rejected
+// if out of range.
+func (t *Tag) Encode(value float64) ([]uint16, error) {
+	scale := t.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	raw := (value - t.Offset) / scale
+	if math.IsNaN(raw) || (math.IsInf(raw, 0) && t.Type != TypeFloat32 && t.Type != TypeFloat64) {
+		return nil, fmt.Errorf("tag %q: cannot encode %v", t.Name, value)
+	}
+
+	if t.Type == TypeBool {
+		if raw != 0 {
+			return []uint16{1}, nil
+		}
+		return []uint16{0}, nil
+	}
+
+	order := t.order()
+	rounded := math.Round(raw)
+	outOfRange := func(min, max float64) error {
+		if rounded < min || rounded > max {
+			return fmt.Errorf("tag %q: %v is out of range for %v", t.Name, value, t.Type)
+		}
+		return nil
+	}
+
+	var data []byte
+	switch t.Type {
+	case TypeInt16:
+		if err := outOfRange(math.MinInt16, math.MaxInt16); err != nil {
+			return nil, err
+		}
+		data = Int16ToBytes(int16(rounded), order.ByteOrder)
+	case TypeUint16:
+		if err := outOfRange(0, math.MaxUint16); err != nil {
+			return nil, err
+		}
+		data = Uint16ToBytes(uint16(rounded), order.ByteOrder)
+	case TypeInt32:
+		if err := outOfRange(math.MinInt32, math.MaxInt32); err != nil {
+			return nil, err
+		}
+		data = Int32ToBytes(int32(rounded), order)
+	case TypeUint32:
+		if err := outOfRange(0, math.MaxUint32); err != nil {
+			return nil, err
+		}
+		data = Uint32ToBytes(uint32(rounded), order)
+	case TypeFloat32:
+		data = Float32ToBytes(float32(raw), order)
+	case TypeInt64:
+		// float64 cannot represent MaxInt64; 2^63 is the first value too big.
+		if rounded < math.MinInt64 || rounded >= math.Exp2(63) {
+			return nil, fmt.Errorf("tag %q: %v is out of range for %v", t.Name, value, t.Type)
+		}
+		data = Int64ToBytes(int64(rounded), order)
+	case TypeUint64:
+		if rounded < 0 || rounded >= math.Exp2(64) {
+			return nil, fmt.Errorf("tag %q: %v is out of range for %v", t.Name, value, t.Type)
+		}
+		data = Uint64ToBytes(uint64(rounded), order)
+	case TypeFloat64:
+		data = Float64ToBytes(raw, order)
+	default:
+		return nil, fmt.Errorf("tag %q: unknown data type %d", t.Name, t.Type)
+	}
+
+	return BytesToUint16s(data, binary.BigEndian)
+}
+
+func (t *Tag) scale(raw float64) float64 {
+	if t.Scale == 0 {
+		return raw + t.Offset
+	}
+	return raw*t.Scale + t.Offset
+}
+
+func (t *Tag) order() WordByteOrder {
+	if t.Order.ByteOrder == nil {
+		return WordByteOrder{ByteOrder: binary.BigEndian, SwapWords: t.Order.SwapWords}
+	}
+	return t.Order
+}
@@ -0,0 +1,89 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTagDecodeEncode(t *testing.T) {
+	cdab := WordByteOrder{ByteOrder: binary.BigEndian, SwapWords: true}
+
+	tests := []struct {
+		tag       Tag
+		registers []uint16
+		value     float64
+	}{
+		{Tag{Name: "temperature", Table: TableInputRegisters, Type: TypeInt16, Scale: 0.1}, []uint16{0xFF9C}, -10},
+		{Tag{Name: "level", Table: TableHoldingRegisters, Type: TypeUint16, Scale: 0.5, Offset: 100}, []uint16{20}, 110},
+		{Tag{Name: "energy", Table: TableInputRegisters, Type: TypeUint32, Order: cdab}, []uint16{0x5678, 0x1234}, 0x12345678},
+		{Tag{Name: "power", Table: TableInputRegisters, Type: TypeFloat32}, []uint16{0x3FC0, 0x0000}, 1.5},
+		{Tag{Name: "total", Table: TableHoldingRegisters, Type: TypeInt64}, []uint16{0xFFFF, 0xFFFF, 0xFFFF, 0xFFFE}, -2},
+		{Tag{Name: "precise", Table: TableHoldingRegisters, Type: TypeFloat64, Order: cdab}, []uint16{0, 0, 0, 0xC000}, -2},
+		{Tag{Name: "running", Table: TableCoils, Type: TypeBool}, []uint16{1}, 1},
+	}
+
+	for _, tt := range tests {
+		if err := tt.tag.Validate(); err != nil {
+			t.Fatalf("Validate() error: %v", err)
+		}
+
+		value, err := tt.tag.Decode(tt.registers)
+		if err != nil {
+			t.Fatalf("%s: Decode() error: %v", tt.tag.Name, err)
+		}
+		if math.Abs(value-tt.value) > 1e-9 {
+			t.Errorf("%s: Decode() = %v, expected %v", tt.tag.Name, value, tt.value)
+		}
+
+		registers, err := tt.tag.Encode(tt.value)
+		if err != nil {
+			t.Fatalf("%s: Encode() error: %v", tt.tag.Name, err)
+		}
+		if !reflect.DeepEqual(registers, tt.registers) {
+			t.Errorf("%s: Encode() = %04X, expected %04X", tt.tag.Name, registers, tt.registers)
+		}
+	}
+}
+
+func TestTagEncode_OutOfRange(t *testing.T) {
+	tag := Tag{Name: "setpoint", Table: TableHoldingRegisters, Type: TypeInt16, Scale: 0.01}
+	if _, err := tag.Encode(400); err == nil {
+		t.Error("expected error encoding 40000 raw into int16, got nil")
+	}
+	if registers, err := tag.Encode(-3.14159); err != nil || registers[0] != 0xFEC6 {
+		t.Errorf("expected -314 (0xFEC6), got %04X, %v", registers, err)
+	}
+
+	unsigned := Tag{Name: "count", Table: TableHoldingRegisters, Type: TypeUint32}
+	if _, err := unsigned.Encode(-1); err == nil {
+		t.Error("expected error encoding negative value into uint32, got nil")
+	}
+}
+
+func TestTagValidate(t *testing.T) {
+	bad := []Tag{
+		{Name: "bool in register", Table: TableHoldingRegisters, Type: TypeBool},
+		{Name: "float in coil", Table: TableCoils, Type: TypeFloat32},
+		{Name: "past the end", Table: TableHoldingRegisters, Type: TypeFloat64, Address: 0xFFFE},
+		{Name: "unknown type", Table: TableHoldingRegisters, Type: 42},
+		{Name: "bad scale", Table: TableHoldingRegisters, Type: TypeUint16, Scale: math.Inf(1)},
+	}
+	for _, tag := range bad {
+		if err := tag.Validate(); err == nil {
+			t.Errorf("%s: expected error, got nil", tag.Name)
+		}
+	}
+}
+
+func TestParseDataType(t *testing.T) {
+	for name, expected := range map[string]DataType{"float": TypeFloat32, "Int16": TypeInt16, "double": TypeFloat64, "bool": TypeBool} {
+		if d, err := ParseDataType(name); err != nil || d != expected {
+			t.Errorf("ParseDataType(%q) = %v, %v, expected %v", name, d, err, expected)
+		}
+	}
+	if _, err := ParseDataType("string"); err == nil {
+		t.Error("expected error for unknown type, got nil")
+	}
+}
@@ -2,6 +2,7 @@ package modbus
 
 import (
 	"encoding/binary"
+	"fmt"
 )
 
 type TCPRequestWrapper struct {
@@ -15,18 +16,42 @@ func (wrap *TCPRequestWrapper) Build() ([]byte, error) {
 	frame := make([]byte, 6+len(wrap.ModbusFrame))
 
 	//modbus tcp frame:
-	// transcation ID 0001
-	// protocol ID 0000
+	// transcation ID XXXX
+	// protocol ID XXXX (0000 for Modbus)
 	// message length 00XX (bytes to folllow)
 	// modbus frame
 	wrap.MessageLength = uint16(len(wrap.ModbusFrame))
 
-	frame[0] = 0b0000
-	frame[1] = 0b0001
-	frame[2] = 0b0000
-	frame[3] = 0b0000
+	binary.BigEndian.PutUint16(frame[0:2], wrap.TransactionID)
+	binary.BigEndian.PutUint16(frame[2:4], wrap.ProtocolID)
 	binary.BigEndian.PutUint16(frame[4:6], wrap.MessageLength)
 	copy(frame[6:], wrap.ModbusFrame)
 
 	return frame, nil
 }
+
+type TCPResponseWrapper struct {
+	TransactionID uint16
+	ProtocolID    uint16
+	MessageLength uint16
+	ModbusFrame   []byte
+}
+
+// Parse splits a Modbus TCP reply into its MBAP fields and the Modbus frame
+// (unit ID onwards), checking the length field against the bytes received.
+func (wrap *TCPResponseWrapper) Parse(adu []byte) error {
+	if len(adu) < 8 {
+		return fmt.Errorf("Modbus TCP frame too short: expected at least 8 bytes, got %d", len(adu))
+	}
+
+	wrap.TransactionID = binary.BigEndian.Uint16(adu[0:2])
+	wrap.ProtocolID = binary.BigEndian.Uint16(adu[2:4])
+	wrap.MessageLength = binary.BigEndian.Uint16(adu[4:6])
+
+	if int(wrap.MessageLength) != len(adu)-6 {
+		return fmt.Errorf("MBAP length mismatch: header says %d bytes, got %d", wrap.MessageLength, len(adu)-6)
+	}
+
+	wrap.ModbusFrame = adu[6:]
+	return nil
+}
@@ -11,7 +11,6 @@ func TestTCPRequestWrapper_Build(t *testing.T) {
 	modbusFrame := []byte{0x11, 0x22, 0x33}
 
 	// Initialize the TCPRequestWrapper.
-	// Note: TransactionID and ProtocolID are not used in the Build() method.
 	wrap := &TCPRequestWrapper{
 		TransactionID: 1,
 		ProtocolID:    0,
@@ -45,3 +44,46 @@ func TestTCPRequestWrapper_Build(t *testing.T) {
 		t.Errorf("Expected MessageLength %d, got %d", len(modbusFrame), wrap.MessageLength)
 	}
 }
+
+func TestTCPRequestWrapper_BuildTransactionID(t *testing.T) {
+	wrap := &TCPRequestWrapper{
+		TransactionID: 0x1234,
+		ProtocolID:    0x0000,
+		ModbusFrame:   []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01},
+	}
+
+	frame, err := wrap.Build()
+	if err != nil {
+		t.Fatalf("Build() returned error: %v", err)
+	}
+
+	expected := []byte{0x12, 0x34, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x00, 0x00, 0x01}
+	if !reflect.DeepEqual(frame, expected) {
+		t.Errorf("Expected frame %v, got %v", expected, frame)
+	}
+}
+
+func TestTCPResponseWrapper_Parse(t *testing.T) {
+	adu := []byte{
+		0x00, 0x02, // Transaction ID
+		0x00, 0x00, // Protocol ID
+		0x00, 0x05, // Message Length
+		0x01, 0x03, 0x02, 0x00, 0x2A, // ModbusFrame
+	}
+
+	wrap := &TCPResponseWrapper{}
+	if err := wrap.Parse(adu); err != nil {
+		t.Fatalf("Parse() returned error: %v", err)
+	}
+	if wrap.TransactionID != 2 || wrap.ProtocolID != 0 || wrap.MessageLength != 5 {
+		t.Errorf("unexpected MBAP fields: %+v", wrap)
+	}
+	if !reflect.DeepEqual(wrap.ModbusFrame, adu[6:]) {
+		t.Errorf("Expected frame %v, got %v", adu[6:], wrap.ModbusFrame)
+	}
+
+	// A length field that disagrees with the received bytes must be rejected.
+	if err := wrap.Parse(adu[:10]); err == nil {
+		t.Error("expected error for truncated frame, got nil")
+	}
+}